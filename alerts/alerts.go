@@ -0,0 +1,229 @@
+// Package alerts evaluates users' powder alert rules against the latest
+// stored predictions and persists the alert events they fire, for the web
+// app to deliver.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// ThresholdsCM are the snowfall thresholds a rule can use; they match the
+// fields of models.PowderProb.
+var ThresholdsCM = [4]int{5, 10, 20, 30}
+
+// Store is the persistence needed to evaluate alerts.
+// *repository.AlertRepository satisfies it.
+type Store interface {
+	ListEnabledAlertRules(ctx context.Context) ([]models.AlertRule, error)
+	SaveAlertEvents(ctx context.Context, events []models.AlertEvent) ([]models.AlertEvent, error)
+}
+
+// PredictionSource loads the latest predictions.
+// *repository.PredictionRepository satisfies it.
+type PredictionSource interface {
+	LoadPredictionData(ctx context.Context) (*models.PredictionData, error)
+}
+
+// Options controls an evaluation.
+type Options struct {
+	// Location is used to turn the run's GeneratedAt into a calendar date when
+	// computing lead days. Defaults to UTC.
+	Location *time.Location
+	// DryRun evaluates the rules without persisting events. Every matching
+	// event is returned, including ones already fired.
+	DryRun bool
+}
+
+// ValidateRule reports every problem with rule, joined into one error.
+func ValidateRule(rule models.AlertRule) error {
+	var errs []error
+	if rule.UserID == "" {
+		errs = append(errs, errors.New("user ID is required"))
+	}
+	if !validThreshold(rule.ThresholdCM) {
+		errs = append(errs, fmt.Errorf("threshold must be one of %v cm: %d", ThresholdsCM, rule.ThresholdCM))
+	}
+	if rule.MinProbabilityPct < 1 || rule.MinProbabilityPct > 100 {
+		errs = append(errs, fmt.Errorf("min probability must be between 1 and 100: %d", rule.MinProbabilityPct))
+	}
+	if rule.MinLeadDays < 0 {
+		errs = append(errs, fmt.Errorf("min lead days must not be negative: %d", rule.MinLeadDays))
+	}
+	if rule.MaxLeadDays < rule.MinLeadDays {
+		errs = append(errs, fmt.Errorf("max lead days %d is less than min lead days %d", rule.MaxLeadDays, rule.MinLeadDays))
+	}
+	return errors.Join(errs...)
+}
+
+func validThreshold(cm int) bool {
+	for _, t := range ThresholdsCM {
+		if cm == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Run evaluates the enabled rules against the latest predictions, persists the
+// matching events unless opts.DryRun is set, and returns the events that were
+// newly fired. Events already fired for the same rule, run and resort-day are
+// not returned again.
+func Run(ctx context.Context, store Store, src PredictionSource, opts Options) ([]models.AlertEvent, error) {
+	if store == nil || src == nil {
+		return nil, errors.New("nil store or prediction source")
+	}
+
+	rules, err := store.ListEnabledAlertRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list alert rules: %w", err)
+	}
+	data, err := src.LoadPredictionData(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load predictions: %w", err)
+	}
+	if data.GeneratedAt == "" {
+		// Nothing has been predicted yet.
+		return []models.AlertEvent{}, nil
+	}
+
+	events, err := Evaluate(rules, data, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DryRun {
+		return events, nil
+	}
+	fired, err := store.SaveAlertEvents(ctx, events)
+	if err != nil {
+		return nil, fmt.Errorf("save alert events: %w", err)
+	}
+	return fired, nil
+}
+
+// Evaluate returns an event for every rule and resort-day of data that
+// matches, ordered by resort ID, forecast date and rule ID. Rules that fail
+// ValidateRule and disabled rules are skipped.
+//
+// A day matches when its lead time is within the rule's window and the
+// probability of exceeding the rule's threshold is at least
+// MinProbabilityPct. The probability is taken from the day's
+// PowderProbability; without one, it is SnowProbPct (or 100 if that is unset)
+// when the deterministic SnowfallCM exceeds the threshold, and 0 otherwise.
+func Evaluate(rules []models.AlertRule, data *models.PredictionData, loc *time.Location) ([]models.AlertEvent, error) {
+	if data == nil {
+		return nil, errors.New("nil prediction data")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	generated, err := time.Parse(time.RFC3339, data.GeneratedAt)
+	if err != nil {
+		return nil, fmt.Errorf("parse generated_at %q: %w", data.GeneratedAt, err)
+	}
+	generated = generated.In(loc)
+	runDate := time.Date(generated.Year(), generated.Month(), generated.Day(), 0, 0, 0, 0, time.UTC)
+
+	active := make([]models.AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Enabled && ValidateRule(rule) == nil {
+			active = append(active, rule)
+		}
+	}
+
+	type eventKey struct {
+		ruleID, resortID, date string
+	}
+	seen := make(map[eventKey]bool)
+	events := []models.AlertEvent{}
+	for resortID, pred := range data.Resorts {
+		for _, rule := range active {
+			if !appliesTo(rule, resortID, pred) {
+				continue
+			}
+			for _, day := range pred.Daily {
+				date, err := time.Parse("2006-01-02", day.Date)
+				if err != nil {
+					return nil, fmt.Errorf("parse forecast date %q for %s: %w", day.Date, resortID, err)
+				}
+				lead := int(date.Sub(runDate).Hours() / 24)
+				if lead < rule.MinLeadDays || lead > rule.MaxLeadDays {
+					continue
+				}
+				probability := ExceedanceProbability(day, rule.ThresholdCM)
+				key := eventKey{rule.ID, resortID, day.Date}
+				if probability < rule.MinProbabilityPct || seen[key] {
+					continue
+				}
+				seen[key] = true
+				events = append(events, models.AlertEvent{
+					RuleID:         rule.ID,
+					UserID:         rule.UserID,
+					RunGeneratedAt: data.GeneratedAt,
+					ResortID:       resortID,
+					ResortName:     pred.Name,
+					ForecastDate:   day.Date,
+					LeadDays:       lead,
+					ThresholdCM:    rule.ThresholdCM,
+					ProbabilityPct: probability,
+					SnowfallCM:     day.SnowfallCM,
+				})
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.ResortID != b.ResortID {
+			return a.ResortID < b.ResortID
+		}
+		if a.ForecastDate != b.ForecastDate {
+			return a.ForecastDate < b.ForecastDate
+		}
+		return a.RuleID < b.RuleID
+	})
+	return events, nil
+}
+
+// appliesTo reports whether rule covers the resort. Prefectures are compared
+// ignoring case and surrounding space.
+func appliesTo(rule models.AlertRule, resortID string, pred models.Prediction) bool {
+	if rule.ResortID != "" && rule.ResortID != resortID {
+		return false
+	}
+	if rule.Prefecture != "" && !strings.EqualFold(strings.TrimSpace(rule.Prefecture), strings.TrimSpace(pred.Prefecture)) {
+		return false
+	}
+	return true
+}
+
+// ExceedanceProbability returns the probability, in percent, that the day's
+// snowfall exceeds thresholdCM, as described on Evaluate.
+func ExceedanceProbability(day models.DailyForecast, thresholdCM int) int {
+	if p := day.PowderProbability; p != nil {
+		switch thresholdCM {
+		case 5:
+			return p.Exceeds5cm
+		case 10:
+			return p.Exceeds10cm
+		case 20:
+			return p.Exceeds20cm
+		case 30:
+			return p.Exceeds30cm
+		}
+	}
+	if day.SnowfallCM <= float64(thresholdCM) {
+		return 0
+	}
+	if day.SnowProbPct != nil {
+		return int(math.Round(*day.SnowProbPct))
+	}
+	return 100
+}
@@ -0,0 +1,181 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/migrations"
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+	_ "modernc.org/sqlite"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestValidateRule_ReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidateRule(models.AlertRule{UserID: "u", ThresholdCM: 10, MinProbabilityPct: 50, MaxLeadDays: 2}); err != nil {
+		t.Fatalf("ValidateRule(valid) error = %v", err)
+	}
+	err := ValidateRule(models.AlertRule{ThresholdCM: 15, MinProbabilityPct: 0, MinLeadDays: 3, MaxLeadDays: 1})
+	if err == nil {
+		t.Fatal("ValidateRule(invalid) expected error")
+	}
+	for _, want := range []string{"user ID", "threshold", "min probability", "max lead days"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateRule() error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestExceedanceProbability(t *testing.T) {
+	t.Parallel()
+
+	powder := &models.PowderProb{Exceeds5cm: 90, Exceeds10cm: 70, Exceeds20cm: 40, Exceeds30cm: 10}
+	tests := []struct {
+		name      string
+		day       models.DailyForecast
+		threshold int
+		want      int
+	}{
+		{"ensemble", models.DailyForecast{SnowfallCM: 0, PowderProbability: powder}, 20, 40},
+		{"deterministic below threshold", models.DailyForecast{SnowfallCM: 8, SnowProbPct: floatPtr(95)}, 10, 0},
+		{"deterministic with snow probability", models.DailyForecast{SnowfallCM: 12, SnowProbPct: floatPtr(64.6)}, 10, 65},
+		{"deterministic at threshold", models.DailyForecast{SnowfallCM: 30, SnowProbPct: floatPtr(95)}, 30, 0},
+		{"deterministic only", models.DailyForecast{SnowfallCM: 30.5}, 30, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExceedanceProbability(tt.day, tt.threshold); got != tt.want {
+				t.Fatalf("ExceedanceProbability() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_MatchesScopeThresholdAndLeadWindow(t *testing.T) {
+	t.Parallel()
+
+	data := &models.PredictionData{
+		// 23:00 UTC is already the 11th in Tokyo.
+		GeneratedAt: "2025-01-10T23:00:00Z",
+		Resorts: map[string]models.Prediction{
+			"happo": {Name: "Happo", Prefecture: "Nagano", Daily: []models.DailyForecast{
+				{Date: "2025-01-11", PowderProbability: &models.PowderProb{Exceeds20cm: 80}},
+				{Date: "2025-01-12", PowderProbability: &models.PowderProb{Exceeds20cm: 50}},
+				{Date: "2025-01-14", PowderProbability: &models.PowderProb{Exceeds20cm: 90}},
+				{Date: "2025-01-14", PowderProbability: &models.PowderProb{Exceeds20cm: 90}},
+			}},
+			"niseko": {Name: "Niseko", Prefecture: "hokkaido", Daily: []models.DailyForecast{
+				{Date: "2025-01-11", SnowfallCM: 35},
+			}},
+		},
+	}
+	rules := []models.AlertRule{
+		{ID: "nagano", UserID: "u1", Prefecture: " nagano ", ThresholdCM: 20, MinProbabilityPct: 60, MaxLeadDays: 3, Enabled: true},
+		{ID: "niseko", UserID: "u2", ResortID: "niseko", ThresholdCM: 30, MinProbabilityPct: 100, MaxLeadDays: 0, Enabled: true},
+		{ID: "off", UserID: "u3", ThresholdCM: 5, MinProbabilityPct: 1, MaxLeadDays: 7},
+		{ID: "invalid", UserID: "u4", ThresholdCM: 15, MinProbabilityPct: 1, MaxLeadDays: 7, Enabled: true},
+	}
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	events, err := Evaluate(rules, data, tokyo)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	type fired struct {
+		rule, resort, date string
+		lead, probability  int
+	}
+	want := []fired{
+		{"nagano", "happo", "2025-01-11", 0, 80},
+		{"nagano", "happo", "2025-01-14", 3, 90},
+		{"niseko", "niseko", "2025-01-11", 0, 100},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Evaluate() returned %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		got := fired{e.RuleID, e.ResortID, e.ForecastDate, e.LeadDays, e.ProbabilityPct}
+		if got != w || e.RunGeneratedAt != data.GeneratedAt {
+			t.Fatalf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+
+	if _, err := Evaluate(rules, &models.PredictionData{GeneratedAt: "yesterday"}, nil); err == nil {
+		t.Fatal("Evaluate() with invalid generated_at expected error")
+	}
+}
+
+func TestRun_PersistsAndDeduplicatesEvents(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	if err := migrations.Migrate(ctx, db); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+
+	predRepo := repository.NewPredictionRepository(db)
+	alertRepo := repository.NewAlertRepository(db)
+
+	rule := &models.AlertRule{UserID: "u1", ThresholdCM: 10, MinProbabilityPct: 50, MaxLeadDays: 2, Enabled: true}
+	if err := alertRepo.SaveAlertRule(ctx, rule); err != nil {
+		t.Fatalf("SaveAlertRule() error = %v", err)
+	}
+
+	save := func(generatedAt string) {
+		t.Helper()
+		if err := predRepo.SavePredictions(ctx, &models.PredictionData{
+			GeneratedAt: generatedAt,
+			Resorts: map[string]models.Prediction{
+				"resort-1": {Name: "One", Daily: []models.DailyForecast{
+					{Date: "2025-01-15", PowderProbability: &models.PowderProb{Exceeds10cm: 70}},
+					{Date: "2025-01-16", PowderProbability: &models.PowderProb{Exceeds10cm: 20}},
+				}},
+			},
+		}); err != nil {
+			t.Fatalf("SavePredictions() error = %v", err)
+		}
+	}
+
+	save("2025-01-15T00:00:00Z")
+	first, err := Run(ctx, alertRepo, predRepo, Options{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(first) != 1 || first[0].ForecastDate != "2025-01-15" || first[0].ID == "" {
+		t.Fatalf("Run() = %+v", first)
+	}
+
+	again, err := Run(ctx, alertRepo, predRepo, Options{})
+	if err != nil || len(again) != 0 {
+		t.Fatalf("Run() on the same run = %+v, %v; want no new events", again, err)
+	}
+	dry, err := Run(ctx, alertRepo, predRepo, Options{DryRun: true})
+	if err != nil || len(dry) != 1 {
+		t.Fatalf("Run(dry run) = %+v, %v", dry, err)
+	}
+
+	save("2025-01-15T06:00:00Z")
+	next, err := Run(ctx, alertRepo, predRepo, Options{})
+	if err != nil || len(next) != 1 || next[0].RunGeneratedAt != "2025-01-15T06:00:00Z" {
+		t.Fatalf("Run() on a new run = %+v, %v", next, err)
+	}
+
+	pending, err := alertRepo.ListPendingAlertEvents(ctx, 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("ListPendingAlertEvents() = %+v, %v", pending, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package calibration recomputes the per-resort, per-source BiasFactors in
+// prediction_config by regressing stored forecasts against observed snowfall.
+// Only forecasts from a single model can be attributed to a source, so resorts
+// whose forecasts always blend several models keep their factors unchanged.
+package calibration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/verification"
+)
+
+// Default bounds and sample requirements applied when Options leaves them zero.
+const (
+	DefaultMinSamples = 20
+	DefaultMinFactor  = 0.5
+	DefaultMaxFactor  = 2.0
+)
+
+// Store is the persistence needed to recalibrate bias factors.
+// *repository.PredictionRepository satisfies it.
+type Store interface {
+	verification.SampleSource
+	LoadPredictionConfig(ctx context.Context) (map[string]models.PredictorResortConfig, error)
+	SavePredictionConfig(ctx context.Context, configs map[string]models.PredictorResortConfig) error
+}
+
+// Options controls which runs are used and how factors are bounded.
+type Options struct {
+	// From and To bound the runs' GeneratedAt (inclusive).
+	From time.Time
+	To   time.Time
+	// Location is used to date runs when pairing forecasts with observations.
+	// Defaults to UTC.
+	Location *time.Location
+	// MinSamples is the number of wet-day samples a resort/source pair needs
+	// before its factor is updated. Defaults to DefaultMinSamples.
+	MinSamples int
+	// MinFactor and MaxFactor clamp the new factor.
+	// Default to DefaultMinFactor and DefaultMaxFactor.
+	MinFactor float64
+	MaxFactor float64
+	// DryRun computes the report without writing prediction_config.
+	DryRun bool
+}
+
+// FactorChange is one resort/source bias factor before and after recalibration.
+// Old is nil when the resort had no factor for the source.
+type FactorChange struct {
+	ResortID    string   `json:"resort_id"`
+	Source      string   `json:"source"`
+	Old         *float64 `json:"old"`
+	New         float64  `json:"new"`
+	SampleCount int      `json:"sample_count"`
+	// Clamped is set when the fitted factor fell outside [MinFactor, MaxFactor].
+	Clamped bool `json:"clamped"`
+}
+
+// Report summarises a recalibration.
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Changes []FactorChange `json:"changes"`
+	// Skipped lists "resort_id/source" pairs with too few samples or no
+	// prediction_config entry.
+	Skipped []string `json:"skipped"`
+}
+
+// String renders the report as a table of old versus new factors.
+func (r Report) String() string {
+	var b strings.Builder
+	if r.DryRun {
+		b.WriteString("dry run: prediction_config not updated\n")
+	}
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESORT\tSOURCE\tOLD\tNEW\tSAMPLES\t")
+	for _, c := range r.Changes {
+		old := "-"
+		if c.Old != nil {
+			old = fmt.Sprintf("%.3f", *c.Old)
+		}
+		newFactor := fmt.Sprintf("%.3f", c.New)
+		if c.Clamped {
+			newFactor += " (clamped)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t\n", c.ResortID, c.Source, old, newFactor, c.SampleCount)
+	}
+	tw.Flush() //nolint:errcheck
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&b, "skipped: %s\n", strings.Join(r.Skipped, ", "))
+	}
+	return b.String()
+}
+
+// Recalibrate fits a new bias factor for every resort and model source with
+// enough samples and, unless opts.DryRun is set, writes the updated
+// BiasFactors back to prediction_config.
+//
+// Stored forecasts have the factors of their run applied, so each is divided
+// by the factor its run recorded and the new factor is the least-squares
+// multiplier through the origin that maps the raw forecast to observed
+// snowfall. It replaces the old factor rather than scaling it, so runs
+// overlapping an earlier recalibration's window do not compound. Forecasts
+// blending several models, runs that did not record their factors, and days
+// where both forecast and observation are zero are ignored.
+func Recalibrate(ctx context.Context, store Store, opts Options) (*Report, error) {
+	if store == nil {
+		return nil, errors.New("nil store")
+	}
+	if opts.To.Before(opts.From) {
+		return nil, fmt.Errorf("to %s is before from %s", opts.To.Format(time.RFC3339), opts.From.Format(time.RFC3339))
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = DefaultMinSamples
+	}
+	if opts.MinFactor <= 0 {
+		opts.MinFactor = DefaultMinFactor
+	}
+	if opts.MaxFactor <= 0 {
+		opts.MaxFactor = DefaultMaxFactor
+	}
+	if opts.MinFactor > opts.MaxFactor {
+		return nil, fmt.Errorf("min factor %g exceeds max factor %g", opts.MinFactor, opts.MaxFactor)
+	}
+
+	samples, err := verification.CollectSamples(ctx, store, opts.From, opts.To, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := store.LoadPredictionConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load prediction config: %w", err)
+	}
+
+	fits := Fit(samples)
+	report := &Report{DryRun: opts.DryRun, Changes: []FactorChange{}}
+	updated := make(map[string]models.PredictorResortConfig)
+	for _, f := range fits {
+		cfg, ok := configs[f.ResortID]
+		if !ok || f.SampleCount < opts.MinSamples || f.Scale == nil {
+			report.Skipped = append(report.Skipped, f.ResortID+"/"+f.Source)
+			continue
+		}
+
+		change := FactorChange{ResortID: f.ResortID, Source: f.Source, SampleCount: f.SampleCount}
+		if old, ok := cfg.BiasFactors[f.Source]; ok {
+			change.Old = &old
+		}
+		change.New = *f.Scale
+		if change.New < opts.MinFactor || change.New > opts.MaxFactor {
+			change.New = math.Min(math.Max(change.New, opts.MinFactor), opts.MaxFactor)
+			change.Clamped = true
+		}
+		report.Changes = append(report.Changes, change)
+
+		if pending, ok := updated[f.ResortID]; ok {
+			cfg = pending
+		} else {
+			cfg.BiasFactors = copyFactors(cfg.BiasFactors)
+		}
+		cfg.BiasFactors[f.Source] = change.New
+		updated[f.ResortID] = cfg
+	}
+
+	if opts.DryRun || len(updated) == 0 {
+		return report, nil
+	}
+	if err := store.SavePredictionConfig(ctx, updated); err != nil {
+		return nil, fmt.Errorf("save prediction config: %w", err)
+	}
+	return report, nil
+}
+
+// SourceFit is the regression result for one resort and model source.
+// Scale is nil when the forecasts carry no signal (all forecasts zero).
+type SourceFit struct {
+	ResortID    string
+	Source      string
+	SampleCount int
+	Scale       *float64
+}
+
+// Fit computes, per resort and model source, the multiplier k minimising
+// sum((observed - k*raw)^2), i.e. k = sum(r*o) / sum(r*r), where raw is the
+// forecast divided by the factor that was applied to it. Samples without a
+// single model source or a recorded factor are ignored.
+// Results are ordered by resort ID and source.
+func Fit(samples []verification.Sample) []SourceFit {
+	type key struct{ resortID, source string }
+	type accumulator struct {
+		n      int
+		fo, ff float64
+	}
+
+	acc := make(map[key]*accumulator)
+	for _, s := range samples {
+		if s.ModelSource == "" || s.AppliedFactor <= 0 || (s.ForecastCM == 0 && s.ObservedCM == 0) {
+			continue
+		}
+		raw := s.ForecastCM / s.AppliedFactor
+		k := key{s.ResortID, s.ModelSource}
+		a := acc[k]
+		if a == nil {
+			a = &accumulator{}
+			acc[k] = a
+		}
+		a.n++
+		a.fo += raw * s.ObservedCM
+		a.ff += raw * raw
+	}
+
+	fits := make([]SourceFit, 0, len(acc))
+	for k, a := range acc {
+		fit := SourceFit{ResortID: k.resortID, Source: k.source, SampleCount: a.n}
+		if a.ff > 0 {
+			scale := a.fo / a.ff
+			fit.Scale = &scale
+		}
+		fits = append(fits, fit)
+	}
+
+	sort.Slice(fits, func(i, j int) bool {
+		if fits[i].ResortID != fits[j].ResortID {
+			return fits[i].ResortID < fits[j].ResortID
+		}
+		return fits[i].Source < fits[j].Source
+	})
+	return fits
+}
+
+func copyFactors(factors map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(factors)+1)
+	for k, v := range factors {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+package calibration
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/verification"
+)
+
+type fakeStore struct {
+	runs        []models.PredictionRun
+	predictions map[string]map[string]models.Prediction
+	observed    map[string]map[string]int
+	configs     map[string]models.PredictorResortConfig
+	saved       map[string]models.PredictorResortConfig
+}
+
+func (f *fakeStore) ListPredictionRunsBetween(context.Context, time.Time, time.Time) ([]models.PredictionRun, error) {
+	return f.runs, nil
+}
+
+func (f *fakeStore) LoadPredictionRun(_ context.Context, runID string) (map[string]models.Prediction, error) {
+	return f.predictions[runID], nil
+}
+
+func (f *fakeStore) GetObservedSnowfall(context.Context, string, string) (map[string]map[string]int, error) {
+	return f.observed, nil
+}
+
+func (f *fakeStore) LoadPredictionConfig(context.Context) (map[string]models.PredictorResortConfig, error) {
+	return f.configs, nil
+}
+
+func (f *fakeStore) SavePredictionConfig(_ context.Context, configs map[string]models.PredictorResortConfig) error {
+	f.saved = configs
+	return nil
+}
+
+// newFakeStore builds one "msm" run where every forecast day, before the old
+// factor was applied, is a fifth of the observation.
+func newFakeStore(oldFactor *float64) *fakeStore {
+	applied := map[string]float64{}
+	scale := 1.0
+	if oldFactor != nil {
+		applied["msm"] = *oldFactor
+		scale = *oldFactor
+	}
+
+	daily := make([]models.DailyForecast, 0, 5)
+	observed := map[string]int{}
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		daily = append(daily, models.DailyForecast{Date: date, SnowfallCM: float64(2*(i+1)) * scale})
+		observed[date] = 10 * (i + 1)
+	}
+
+	cfg := models.PredictorResortConfig{Name: "One"}
+	if oldFactor != nil {
+		cfg.BiasFactors = map[string]float64{"msm": *oldFactor, "gsm": 0.9}
+	}
+
+	return &fakeStore{
+		runs: []models.PredictionRun{{ID: "run-1", Source: "msm", GeneratedAt: start}},
+		predictions: map[string]map[string]models.Prediction{
+			"run-1": {"resort-1": {Sources: []string{"msm"}, BiasFactors: applied, Daily: daily}},
+		},
+		observed: map[string]map[string]int{"resort-1": observed},
+		configs:  map[string]models.PredictorResortConfig{"resort-1": cfg},
+	}
+}
+
+func TestFit_LeastSquaresThroughOrigin(t *testing.T) {
+	t.Parallel()
+
+	fits := Fit([]verification.Sample{
+		// Forecasts with a factor of 2 applied: raw 2 and 4 against 4 and 8.
+		{ResortID: "r", Source: "blend", ModelSource: "msm", AppliedFactor: 2, ForecastCM: 4, ObservedCM: 4},
+		{ResortID: "r", Source: "blend", ModelSource: "msm", AppliedFactor: 2, ForecastCM: 8, ObservedCM: 8},
+		{ResortID: "r", Source: "blend", ModelSource: "msm", AppliedFactor: 1, ForecastCM: 0, ObservedCM: 0},
+		{ResortID: "r", Source: "blend", ModelSource: "", AppliedFactor: 1, ForecastCM: 1, ObservedCM: 1},
+		{ResortID: "r", Source: "blend", ModelSource: "msm", AppliedFactor: 0, ForecastCM: 1, ObservedCM: 9},
+		{ResortID: "r", Source: "blend", ModelSource: "gsm", AppliedFactor: 1, ForecastCM: 0, ObservedCM: 3},
+	})
+	if len(fits) != 2 {
+		t.Fatalf("Fit() returned %d fits, want 2: %+v", len(fits), fits)
+	}
+	if fits[0].Source != "gsm" || fits[0].Scale != nil {
+		t.Fatalf("gsm fit = %+v, want nil scale", fits[0])
+	}
+	if fits[1].Source != "msm" || fits[1].SampleCount != 2 || math.Abs(*fits[1].Scale-2) > 1e-9 {
+		t.Fatalf("msm fit = %+v, want scale 2 from 2 samples", fits[1])
+	}
+}
+
+func TestRecalibrate_DryRunDoesNotSave(t *testing.T) {
+	t.Parallel()
+
+	old := 1.2
+	store := newFakeStore(&old)
+	report, err := Recalibrate(context.Background(), store, Options{MinSamples: 3, MaxFactor: 5, DryRun: true})
+	if err != nil {
+		t.Fatalf("Recalibrate() error = %v", err)
+	}
+	if store.saved != nil {
+		t.Fatal("dry run saved prediction config")
+	}
+	if len(report.Changes) != 1 {
+		t.Fatalf("report changes = %+v, want 1", report.Changes)
+	}
+	change := report.Changes[0]
+	if change.Old == nil || *change.Old != 1.2 || math.Abs(change.New-5) > 1e-9 {
+		t.Fatalf("change = %+v, want 1.2 -> 5", change)
+	}
+	if out := report.String(); !strings.Contains(out, "dry run") || !strings.Contains(out, "5.000") {
+		t.Fatalf("report.String() = %q", out)
+	}
+}
+
+func TestRecalibrate_SavesClampedFactorAndKeepsOtherSources(t *testing.T) {
+	t.Parallel()
+
+	old := 1.2
+	store := newFakeStore(&old)
+	report, err := Recalibrate(context.Background(), store, Options{MinSamples: 3})
+	if err != nil {
+		t.Fatalf("Recalibrate() error = %v", err)
+	}
+	if !report.Changes[0].Clamped || report.Changes[0].New != DefaultMaxFactor {
+		t.Fatalf("change = %+v, want clamped to %v", report.Changes[0], DefaultMaxFactor)
+	}
+
+	saved, ok := store.saved["resort-1"]
+	if !ok {
+		t.Fatal("expected resort-1 config to be saved")
+	}
+	if saved.BiasFactors["msm"] != DefaultMaxFactor || saved.BiasFactors["gsm"] != 0.9 || saved.Name != "One" {
+		t.Fatalf("saved config = %+v", saved)
+	}
+	if store.configs["resort-1"].BiasFactors["msm"] != 1.2 {
+		t.Fatal("Recalibrate mutated the loaded config map")
+	}
+}
+
+func TestRecalibrate_DoesNotCompoundOverRecalibratedRuns(t *testing.T) {
+	t.Parallel()
+
+	old := 1.2
+	store := newFakeStore(&old)
+	opts := Options{MinSamples: 3, MaxFactor: 10}
+	first, err := Recalibrate(context.Background(), store, opts)
+	if err != nil {
+		t.Fatalf("Recalibrate() error = %v", err)
+	}
+
+	// Recalibrating again over the same runs, which recorded the 1.2 they
+	// were produced with, fits the same factor rather than scaling the new one.
+	store.configs = store.saved
+	second, err := Recalibrate(context.Background(), store, opts)
+	if err != nil {
+		t.Fatalf("second Recalibrate() error = %v", err)
+	}
+	if got, want := second.Changes[0].New, first.Changes[0].New; math.Abs(got-want) > 1e-9 || math.Abs(got-5) > 1e-9 {
+		t.Fatalf("second factor = %v, want %v", got, want)
+	}
+}
+
+func TestRecalibrate_SkipsRunsWithoutRecordedFactors(t *testing.T) {
+	t.Parallel()
+
+	old := 1.2
+	store := newFakeStore(&old)
+	pred := store.predictions["run-1"]["resort-1"]
+	pred.BiasFactors = nil
+	store.predictions["run-1"]["resort-1"] = pred
+
+	report, err := Recalibrate(context.Background(), store, Options{MinSamples: 3})
+	if err != nil {
+		t.Fatalf("Recalibrate() error = %v", err)
+	}
+	if len(report.Changes) != 0 || store.saved != nil {
+		t.Fatalf("report = %+v, want no changes", report)
+	}
+}
+
+func TestRecalibrate_SkipsBelowMinSamples(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore(nil)
+	report, err := Recalibrate(context.Background(), store, Options{})
+	if err != nil {
+		t.Fatalf("Recalibrate() error = %v", err)
+	}
+	if len(report.Changes) != 0 || len(report.Skipped) != 1 || report.Skipped[0] != "resort-1/msm" {
+		t.Fatalf("report = %+v, want resort-1/msm skipped", report)
+	}
+	if store.saved != nil {
+		t.Fatal("expected nothing to be saved")
+	}
+}
@@ -0,0 +1,235 @@
+// Package climatology builds per-day-of-year snowfall statistics for each
+// resort from daily_snowfall history, in the "MM-DD" keyed form stored in
+// prediction_config.
+package climatology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// Store is the persistence needed to build and save climatologies.
+// *repository.PredictionRepository satisfies it.
+type Store interface {
+	GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error)
+	LoadPredictionConfig(ctx context.Context) (map[string]models.PredictorResortConfig, error)
+	SavePredictionConfig(ctx context.Context, configs map[string]models.PredictorResortConfig) error
+}
+
+// Options controls how observations are pooled into daily statistics.
+type Options struct {
+	// Window is the number of calendar days on each side of a day whose
+	// observations are pooled into that day's statistics. Zero pools only the
+	// day itself.
+	Window int
+	// MinWinters is the number of distinct winters that must contribute to a
+	// day before an entry is emitted for it. Defaults to 1.
+	MinWinters int
+	// From and To bound the observations used ("YYYY-MM-DD", inclusive).
+	// Empty From uses all history; empty To uses today (UTC).
+	From string
+	To   string
+}
+
+// daysPerYear is the length of the non-leap calendar positions are measured on.
+const daysPerYear = 365
+
+// calendar lists every "MM-DD" key, including "02-29", in order.
+var calendar = func() []string {
+	keys := make([]string, 0, 366)
+	for d := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC); d.Year() == 2000; d = d.AddDate(0, 0, 1) {
+		keys = append(keys, d.Format("01-02"))
+	}
+	return keys
+}()
+
+// position places a month/day on a 365-day circle. Feb 29 sits halfway between
+// Feb 28 and Mar 1 so that it neither displaces nor duplicates either neighbour.
+func position(month time.Month, day int) float64 {
+	if month == time.February && day == 29 {
+		return 59.5
+	}
+	return float64(time.Date(2001, month, day, 0, 0, 0, 0, time.UTC).YearDay())
+}
+
+// circularDistance returns the shortest distance between two positions on the year circle.
+func circularDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	return math.Min(d, daysPerYear-d)
+}
+
+// winterOf returns the winter a date belongs to, identified by the year it
+// starts in: July through December start a winter, January through June
+// belong to the previous one.
+func winterOf(t time.Time) int {
+	if t.Month() >= time.July {
+		return t.Year()
+	}
+	return t.Year() - 1
+}
+
+type observation struct {
+	pos    float64
+	winter int
+	cm     float64
+}
+
+// Build computes climatology entries for one resort from its observed daily
+// snowfall, which is keyed by "YYYY-MM-DD" date. Days whose pooled
+// observations span fewer than opts.MinWinters winters are omitted.
+//
+// Feb 29 observations only pool into other days when the window reaches them
+// (Window >= 1). The "02-29" entry always pools Feb 28 and Mar 1 as well, so
+// that every winter contributes to it and it tracks its neighbours.
+func Build(observed map[string]int, opts Options) (map[string]models.ClimatologyEntry, error) {
+	if opts.Window < 0 {
+		return nil, fmt.Errorf("window must not be negative: %d", opts.Window)
+	}
+	minWinters := opts.MinWinters
+	if minWinters <= 0 {
+		minWinters = 1
+	}
+
+	obs := make([]observation, 0, len(observed))
+	for date, cm := range observed {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("parse observation date %q: %w", date, err)
+		}
+		obs = append(obs, observation{pos: position(t.Month(), t.Day()), winter: winterOf(t), cm: float64(cm)})
+	}
+
+	entries := make(map[string]models.ClimatologyEntry)
+	for _, key := range calendar {
+		t, _ := time.Parse("01-02", key) //nolint:errcheck // calendar keys are well-formed
+		target := position(t.Month(), t.Day())
+		window := float64(opts.Window)
+		if t.Month() == time.February && t.Day() == 29 {
+			window += 0.5
+		}
+
+		var values []float64
+		winters := make(map[int]struct{})
+		for _, o := range obs {
+			if circularDistance(o.pos, target) <= window {
+				values = append(values, o.cm)
+				winters[o.winter] = struct{}{}
+			}
+		}
+		if len(winters) < minWinters {
+			continue
+		}
+		entries[key] = entryFor(values)
+	}
+
+	return entries, nil
+}
+
+// entryFor computes mean, sample standard deviation and percentiles of values.
+func entryFor(values []float64) models.ClimatologyEntry {
+	sort.Float64s(values)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	avg := sum / float64(len(values))
+
+	var std float64
+	if len(values) > 1 {
+		var sq float64
+		for _, v := range values {
+			sq += (v - avg) * (v - avg)
+		}
+		std = math.Sqrt(sq / float64(len(values)-1))
+	}
+
+	return models.ClimatologyEntry{
+		Avg: avg,
+		Std: std,
+		P10: percentile(values, 0.10),
+		P25: percentile(values, 0.25),
+		P50: percentile(values, 0.50),
+		P75: percentile(values, 0.75),
+		P90: percentile(values, 0.90),
+	}
+}
+
+// percentile returns the p-quantile of sorted values using linear interpolation.
+func percentile(sorted []float64, p float64) *float64 {
+	rank := p * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	v := sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+	return &v
+}
+
+// BuildAll builds climatologies for every resort with observations in daily_snowfall.
+func BuildAll(ctx context.Context, store Store, opts Options) (map[string]map[string]models.ClimatologyEntry, error) {
+	if store == nil {
+		return nil, errors.New("nil store")
+	}
+	from := opts.From
+	if from == "" {
+		from = "0001-01-01"
+	}
+	to := opts.To
+	if to == "" {
+		to = time.Now().UTC().Format("2006-01-02")
+	}
+
+	observed, err := store.GetObservedSnowfall(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get observed snowfall: %w", err)
+	}
+
+	result := make(map[string]map[string]models.ClimatologyEntry, len(observed))
+	for resortID, days := range observed {
+		entries, err := Build(days, opts)
+		if err != nil {
+			return nil, fmt.Errorf("build climatology for %s: %w", resortID, err)
+		}
+		if len(entries) > 0 {
+			result[resortID] = entries
+		}
+	}
+	return result, nil
+}
+
+// Update builds climatologies and writes them into prediction_config for every
+// resort that already has a config entry. Returns the built climatologies,
+// including those for resorts that were not saved.
+func Update(ctx context.Context, store Store, opts Options) (map[string]map[string]models.ClimatologyEntry, error) {
+	built, err := BuildAll(ctx, store, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := store.LoadPredictionConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load prediction config: %w", err)
+	}
+
+	updated := make(map[string]models.PredictorResortConfig)
+	for resortID, entries := range built {
+		cfg, ok := configs[resortID]
+		if !ok {
+			continue
+		}
+		cfg.Climatology = entries
+		updated[resortID] = cfg
+	}
+
+	if len(updated) > 0 {
+		if err := store.SavePredictionConfig(ctx, updated); err != nil {
+			return nil, fmt.Errorf("save prediction config: %w", err)
+		}
+	}
+	return built, nil
+}
@@ -0,0 +1,145 @@
+package climatology
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func TestBuild_StatisticsForSingleDay(t *testing.T) {
+	t.Parallel()
+
+	observed := map[string]int{
+		"2020-01-15": 0,
+		"2021-01-15": 10,
+		"2022-01-15": 20,
+		"2023-01-15": 30,
+		"2024-01-15": 40,
+	}
+
+	entries, err := Build(observed, Options{MinWinters: 5})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Build() returned %d entries, want 1: %v", len(entries), entries)
+	}
+	e, ok := entries["01-15"]
+	if !ok {
+		t.Fatal("missing 01-15 entry")
+	}
+	if e.Avg != 20 || math.Abs(e.Std-math.Sqrt(250)) > 1e-9 {
+		t.Fatalf("avg/std = %v/%v, want 20/%v", e.Avg, e.Std, math.Sqrt(250))
+	}
+	if *e.P10 != 4 || *e.P50 != 20 || *e.P90 != 36 {
+		t.Fatalf("p10/p50/p90 = %v/%v/%v, want 4/20/36", *e.P10, *e.P50, *e.P90)
+	}
+}
+
+func TestBuild_MinWintersUsesSeasonNotCalendarYear(t *testing.T) {
+	t.Parallel()
+
+	// Dec 31 and Jan 1 of the same winter must count as one winter.
+	observed := map[string]int{"2023-12-31": 5, "2024-01-01": 7}
+
+	entries, err := Build(observed, Options{Window: 1, MinWinters: 2})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Build() = %v, want no entries for a single winter", entries)
+	}
+
+	entries, err = Build(observed, Options{Window: 1, MinWinters: 1})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	// The window wraps around the year end.
+	if e, ok := entries["12-31"]; !ok || e.Avg != 6 {
+		t.Fatalf("12-31 entry = %+v, want avg 6", entries["12-31"])
+	}
+	if e, ok := entries["01-02"]; !ok || e.Avg != 7 {
+		t.Fatalf("01-02 entry = %+v, want avg 7", entries["01-02"])
+	}
+}
+
+func TestBuild_Feb29(t *testing.T) {
+	t.Parallel()
+
+	observed := map[string]int{
+		"2023-02-28": 10,
+		"2023-03-01": 20,
+		"2024-02-28": 30,
+		"2024-02-29": 100,
+		"2024-03-01": 40,
+	}
+
+	entries, err := Build(observed, Options{MinWinters: 2})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if e := entries["02-28"]; e.Avg != 20 {
+		t.Fatalf("02-28 avg = %v, want 20 (Feb 29 must not leak in with window 0)", e.Avg)
+	}
+	feb29, ok := entries["02-29"]
+	if !ok {
+		t.Fatal("missing 02-29 entry")
+	}
+	if feb29.Avg != 40 {
+		t.Fatalf("02-29 avg = %v, want 40", feb29.Avg)
+	}
+
+	if _, err := Build(observed, Options{Window: -1}); err == nil {
+		t.Fatal("expected error for negative window")
+	}
+}
+
+type fakeStore struct {
+	observed map[string]map[string]int
+	configs  map[string]models.PredictorResortConfig
+	saved    map[string]models.PredictorResortConfig
+}
+
+func (f *fakeStore) GetObservedSnowfall(context.Context, string, string) (map[string]map[string]int, error) {
+	return f.observed, nil
+}
+
+func (f *fakeStore) LoadPredictionConfig(context.Context) (map[string]models.PredictorResortConfig, error) {
+	return f.configs, nil
+}
+
+func (f *fakeStore) SavePredictionConfig(_ context.Context, configs map[string]models.PredictorResortConfig) error {
+	f.saved = configs
+	return nil
+}
+
+func TestUpdate_SavesOnlyConfiguredResorts(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeStore{
+		observed: map[string]map[string]int{
+			"resort-1": {"2024-01-15": 10},
+			"resort-2": {"2024-01-15": 20},
+		},
+		configs: map[string]models.PredictorResortConfig{
+			"resort-1": {Name: "One", BiasFactors: map[string]float64{"msm": 1.1}},
+		},
+	}
+
+	built, err := Update(context.Background(), store, Options{})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if len(built) != 2 {
+		t.Fatalf("Update() built %d climatologies, want 2", len(built))
+	}
+	if len(store.saved) != 1 {
+		t.Fatalf("saved %d configs, want 1", len(store.saved))
+	}
+	saved := store.saved["resort-1"]
+	if saved.Climatology["01-15"].Avg != 10 || saved.BiasFactors["msm"] != 1.1 {
+		t.Fatalf("saved config = %+v", saved)
+	}
+}
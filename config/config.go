@@ -1,16 +1,64 @@
 package config
 
-import "os"
+import (
+	"os"
+	"time"
+)
+
+// Defaults applied by Default.
+const (
+	DefaultReadTimeout     = 15 * time.Second
+	DefaultWriteTimeout    = 30 * time.Second
+	DefaultBatchChunkSize  = 500
+	DefaultBusyTimeout     = 5 * time.Second
+	DefaultWALMode         = true
+	DefaultMaxOpenConns    = 4
+	DefaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// MaxBatchChunkSize bounds BatchChunkSize so that chunked IN (...) queries
+// stay well below SQLite's limit of 32766 bound parameters per statement.
+const MaxBatchChunkSize = 10000
 
 // Config contains database configuration shared by both scraper and web projects.
 type Config struct {
 	DatabasePath string
+
+	// ReadTimeout bounds each repository read.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds each repository write; batch writes apply it to
+	// each chunk separately.
+	WriteTimeout time.Duration
+	// BatchChunkSize is the number of records or IDs sent per statement or
+	// transaction by batch reads and writes.
+	BatchChunkSize int
+
+	// BusyTimeout is how long SQLite waits on a locked database before
+	// failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// WALMode enables SQLite's write-ahead log so readers do not block the
+	// writer. It requires a file database.
+	WALMode bool
+	// MaxOpenConns limits the connection pool size.
+	MaxOpenConns int
+	// ConnMaxIdleTime closes pooled connections that have been idle this long.
+	// Zero keeps idle connections open.
+	ConnMaxIdleTime time.Duration
 }
 
 // Default reads configuration values from environment variables.
 // DATABASE_PATH must be set; callers should validate before connecting.
+// The remaining fields are set to their defaults. Load also reads a config
+// file, the other environment variables and command-line flags.
 func Default() Config {
 	return Config{
-		DatabasePath: os.Getenv("DATABASE_PATH"),
+		DatabasePath:    os.Getenv("DATABASE_PATH"),
+		ReadTimeout:     DefaultReadTimeout,
+		WriteTimeout:    DefaultWriteTimeout,
+		BatchChunkSize:  DefaultBatchChunkSize,
+		BusyTimeout:     DefaultBusyTimeout,
+		WALMode:         DefaultWALMode,
+		MaxOpenConns:    DefaultMaxOpenConns,
+		ConnMaxIdleTime: DefaultConnMaxIdleTime,
 	}
 }
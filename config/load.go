@@ -0,0 +1,290 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable holding the optional config
+// file path. The -config flag takes precedence over it.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// field describes one Config field and its names in each configuration source.
+type field struct {
+	key   string // config file key
+	env   string // environment variable
+	usage string
+	bool  bool // registered as a boolean flag
+	set   func(c *Config, value string) error
+	get   func(c Config) string
+}
+
+// flagName returns the command-line flag for f, e.g. "read-timeout".
+func (f field) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+var fields = []field{
+	{
+		key: "database_path", env: "DATABASE_PATH", usage: "path to the SQLite database file",
+		set: func(c *Config, v string) error { c.DatabasePath = v; return nil },
+		get: func(c Config) string { return c.DatabasePath },
+	},
+	durationField("read_timeout", "DATABASE_READ_TIMEOUT", "timeout for each repository read",
+		func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationField("write_timeout", "DATABASE_WRITE_TIMEOUT", "timeout for each repository write",
+		func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	intField("batch_chunk_size", "DATABASE_BATCH_CHUNK_SIZE", "records or IDs per batch statement",
+		func(c *Config) *int { return &c.BatchChunkSize }),
+	durationField("busy_timeout", "DATABASE_BUSY_TIMEOUT", "how long SQLite waits on a locked database",
+		func(c *Config) *time.Duration { return &c.BusyTimeout }),
+	{
+		key: "wal_mode", env: "DATABASE_WAL_MODE", usage: "enable SQLite write-ahead logging", bool: true,
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			c.WALMode = b
+			return nil
+		},
+		get: func(c Config) string { return strconv.FormatBool(c.WALMode) },
+	},
+	intField("max_open_conns", "DATABASE_MAX_OPEN_CONNS", "maximum open database connections",
+		func(c *Config) *int { return &c.MaxOpenConns }),
+	durationField("conn_max_idle_time", "DATABASE_CONN_MAX_IDLE_TIME", "close connections idle this long",
+		func(c *Config) *time.Duration { return &c.ConnMaxIdleTime }),
+}
+
+func durationField(key, env, usage string, ptr func(*Config) *time.Duration) field {
+	return field{
+		key: key, env: env, usage: usage,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q (want e.g. 15s or 2m)", v)
+			}
+			*ptr(c) = d
+			return nil
+		},
+		get: func(c Config) string { return ptr(&c).String() },
+	}
+}
+
+func intField(key, env, usage string, ptr func(*Config) *int) field {
+	return field{
+		key: key, env: env, usage: usage,
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", v)
+			}
+			*ptr(c) = n
+			return nil
+		},
+		get: func(c Config) string { return strconv.Itoa(*ptr(&c)) },
+	}
+}
+
+// Loader merges configuration sources. The zero value reads the process
+// environment and no flags.
+type Loader struct {
+	// Args are the command-line arguments without the program name.
+	Args []string
+	// LookupEnv reads environment variables. Nil means os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+}
+
+// Load is shorthand for Loader{Args: args}.Load().
+func Load(args []string) (Config, error) {
+	return Loader{Args: args}.Load()
+}
+
+// Load builds a Config from, in increasing order of precedence: the defaults,
+// the optional YAML, TOML or JSON file named by -config or CONFIG_FILE,
+// environment variables, and command-line flags. Every problem found while
+// reading the sources or validating the result is reported in one error;
+// the returned Config holds whatever could be applied.
+func (l Loader) Load() (Config, error) {
+	lookupEnv := l.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	flags, configFile, err := parseFlags(l.Args)
+	if err != nil {
+		return Config{}, err
+	}
+	if configFile == "" {
+		configFile, _ = lookupEnv(ConfigFileEnv)
+	}
+
+	cfg := Default()
+	cfg.DatabasePath = ""
+	var errs []error
+
+	if configFile != "" {
+		values, err := readFile(configFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, f := range fields {
+			if v, ok := values[f.key]; ok {
+				if err := f.set(&cfg, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s: %w", configFile, f.key, err))
+				}
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := lookupEnv(f.env); ok && v != "" {
+			if err := f.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := flags[f.flagName()]; ok {
+			if err := f.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.flagName(), err))
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// parseFlags returns the raw values of the flags set in args and the -config
+// value. Values are applied later so that flags override the other sources.
+func parseFlags(args []string) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	values := make(map[string]string)
+	configFile := fs.String("config", "", "optional YAML, TOML or JSON config file (env "+ConfigFileEnv+")")
+	for _, f := range fields {
+		name := f.flagName()
+		usage := fmt.Sprintf("%s (env %s)", f.usage, f.env)
+		store := func(v string) error { values[name] = v; return nil }
+		if f.bool {
+			fs.BoolFunc(name, usage, store)
+		} else {
+			fs.Func(name, usage, store)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", fmt.Errorf("parse flags: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return nil, "", fmt.Errorf("parse flags: unexpected arguments %q", fs.Args())
+	}
+	return values, *configFile, nil
+}
+
+// readFile decodes a flat config file into string values keyed by field key.
+// The format is chosen by extension. Unknown keys are reported as errors.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q (want .yaml, .yml, .toml or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+	}
+	values := make(map[string]string, len(raw))
+	var unknown []string
+	for k, v := range raw {
+		if !known[k] {
+			unknown = append(unknown, k)
+			continue
+		}
+		values[k] = fmt.Sprint(v)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return values, fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+// String formats c as key=value pairs for startup logs. Credentials and
+// query parameters in the database path are redacted.
+func (c Config) String() string {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		v := f.get(c)
+		if f.key == "database_path" {
+			v = redactPath(v)
+		}
+		fmt.Fprintf(&b, "%s=%s", f.key, strconv.Quote(v))
+	}
+	return b.String()
+}
+
+const redacted = "REDACTED"
+
+// redactPath hides the password and query parameter values of a URL-style
+// path, which may carry credentials or encryption keys.
+func redactPath(path string) string {
+	base, query, hasQuery := strings.Cut(path, "?")
+	if u, err := url.Parse(base); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			base = u.String()
+		}
+	}
+	if !hasQuery {
+		return base
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return base + "?" + redacted
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + redacted
+	}
+	return base + "?" + strings.Join(keys, "&")
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func envMap(m map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
+}
+
+func TestLoad_MergesSourcesInPrecedenceOrder(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "snow.db")
+	file := writeFile(t, "config.yaml", "database_path: "+dbPath+"\n"+
+		"read_timeout: 5s\n"+
+		"write_timeout: 10s\n"+
+		"batch_chunk_size: 100\n")
+
+	cfg, err := Loader{
+		Args: []string{"-config", file, "-batch-chunk-size", "50", "-wal-mode=false"},
+		LookupEnv: envMap(map[string]string{
+			"DATABASE_WRITE_TIMEOUT":    "20s",
+			"DATABASE_BATCH_CHUNK_SIZE": "75",
+		}),
+	}.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.DatabasePath != dbPath {
+		t.Errorf("DatabasePath = %q, want %q from file", cfg.DatabasePath, dbPath)
+	}
+	if cfg.ReadTimeout != 5*time.Second {
+		t.Errorf("ReadTimeout = %s, want 5s from file", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != 20*time.Second {
+		t.Errorf("WriteTimeout = %s, want 20s from env", cfg.WriteTimeout)
+	}
+	if cfg.BatchChunkSize != 50 {
+		t.Errorf("BatchChunkSize = %d, want 50 from flags", cfg.BatchChunkSize)
+	}
+	if cfg.WALMode {
+		t.Error("WALMode = true, want false from flags")
+	}
+	if cfg.BusyTimeout != DefaultBusyTimeout || cfg.MaxOpenConns != DefaultMaxOpenConns {
+		t.Errorf("unset fields = %s, want defaults", cfg)
+	}
+}
+
+func TestLoad_FileFormats(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(t.TempDir(), "snow.db")
+	for _, tt := range []struct {
+		name    string
+		content string
+	}{
+		{"config.toml", "database_path = '" + dbPath + "'\nmax_open_conns = 8\nwal_mode = false\n"},
+		{"config.json", `{"database_path": "` + dbPath + `", "max_open_conns": 8, "wal_mode": false}`},
+		{"config.yml", "database_path: " + dbPath + "\nmax_open_conns: 8\nwal_mode: false\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			file := writeFile(t, tt.name, tt.content)
+			cfg, err := Loader{LookupEnv: envMap(map[string]string{ConfigFileEnv: file})}.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.DatabasePath != dbPath || cfg.MaxOpenConns != 8 || cfg.WALMode {
+				t.Fatalf("Load() = %s", cfg)
+			}
+		})
+	}
+}
+
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "config.json", `{"read_timeout": "soon", "colour": "blue"}`)
+	_, err := Loader{
+		Args: []string{"-config", file, "-max-open-conns", "many"},
+		LookupEnv: envMap(map[string]string{
+			"DATABASE_PATH":          filepath.Join(t.TempDir(), "missing", "snow.db"),
+			"DATABASE_WRITE_TIMEOUT": "30",
+		}),
+	}.Load()
+	if err == nil {
+		t.Fatal("Load() expected error")
+	}
+	for _, want := range []string{
+		`read_timeout: invalid duration "soon"`,
+		"unknown keys colour",
+		`DATABASE_WRITE_TIMEOUT: invalid duration "30"`,
+		`-max-open-conns: invalid integer "many"`,
+		"database directory",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %q, missing %q", err, want)
+		}
+	}
+}
+
+func TestLoad_RejectsBadArguments(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) error = %v, want flag.ErrHelp", err)
+	}
+	if _, err := Load([]string{"extra"}); err == nil || !strings.Contains(err.Error(), "unexpected arguments") {
+		t.Errorf("Load(extra) error = %v", err)
+	}
+	file := writeFile(t, "config.ini", "")
+	if _, err := Load([]string{"-config", file}); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Errorf("Load(ini) error = %v", err)
+	}
+}
+
+func TestConfigString_RedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		path string
+		want string
+		leak string
+	}{
+		{"/data/snow.db", `database_path="/data/snow.db"`, ""},
+		{"file:/data/snow.db?_pragma=key('hunter2')", `database_path="file:/data/snow.db?_pragma=REDACTED"`, "hunter2"},
+		{"postgres://app:hunter2@db:5432/snow", `database_path="postgres://app:REDACTED@db:5432/snow"`, "hunter2"},
+	} {
+		cfg := Default()
+		cfg.DatabasePath = tt.path
+		got := cfg.String()
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("String() = %q, want it to contain %q", got, tt.want)
+		}
+		if tt.leak != "" && strings.Contains(got, tt.leak) {
+			t.Errorf("String() = %q leaks %q", got, tt.leak)
+		}
+		if !strings.Contains(got, "read_timeout=\"15s\"") {
+			t.Errorf("String() = %q, want read_timeout", got)
+		}
+	}
+}
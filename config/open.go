@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// Validate reports every invalid field of c, joined into one error. For file
+// databases it also checks that the file or its directory is writable.
+func (c Config) Validate() error {
+	var errs []error
+	if c.DatabasePath == "" {
+		errs = append(errs, errors.New("database path is required (DATABASE_PATH)"))
+	} else if !isMemoryDatabase(c.DatabasePath) {
+		if err := checkWritable(c.DatabasePath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ReadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("read timeout must be positive: %s", c.ReadTimeout))
+	}
+	if c.WriteTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("write timeout must be positive: %s", c.WriteTimeout))
+	}
+	if c.BatchChunkSize < 1 || c.BatchChunkSize > MaxBatchChunkSize {
+		errs = append(errs, fmt.Errorf("batch chunk size must be between 1 and %d: %d", MaxBatchChunkSize, c.BatchChunkSize))
+	}
+	if c.BusyTimeout < 0 {
+		errs = append(errs, fmt.Errorf("busy timeout must not be negative: %s", c.BusyTimeout))
+	}
+	if c.MaxOpenConns < 1 {
+		errs = append(errs, fmt.Errorf("max open connections must be at least 1: %d", c.MaxOpenConns))
+	}
+	if c.ConnMaxIdleTime < 0 {
+		errs = append(errs, fmt.Errorf("connection idle timeout must not be negative: %s", c.ConnMaxIdleTime))
+	}
+	if c.WALMode && isMemoryDatabase(c.DatabasePath) {
+		errs = append(errs, fmt.Errorf("WAL mode requires a file database, not %q", c.DatabasePath))
+	}
+	return errors.Join(errs...)
+}
+
+// DSN returns the modernc.org/sqlite data source name for c, with the busy
+// timeout and journal mode applied to every connection.
+func (c Config) DSN() string {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", c.BusyTimeout.Milliseconds()))
+	if c.WALMode {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	sep := "?"
+	if strings.Contains(c.DatabasePath, "?") {
+		sep = "&"
+	}
+	return c.DatabasePath + sep + params.Encode()
+}
+
+// Open validates cfg and opens its SQLite database with the configured pool
+// limits and pragmas. The connection is verified before Open returns.
+func Open(ctx context.Context, cfg Config) (*sql.DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("open database %q: %w", cfg.DatabasePath, err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxOpenConns)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to database %q: %w", cfg.DatabasePath, err)
+	}
+
+	if cfg.WALMode {
+		var mode string
+		if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read journal mode of %q: %w", cfg.DatabasePath, err)
+		}
+		if !strings.EqualFold(mode, "wal") {
+			db.Close()
+			return nil, fmt.Errorf("enable WAL mode on %q: journal mode is %q", cfg.DatabasePath, mode)
+		}
+	}
+	return db, nil
+}
+
+// checkWritable reports whether the database file at path can be created or
+// opened for writing, without creating it.
+func checkWritable(path string) error {
+	file, _, _ := strings.Cut(strings.TrimPrefix(path, "file:"), "?")
+	if info, err := os.Stat(file); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("database path %q is a directory", file)
+		}
+		f, err := os.OpenFile(file, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("database file %q is not writable: %w", file, err)
+		}
+		return f.Close()
+	}
+
+	dir := filepath.Dir(file)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("database directory %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("database directory %q is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".snowfinder-write-check-*")
+	if err != nil {
+		return fmt.Errorf("database directory %q is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func isMemoryDatabase(path string) bool {
+	return path == ":memory:" || strings.HasPrefix(path, "file::memory:") || strings.Contains(path, "mode=memory")
+}
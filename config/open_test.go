@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		DatabasePath:    ":memory:",
+		ReadTimeout:     -time.Second,
+		BatchChunkSize:  MaxBatchChunkSize + 1,
+		BusyTimeout:     -time.Second,
+		WALMode:         true,
+		ConnMaxIdleTime: -time.Second,
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() expected error")
+	}
+	for _, want := range []string{
+		"read timeout", "write timeout", "batch chunk size", "busy timeout",
+		"max open connections", "connection idle timeout", "WAL mode",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error = %q, missing %q", err, want)
+		}
+	}
+
+	cfg = Default()
+	cfg.DatabasePath = filepath.Join(t.TempDir(), "snow.db")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate(defaults) error = %v", err)
+	}
+}
+
+func TestOpen_ConfiguresDatabase(t *testing.T) {
+	t.Parallel()
+
+	cfg := Default()
+	cfg.DatabasePath = filepath.Join(t.TempDir(), "snow.db")
+	cfg.BusyTimeout = 1234 * time.Millisecond
+	cfg.MaxOpenConns = 3
+
+	ctx := context.Background()
+	db, err := Open(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+	var mode string
+	if err := db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v; want wal", mode, err)
+	}
+	var busy int
+	if err := db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busy); err != nil || busy != 1234 {
+		t.Errorf("busy_timeout = %d, %v; want 1234", busy, err)
+	}
+}
+
+func TestOpen_ReportsErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	if _, err := Open(ctx, Config{}); err == nil || !strings.Contains(err.Error(), "invalid database config") {
+		t.Fatalf("Open(zero) error = %v, want invalid config", err)
+	}
+
+	cfg := Default()
+	dir := filepath.Join(t.TempDir(), "missing")
+	cfg.DatabasePath = filepath.Join(dir, "snow.db")
+	if _, err := Open(ctx, cfg); err == nil || !strings.Contains(err.Error(), dir) {
+		t.Fatalf("Open(missing dir) error = %v, want error naming the directory", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Package dialect describes the SQL differences between the storage backends
+// supported by the repository and migrations packages.
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies a SQL backend. The zero value is SQLite.
+type Dialect int
+
+const (
+	// SQLite is the default backend (modernc.org/sqlite).
+	SQLite Dialect = iota
+	// Postgres is PostgreSQL, e.g. via github.com/jackc/pgx/v5/stdlib.
+	Postgres
+)
+
+// String returns the dialect name as accepted by Parse.
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	default:
+		return fmt.Sprintf("dialect(%d)", int(d))
+	}
+}
+
+// Parse returns the dialect for a name such as "sqlite", "postgres" or a
+// database/sql driver name ("pgx", "postgresql").
+func Parse(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "sqlite", "sqlite3":
+		return SQLite, nil
+	case "postgres", "postgresql", "pgx":
+		return Postgres, nil
+	default:
+		return 0, fmt.Errorf("unknown SQL dialect %q", name)
+	}
+}
+
+// Rebind converts "?" bind placeholders to the dialect's form. Postgres uses
+// numbered "$1, $2, ..." placeholders; "?" inside single-quoted string
+// literals is left untouched.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+			b.WriteByte(c)
+		case c == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// DateString returns an expression formatting the date column col as "YYYY-MM-DD".
+func (d Dialect) DateString(col string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(%s, 'YYYY-MM-DD')", col)
+	}
+	return fmt.Sprintf("substr(%s, 1, 10)", col)
+}
+
+// MonthDay returns an expression formatting the date column col as "MM-DD".
+func (d Dialect) MonthDay(col string) string {
+	if d == Postgres {
+		return fmt.Sprintf("to_char(%s, 'MM-DD')", col)
+	}
+	return fmt.Sprintf("substr(%s, 6, 5)", col)
+}
+
+// Year returns an integer expression for the calendar year of col.
+func (d Dialect) Year(col string) string {
+	return d.datePart(col, "%Y", "YEAR")
+}
+
+// Month returns an integer expression for the month (1-12) of col.
+func (d Dialect) Month(col string) string {
+	return d.datePart(col, "%m", "MONTH")
+}
+
+// DayOfYear returns an integer expression for the day of year (1-366) of col.
+func (d Dialect) DayOfYear(col string) string {
+	return d.datePart(col, "%j", "DOY")
+}
+
+func (d Dialect) datePart(col, sqliteFormat, postgresField string) string {
+	if d == Postgres {
+		return fmt.Sprintf("CAST(EXTRACT(%s FROM %s) AS INTEGER)", postgresField, col)
+	}
+	// substr tolerates dates stored with a time suffix
+	return fmt.Sprintf("CAST(strftime('%s', substr(%s, 1, 19)) AS INTEGER)", sqliteFormat, col)
+}
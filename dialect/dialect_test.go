@@ -0,0 +1,36 @@
+package dialect
+
+import "testing"
+
+func TestRebind(t *testing.T) {
+	t.Parallel()
+
+	query := "SELECT * FROM t WHERE a = ? AND b IN (?, ?) AND c = '?'"
+	if got := SQLite.Rebind(query); got != query {
+		t.Fatalf("SQLite.Rebind() = %q, want unchanged", got)
+	}
+	want := "SELECT * FROM t WHERE a = $1 AND b IN ($2, $3) AND c = '?'"
+	if got := Postgres.Rebind(query); got != want {
+		t.Fatalf("Postgres.Rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]Dialect{
+		"":           SQLite,
+		"sqlite":     SQLite,
+		"Postgres":   Postgres,
+		"postgresql": Postgres,
+		"pgx":        Postgres,
+	} {
+		got, err := Parse(name)
+		if err != nil || got != want {
+			t.Fatalf("Parse(%q) = (%v, %v), want %v", name, got, err, want)
+		}
+	}
+	if _, err := Parse("mysql"); err == nil {
+		t.Fatal("Parse(\"mysql\") expected error")
+	}
+}
@@ -0,0 +1,149 @@
+// Package geo holds the coordinate validation, distance and bounding-box
+// logic shared by the repository package and the in-memory repositorytest
+// fake, so both answer geographic queries identically.
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// EarthRadiusKM is the mean Earth radius used for haversine distances.
+const EarthRadiusKM = 6371.0
+
+// kmPerDegreeLat is the length of one degree of latitude.
+const kmPerDegreeLat = EarthRadiusKM * math.Pi / 180
+
+// HaversineKM returns the great-circle distance in kilometres between two
+// points given in decimal degrees.
+func HaversineKM(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusKM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ValidateCoordinates checks that lat and lon are decimal degrees within
+// [-90, 90] and [-180, 180].
+func ValidateCoordinates(lat, lon float64) error {
+	if math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude out of range [-90, 90]: %v", lat)
+	}
+	if math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return fmt.Errorf("longitude out of range [-180, 180]: %v", lon)
+	}
+	return nil
+}
+
+// ValidateResort requires a resort to have both coordinates or neither,
+// within range. SaveResort rejects resorts that fail it.
+func ValidateResort(resort *models.Resort) error {
+	if (resort.Latitude == nil) != (resort.Longitude == nil) {
+		return fmt.Errorf("resort %q must have both latitude and longitude or neither", resort.Slug)
+	}
+	if resort.Latitude == nil {
+		return nil
+	}
+	return ValidateCoordinates(*resort.Latitude, *resort.Longitude)
+}
+
+// Box is a latitude/longitude box. When Wraps is set the box crosses the
+// antimeridian and spans MinLon..180 and -180..MaxLon; when AllLon is set it
+// spans every longitude.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+	Wraps          bool
+	AllLon         bool
+}
+
+// NewBox validates the corners of a bounding box. A box with minLon greater
+// than maxLon crosses the antimeridian.
+func NewBox(minLat, minLon, maxLat, maxLon float64) (Box, error) {
+	if err := ValidateCoordinates(minLat, minLon); err != nil {
+		return Box{}, fmt.Errorf("bounding box: %w", err)
+	}
+	if err := ValidateCoordinates(maxLat, maxLon); err != nil {
+		return Box{}, fmt.Errorf("bounding box: %w", err)
+	}
+	if minLat > maxLat {
+		return Box{}, fmt.Errorf("bounding box: min latitude %v exceeds max latitude %v", minLat, maxLat)
+	}
+	return Box{MinLat: minLat, MaxLat: maxLat, MinLon: minLon, MaxLon: maxLon, Wraps: minLon > maxLon}, nil
+}
+
+// Contains reports whether the point lat/lon lies inside b.
+func (b Box) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	switch {
+	case b.AllLon:
+		return true
+	case b.Wraps:
+		return lon >= b.MinLon || lon <= b.MaxLon
+	default:
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+}
+
+// AroundPoint returns the smallest box containing every point within
+// radiusKM of lat/lon.
+func AroundPoint(lat, lon, radiusKM float64) Box {
+	// The padding keeps points exactly on the circle inside despite rounding.
+	dLat := radiusKM/kmPerDegreeLat + 1e-9
+	b := Box{MinLat: math.Max(lat-dLat, -90), MaxLat: math.Min(lat+dLat, 90)}
+	// Meridians converge, so the widest part of the box is at the latitude
+	// furthest from the equator.
+	cosLat := math.Cos(math.Max(math.Abs(b.MinLat), math.Abs(b.MaxLat)) * math.Pi / 180)
+	if b.MinLat <= -90 || b.MaxLat >= 90 || cosLat <= 0 {
+		b.AllLon = true
+		return b
+	}
+	dLon := dLat / cosLat
+	if dLon >= 180 {
+		b.AllLon = true
+		return b
+	}
+	b.MinLon, b.MaxLon = lon-dLon, lon+dLon
+	if b.MinLon < -180 {
+		b.MinLon += 360
+		b.Wraps = true
+	}
+	if b.MaxLon > 180 {
+		b.MaxLon -= 360
+		b.Wraps = true
+	}
+	return b
+}
+
+// Nearest returns up to limit of resorts within radiusKM of lat/lon, nearest
+// first with ties broken by name, skipping resorts without coordinates.
+func Nearest(resorts []models.Resort, lat, lon, radiusKM float64, limit int) []models.ResortDistance {
+	results := []models.ResortDistance{}
+	for _, resort := range resorts {
+		if resort.Latitude == nil || resort.Longitude == nil {
+			continue
+		}
+		d := HaversineKM(lat, lon, *resort.Latitude, *resort.Longitude)
+		if d <= radiusKM {
+			results = append(results, models.ResortDistance{Resort: resort, DistanceKM: d})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].DistanceKM != results[j].DistanceKM {
+			return results[i].DistanceKM < results[j].DistanceKM
+		}
+		return strings.Compare(results[i].Resort.Name, results[j].Resort.Name) < 0
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
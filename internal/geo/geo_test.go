@@ -0,0 +1,53 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversineKM(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 36.7, 137.8, 36.7, 137.8, 0},
+		{"one degree of latitude", 0, 0, 1, 0, kmPerDegreeLat},
+		{"tokyo to sapporo", 35.6812, 139.7671, 43.0687, 141.3508, 832.6},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, kmPerDegreeLat},
+		{"antipodes", 0, 0, 0, 180, math.Pi * EarthRadiusKM},
+	} {
+		if got := HaversineKM(tt.lat1, tt.lon1, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 1 {
+			t.Errorf("%s: HaversineKM() = %.1f, want %.1f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAroundPoint_ContainsRadius(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		lat, lon, radius float64
+	}{
+		{36.7, 137.8, 50},
+		{43, 141, 300},
+		{-17.7, 179.9, 100},
+		{89.9, 0, 50},
+	} {
+		b := AroundPoint(tt.lat, tt.lon, tt.radius)
+		// Walk the circle and check every point lies in the box.
+		for deg := 0.0; deg < 360; deg += 5 {
+			bearing := deg * math.Pi / 180
+			d := tt.radius / EarthRadiusKM
+			lat1, lon1 := tt.lat*math.Pi/180, tt.lon*math.Pi/180
+			lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(bearing))
+			lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+			plat, plon := lat2*180/math.Pi, math.Remainder(lon2*180/math.Pi, 360)
+			if !b.Contains(plat, plon) {
+				t.Fatalf("AroundPoint(%v, %v, %v) = %+v misses %.4f, %.4f", tt.lat, tt.lon, tt.radius, b, plat, plon)
+			}
+		}
+	}
+}
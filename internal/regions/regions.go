@@ -0,0 +1,91 @@
+// Package regions holds the region grouping and snowfall summaries shared by
+// the repository package and the in-memory repositorytest fake, so both group
+// and rank resorts identically.
+package regions
+
+import (
+	"sort"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// Key identifies a group of resorts. Region is empty when grouping by
+// prefecture.
+type Key struct {
+	Prefecture, Region string
+}
+
+// NewKey returns the group of a resort in prefecture and region, keeping the
+// region only when byRegion is set.
+func NewKey(prefecture, region string, byRegion bool) Key {
+	if !byRegion {
+		region = ""
+	}
+	return Key{Prefecture: prefecture, Region: region}
+}
+
+// SortedKeys returns the keys of groups ordered by prefecture and region.
+func SortedKeys[T any](groups map[Key]T) []Key {
+	keys := make([]Key, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Prefecture != keys[j].Prefecture {
+			return keys[i].Prefecture < keys[j].Prefecture
+		}
+		return keys[i].Region < keys[j].Region
+	})
+	return keys
+}
+
+// BetterTopResort reports whether a resort with snowfallCM beats top, breaking
+// ties by name and then ID.
+func BetterTopResort(top *models.RegionTopResort, id, name string, snowfallCM float64) bool {
+	switch {
+	case top == nil:
+		return true
+	case snowfallCM != top.SnowfallCM:
+		return snowfallCM > top.SnowfallCM
+	case name != top.Name:
+		return name < top.Name
+	default:
+		return id < top.ResortID
+	}
+}
+
+// Summarize groups resorts by prefecture, or by region within each prefecture
+// when byRegion is set, and aggregates their snowfall totals, keyed by resort
+// ID. Resorts missing from totals count towards ResortCount only.
+func Summarize(resorts []models.Resort, totals map[string]int, byRegion bool) []models.RegionSummary {
+	groups := make(map[Key]*models.RegionSummary)
+	for _, resort := range resorts {
+		key := NewKey(resort.Prefecture, resort.Region, byRegion)
+		summary := groups[key]
+		if summary == nil {
+			summary = &models.RegionSummary{Prefecture: key.Prefecture, Region: key.Region}
+			groups[key] = summary
+		}
+		summary.ResortCount++
+
+		total, ok := totals[resort.ID]
+		if !ok {
+			continue
+		}
+		summary.ReportingResorts++
+		summary.TotalSnowfallCM += total
+		if BetterTopResort(summary.TopResort, resort.ID, resort.Name, float64(total)) {
+			summary.TopResort = &models.RegionTopResort{ResortID: resort.ID, Name: resort.Name, SnowfallCM: float64(total)}
+		}
+	}
+
+	summaries := []models.RegionSummary{}
+	for _, key := range SortedKeys(groups) {
+		summary := groups[key]
+		if summary.ReportingResorts > 0 {
+			summary.AvgSnowfallCM = float64(summary.TotalSnowfallCM) / float64(summary.ReportingResorts)
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries
+}
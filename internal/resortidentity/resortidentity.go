@@ -0,0 +1,131 @@
+// Package resortidentity resolves the ID and slug a resort is persisted under,
+// shared by the repository package and the in-memory repositorytest fake so
+// that both disambiguate colliding slugs identically.
+package resortidentity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// Record is the identity of a stored resort.
+type Record struct {
+	ID         string
+	Slug       string
+	Name       string
+	Prefecture string
+	Region     string
+}
+
+func sameIdentity(a, b *Record) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	return normalizeIdentityPart(a.Prefecture) == normalizeIdentityPart(b.Prefecture) &&
+		normalizeIdentityPart(a.Region) == normalizeIdentityPart(b.Region)
+}
+
+func fromModel(resort *models.Resort) *Record {
+	if resort == nil {
+		return nil
+	}
+
+	return &Record{
+		ID:         resort.ID,
+		Slug:       resort.Slug,
+		Name:       resort.Name,
+		Prefecture: resort.Prefecture,
+		Region:     resort.Region,
+	}
+}
+
+func normalizeIdentityPart(value string) string {
+	return strings.TrimSpace(strings.ToLower(value))
+}
+
+// ScopedSlug returns slug qualified by the normalized prefecture and region,
+// e.g. "mount-foo--nagano--north".
+func ScopedSlug(slug, prefecture, region string) string {
+	parts := []string{strings.TrimSpace(slug)}
+
+	if normalizedPrefecture := normalizeIdentityPart(prefecture); normalizedPrefecture != "" {
+		parts = append(parts, normalizedPrefecture)
+	}
+
+	if normalizedRegion := normalizeIdentityPart(region); normalizedRegion != "" {
+		parts = append(parts, normalizedRegion)
+	}
+
+	return strings.Join(parts, "--")
+}
+
+func resolve(resort *models.Resort, existingBySlug, existingByScopedSlug *Record) *Record {
+	current := fromModel(resort)
+
+	if sameIdentity(existingBySlug, current) {
+		return &Record{ID: existingBySlug.ID, Slug: existingBySlug.Slug}
+	}
+
+	if sameIdentity(existingByScopedSlug, current) {
+		return &Record{ID: existingByScopedSlug.ID, Slug: existingByScopedSlug.Slug}
+	}
+
+	if existingBySlug != nil && existingByScopedSlug != nil {
+		return nil
+	}
+
+	if existingBySlug != nil {
+		return &Record{Slug: ScopedSlug(resort.Slug, resort.Prefecture, resort.Region)}
+	}
+
+	return &Record{Slug: resort.Slug}
+}
+
+// Resolve returns the identity resort is persisted under, given the stored
+// resorts holding its slug and its scoped slug, either of which may be nil.
+// The ID is empty when resort is new. An error is returned if both slugs are
+// taken by other resorts.
+func Resolve(resort *models.Resort, existingBySlug, existingByScopedSlug *Record) (*Record, error) {
+	record := resolve(resort, existingBySlug, existingByScopedSlug)
+	if record != nil {
+		return record, nil
+	}
+
+	return nil, fmt.Errorf(
+		"slug collision: cannot disambiguate %q for resort %q (prefecture %q) from existing resort %q",
+		resort.Slug,
+		resort.Name,
+		resort.Prefecture,
+		existingBySlug.Name,
+	)
+}
+
+// ResolveSlug returns the ID and slug SaveResort persists resort under.
+// lookup returns the stored resort with the given slug, or nil if there is none.
+// The ID is empty when resort is new. A slug already taken by a resort in
+// another prefecture or region is scoped ("slug--prefecture--region"); an
+// error is returned if the scoped slug is taken as well.
+func ResolveSlug(resort *models.Resort, lookup func(slug string) (*models.Resort, error)) (id, slug string, err error) {
+	existingBySlug, err := lookup(resort.Slug)
+	if err != nil {
+		return "", "", err
+	}
+
+	var existingByScopedSlug *models.Resort
+	if scopedSlug := ScopedSlug(resort.Slug, resort.Prefecture, resort.Region); scopedSlug != resort.Slug {
+		existingByScopedSlug, err = lookup(scopedSlug)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	record, err := Resolve(resort,
+		fromModel(existingBySlug), fromModel(existingByScopedSlug))
+	if err != nil {
+		return "", "", err
+	}
+	return record.ID, record.Slug, nil
+}
@@ -1,4 +1,4 @@
-package repository
+package resortidentity
 
 import (
 	"testing"
@@ -9,9 +9,9 @@ import (
 func TestScopedResortSlug(t *testing.T) {
 	t.Parallel()
 
-	got := scopedResortSlug("mount-foo", " Nagano ", "Shiga Kogen")
+	got := ScopedSlug("mount-foo", " Nagano ", "Shiga Kogen")
 	if got != "mount-foo--nagano--shiga kogen" {
-		t.Fatalf("scopedResortSlug() = %q", got)
+		t.Fatalf("ScopedSlug() = %q", got)
 	}
 }
 
@@ -19,11 +19,11 @@ func TestResolvePersistedResortRecord_UsesExistingSlugForSameIdentity(t *testing
 	t.Parallel()
 
 	resort := &models.Resort{Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
-	existing := &resortIdentityRecord{ID: "resort-1", Slug: "mount-foo", Prefecture: "Nagano", Region: "North"}
+	existing := &Record{ID: "resort-1", Slug: "mount-foo", Prefecture: "Nagano", Region: "North"}
 
-	got := resolvePersistedResortRecord(resort, existing, nil)
+	got := resolve(resort, existing, nil)
 	if got.ID != "resort-1" || got.Slug != "mount-foo" {
-		t.Fatalf("resolvePersistedResortRecord() = %+v", got)
+		t.Fatalf("resolve() = %+v", got)
 	}
 }
 
@@ -31,14 +31,14 @@ func TestResolvePersistedResortRecord_ScopesSlugOnCollision(t *testing.T) {
 	t.Parallel()
 
 	resort := &models.Resort{Slug: "mount-foo", Prefecture: "gifu", Region: "west"}
-	existing := &resortIdentityRecord{ID: "resort-1", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
+	existing := &Record{ID: "resort-1", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
 
-	got := resolvePersistedResortRecord(resort, existing, nil)
+	got := resolve(resort, existing, nil)
 	if got.ID != "" {
 		t.Fatalf("expected new record, got id %q", got.ID)
 	}
 	if got.Slug != "mount-foo--gifu--west" {
-		t.Fatalf("resolvePersistedResortRecord() slug = %q", got.Slug)
+		t.Fatalf("resolve() slug = %q", got.Slug)
 	}
 }
 
@@ -46,12 +46,12 @@ func TestResolvePersistedResortRecord_ReusesExistingScopedSlug(t *testing.T) {
 	t.Parallel()
 
 	resort := &models.Resort{Slug: "mount-foo", Prefecture: "gifu", Region: "west"}
-	existing := &resortIdentityRecord{ID: "resort-1", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
-	existingScoped := &resortIdentityRecord{ID: "resort-2", Slug: "mount-foo--gifu--west", Prefecture: "gifu", Region: "west"}
+	existing := &Record{ID: "resort-1", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
+	existingScoped := &Record{ID: "resort-2", Slug: "mount-foo--gifu--west", Prefecture: "gifu", Region: "west"}
 
-	got := resolvePersistedResortRecord(resort, existing, existingScoped)
+	got := resolve(resort, existing, existingScoped)
 	if got.ID != "resort-2" || got.Slug != "mount-foo--gifu--west" {
-		t.Fatalf("resolvePersistedResortRecord() = %+v", got)
+		t.Fatalf("resolve() = %+v", got)
 	}
 }
 
@@ -59,10 +59,10 @@ func TestResolvePersistedResortRecordOrError_ReturnsErrorForUnrelatedScopedColli
 	t.Parallel()
 
 	resort := &models.Resort{Name: "New Resort", Slug: "mount-foo", Prefecture: "gifu", Region: "west"}
-	existing := &resortIdentityRecord{ID: "resort-1", Name: "Existing Resort", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
-	existingScoped := &resortIdentityRecord{ID: "resort-2", Name: "Other Resort", Slug: "mount-foo--gifu--west", Prefecture: "gifu", Region: "south"}
+	existing := &Record{ID: "resort-1", Name: "Existing Resort", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
+	existingScoped := &Record{ID: "resort-2", Name: "Other Resort", Slug: "mount-foo--gifu--west", Prefecture: "gifu", Region: "south"}
 
-	got, err := resolvePersistedResortRecordOrError(resort, existing, existingScoped)
+	got, err := Resolve(resort, existing, existingScoped)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -75,10 +75,10 @@ func TestResolvePersistedResortRecordOrError_ReturnsErrorForSlugCollisionAcrossP
 	t.Parallel()
 
 	resort := &models.Resort{Name: "Second Resort", Slug: "mount-foo", Prefecture: "gifu", Region: "west"}
-	existing := &resortIdentityRecord{ID: "resort-1", Name: "First Resort", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
-	existingScoped := &resortIdentityRecord{ID: "resort-2", Name: "Scoped Resort", Slug: "mount-foo--gifu--west", Prefecture: "toyama", Region: "west"}
+	existing := &Record{ID: "resort-1", Name: "First Resort", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
+	existingScoped := &Record{ID: "resort-2", Name: "Scoped Resort", Slug: "mount-foo--gifu--west", Prefecture: "toyama", Region: "west"}
 
-	got, err := resolvePersistedResortRecordOrError(resort, existing, existingScoped)
+	got, err := Resolve(resort, existing, existingScoped)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -92,12 +92,12 @@ func TestResolvePersistedResortRecord_EmptySlug(t *testing.T) {
 
 	resort := &models.Resort{Name: "Nameless Slug Resort", Slug: "", Prefecture: "nagano", Region: "north"}
 
-	got := resolvePersistedResortRecord(resort, nil, nil)
+	got := resolve(resort, nil, nil)
 	if got == nil {
 		t.Fatal("expected record")
 	}
 	if got.Slug != "" {
-		t.Fatalf("resolvePersistedResortRecord() slug = %q, want empty", got.Slug)
+		t.Fatalf("resolve() slug = %q, want empty", got.Slug)
 	}
 }
 
@@ -105,13 +105,13 @@ func TestResolvePersistedResortRecord_EmptyName(t *testing.T) {
 	t.Parallel()
 
 	resort := &models.Resort{Name: "", Slug: "mount-foo", Prefecture: "nagano", Region: "north"}
-	existing := &resortIdentityRecord{ID: "resort-1", Name: "Existing Resort", Slug: "mount-foo", Prefecture: "Nagano", Region: "North"}
+	existing := &Record{ID: "resort-1", Name: "Existing Resort", Slug: "mount-foo", Prefecture: "Nagano", Region: "North"}
 
-	got := resolvePersistedResortRecord(resort, existing, nil)
+	got := resolve(resort, existing, nil)
 	if got == nil {
 		t.Fatal("expected record")
 	}
 	if got.ID != "resort-1" || got.Slug != "mount-foo" {
-		t.Fatalf("resolvePersistedResortRecord() = %+v", got)
+		t.Fatalf("resolve() = %+v", got)
 	}
 }
@@ -0,0 +1,158 @@
+package resortsearch
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// NameMatches reports whether name contains query, ignoring case,
+// full/half-width forms, spacing and punctuation, and the difference between
+// kana and romaji spellings. For example "Hakuba Happo-One" matches "happou",
+// "ハッポウ" and "はっぽー". Kanji are matched literally.
+func NameMatches(name, query string) bool {
+	q := strings.TrimSpace(query)
+	if q == "" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(name), strings.ToLower(q)) {
+		return true
+	}
+	folded := foldSearchText(q)
+	return folded != "" && strings.Contains(foldSearchText(name), folded)
+}
+
+// foldSearchText reduces s to a canonical romaji-like form: kana become
+// Hepburn romaji, Hepburn and Kunrei spellings are unified, macrons and
+// long vowels are collapsed, and everything but letters and digits is removed.
+func foldSearchText(s string) string {
+	s = strings.ToLower(width.Fold.String(s))
+	s = kanaToRomaji(s)
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	s = romajiCanonical.Replace(b.String())
+	return collapseVowels(s)
+}
+
+// romajiCanonical maps Hepburn spellings onto Kunrei ones, which have a
+// single spelling per kana, and the Hepburn "m" before labials back to "n".
+var romajiCanonical = strings.NewReplacer(
+	"shi", "si", "chi", "ti", "tsu", "tu",
+	"sh", "sy", "ch", "ty", "ji", "zi", "j", "zy", "fu", "hu",
+	"mb", "nb", "mp", "np",
+)
+
+// collapseVowels shortens long vowels, so "ou", "oo" and "o" (from "ō") all
+// read the same.
+func collapseVowels(s string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range s {
+		if isVowel(r) && (r == prev || (prev == 'o' && r == 'u')) {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+func isVowel(r rune) bool {
+	return strings.ContainsRune("aeiou", r)
+}
+
+var hiraganaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+}
+
+var smallKana = map[rune]string{
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa",
+}
+
+// kanaToRomaji transliterates hiragana and katakana in s to Hepburn romaji
+// and leaves every other rune unchanged.
+func kanaToRomaji(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		// Katakana sit 0x60 code points above their hiragana.
+		if r >= 'ァ' && r <= 'ヶ' {
+			runes[i] = r - 0x60
+		}
+	}
+
+	var out []string
+	geminate := false
+	for _, r := range runes {
+		var syllable string
+		switch {
+		case r == 'っ':
+			geminate = true
+			continue
+		case r == 'ー':
+			// Long vowels are collapsed later anyway.
+			continue
+		case smallKana[r] != "":
+			syllable = smallKana[r]
+			if n := len(out); n > 0 {
+				out[n-1], syllable = combineSmallKana(out[n-1], syllable)
+			}
+		case hiraganaRomaji[r] != "":
+			syllable = hiraganaRomaji[r]
+		default:
+			syllable = string(r)
+		}
+		if geminate {
+			geminate = false
+			if strings.HasPrefix(syllable, "ch") {
+				syllable = "t" + syllable
+			} else if c := syllable[0]; c < 0x80 && !isVowel(rune(c)) {
+				syllable = string(c) + syllable
+			}
+		}
+		out = append(out, syllable)
+	}
+	return strings.Join(out, "")
+}
+
+// combineSmallKana merges a small kana into the preceding syllable, e.g.
+// "ki"+"ya" becomes "kya", "shi"+"ya" becomes "sha" and "fu"+"a" becomes "fa".
+func combineSmallKana(prev, small string) (string, string) {
+	if prev == "" || !isVowel(rune(prev[len(prev)-1])) {
+		return prev, small
+	}
+	stem := prev[:len(prev)-1]
+	switch {
+	case strings.HasPrefix(small, "y") && strings.HasSuffix(prev, "i") && len(prev) > 1:
+		if strings.HasSuffix(stem, "sh") || strings.HasSuffix(stem, "ch") || strings.HasSuffix(stem, "j") {
+			return "", stem + small[1:]
+		}
+		return "", stem + small
+	case len(small) == 1 && prev == "u":
+		return "", "w" + small
+	case len(small) == 1 && len(prev) > 1:
+		return "", stem + small
+	}
+	return prev, small
+}
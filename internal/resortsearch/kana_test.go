@@ -0,0 +1,50 @@
+package resortsearch
+
+import "testing"
+
+func TestFoldSearchText(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"Hakuba Happo-One", "hakubahappone"},
+		{"はっぽうおね", "happone"},
+		{"ハッポー", "happo"},
+		{"ｎｉｓｅｋｏ", "niseko"},
+		{"ﾆｾｺ", "niseko"},
+		{"しゃ", "sya"},
+		{"Shiga Kōgen", "sigakogen"},
+		{"つがいけ", "tugaike"},
+		{"まっちゃ", "mattya"},
+		{"Shimbashi", "sinbasi"},
+		{"ふぁ", "fa"},
+		{"白馬", "白馬"},
+	} {
+		if got := foldSearchText(tt.in); got != tt.want {
+			t.Errorf("foldSearchText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNameMatches(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name, query string
+		want        bool
+	}{
+		{"Hakuba Happo-One", "", true},
+		{"Hakuba Happo-One", "happou", true},
+		{"Hakuba Happo-One", "ハッポウ", true},
+		{"ニセコ グラン・ヒラフ", "hirafu", true},
+		{"Niseko", "にせこ", true},
+		{"白馬八方尾根", "八方", true},
+		{"Tsugaike Kogen", "tugaike", true},
+		{"Hakuba Goryu", "happo", false},
+	} {
+		if got := NameMatches(tt.name, tt.query); got != tt.want {
+			t.Errorf("NameMatches(%q, %q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
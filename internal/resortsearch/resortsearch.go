@@ -0,0 +1,302 @@
+// Package resortsearch holds the filtering, ordering and cursor pagination of
+// resort searches, shared by the repository package and the in-memory
+// repositorytest fake so that both page through resorts identically.
+package resortsearch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// ErrInvalidQuery is returned (wrapped) for malformed queries, including
+// cursors that do not belong to the query's sort order.
+var ErrInvalidQuery = errors.New("invalid resort query")
+
+// Page sizes of a Query.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Sort selects the order of search results. Resorts with an unknown value for
+// the sort column are always listed last.
+type Sort int
+
+const (
+	SortByName Sort = iota
+	SortByTopElevation
+	SortByBaseElevation
+	SortByVertical
+	SortByNumCourses
+	SortByLongestCourse
+	SortBySteepestCourse
+)
+
+// Key describes the column a Sort orders by.
+type Key struct {
+	Name   string
+	Column string
+	// Value returns the resort's sort value, or nil when it is unknown. It
+	// is nil for SortByName.
+	Value   func(r models.Resort) *float64
+	Integer bool
+}
+
+func intValue(p *int) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := float64(*p)
+	return &v
+}
+
+var sortKeys = map[Sort]Key{
+	SortByName: {Name: "name", Column: "name"},
+	SortByTopElevation: {Name: "top_elevation", Column: "top_elevation_m", Integer: true,
+		Value: func(r models.Resort) *float64 { return intValue(r.TopElevationM) }},
+	SortByBaseElevation: {Name: "base_elevation", Column: "base_elevation_m", Integer: true,
+		Value: func(r models.Resort) *float64 { return intValue(r.BaseElevationM) }},
+	SortByVertical: {Name: "vertical", Column: "vertical_m", Integer: true,
+		Value: func(r models.Resort) *float64 { return intValue(r.VerticalM) }},
+	SortByNumCourses: {Name: "num_courses", Column: "num_courses", Integer: true,
+		Value: func(r models.Resort) *float64 { return intValue(r.NumCourses) }},
+	SortByLongestCourse: {Name: "longest_course", Column: "longest_course_km",
+		Value: func(r models.Resort) *float64 { return r.LongestCourseKM }},
+	SortBySteepestCourse: {Name: "steepest_course", Column: "steepest_course_deg",
+		Value: func(r models.Resort) *float64 { return r.SteepestCourseDeg }},
+}
+
+// String returns the sort name accepted by ParseSort, e.g. "vertical".
+func (s Sort) String() string {
+	if key, ok := sortKeys[s]; ok {
+		return key.Name
+	}
+	return fmt.Sprintf("ResortSort(%d)", int(s))
+}
+
+// ParseSort returns the Sort named name. The empty string selects SortByName.
+func ParseSort(name string) (Sort, error) {
+	if name == "" {
+		return SortByName, nil
+	}
+	for s, key := range sortKeys {
+		if key.Name == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown sort %q", ErrInvalidQuery, name)
+}
+
+// NullSortValue stands in for unknown values so that they sort last in either
+// direction. It is larger than any elevation, course count or angle.
+const NullSortValue = 1_000_000_000
+
+// IntRange is an inclusive range; a zero bound is unset.
+type IntRange struct {
+	Min, Max int
+}
+
+// FloatRange is an inclusive range; a zero bound is unset.
+type FloatRange struct {
+	Min, Max float64
+}
+
+// Query filters, orders and pages a resort search. Zero fields do not filter.
+// Range filters exclude resorts whose value is unknown.
+type Query struct {
+	// Name matches a substring of the resort name; see NameMatches.
+	Name       string
+	Prefecture string
+	Region     string
+
+	TopElevationM     IntRange
+	BaseElevationM    IntRange
+	VerticalM         IntRange
+	NumCourses        IntRange
+	SteepestCourseDeg FloatRange
+
+	Sort       Sort
+	Descending bool
+
+	// Limit is the page size; zero means DefaultPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first.
+	Cursor string
+}
+
+// Page is one page of search results.
+type Page struct {
+	Resorts []models.Resort `json:"resorts"`
+	// NextCursor fetches the following page; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Cursor is the position after the last resort of a page.
+type Cursor struct {
+	Sort Sort    `json:"s"`
+	Desc bool    `json:"d,omitempty"`
+	Key  float64 `json:"k,omitempty"`
+	Name string  `json:"n"`
+	ID   string  `json:"i"`
+}
+
+func (c Cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Prepare validates q, applies defaults and decodes its cursor, which is nil
+// for the first page.
+func (q Query) Prepare() (Query, Key, *Cursor, error) {
+	key, ok := sortKeys[q.Sort]
+	if !ok {
+		return q, key, nil, fmt.Errorf("%w: unknown sort %d", ErrInvalidQuery, int(q.Sort))
+	}
+	switch {
+	case q.Limit == 0:
+		q.Limit = DefaultPageSize
+	case q.Limit < 0 || q.Limit > MaxPageSize:
+		return q, key, nil, fmt.Errorf("%w: limit must be between 1 and %d: %d", ErrInvalidQuery, MaxPageSize, q.Limit)
+	}
+	for name, r := range map[string]IntRange{
+		"top elevation": q.TopElevationM, "base elevation": q.BaseElevationM,
+		"vertical": q.VerticalM, "number of courses": q.NumCourses,
+	} {
+		if r.Max != 0 && r.Min > r.Max {
+			return q, key, nil, fmt.Errorf("%w: %s range min %d exceeds max %d", ErrInvalidQuery, name, r.Min, r.Max)
+		}
+	}
+	if r := q.SteepestCourseDeg; r.Max != 0 && r.Min > r.Max {
+		return q, key, nil, fmt.Errorf("%w: steepest course range min %g exceeds max %g", ErrInvalidQuery, r.Min, r.Max)
+	}
+
+	if q.Cursor == "" {
+		return q, key, nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return q, key, nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return q, key, nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if cursor.Sort != q.Sort || cursor.Desc != q.Descending {
+		return q, key, nil, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidQuery)
+	}
+	return q, key, &cursor, nil
+}
+
+// sortValue returns r's value for a numeric sort, with unknown values mapped
+// to the end of the order. It is zero for SortByName.
+func (q Query) sortValue(key Key, r models.Resort) float64 {
+	if key.Value == nil {
+		return 0
+	}
+	if v := key.Value(r); v != nil {
+		return *v
+	}
+	if q.Descending {
+		return -NullSortValue
+	}
+	return NullSortValue
+}
+
+// cursorAfter returns the cursor positioned after r.
+func (q Query) cursorAfter(key Key, r models.Resort) string {
+	c := Cursor{Sort: q.Sort, Desc: q.Descending, Key: q.sortValue(key, r), Name: r.Name, ID: r.ID}
+	return c.encode()
+}
+
+// compare orders a before b (negative) or after b (positive) in q's order.
+// Name and ID break ties in ascending order.
+func (q Query) compare(key Key, aKey float64, aName, aID string, bKey float64, bName, bID string) int {
+	primary := 0
+	if key.Value == nil {
+		primary = strings.Compare(aName, bName)
+	} else if aKey < bKey {
+		primary = -1
+	} else if aKey > bKey {
+		primary = 1
+	}
+	if q.Descending {
+		primary = -primary
+	}
+	if primary != 0 {
+		return primary
+	}
+	if key.Value != nil {
+		if c := strings.Compare(aName, bName); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(aID, bID)
+}
+
+// matches reports whether r passes every filter of q except the cursor.
+func (q Query) matches(r models.Resort) bool {
+	inInt := func(v *int, rng IntRange) bool {
+		if rng.Min == 0 && rng.Max == 0 {
+			return true
+		}
+		return v != nil && (rng.Min == 0 || *v >= rng.Min) && (rng.Max == 0 || *v <= rng.Max)
+	}
+	inFloat := func(v *float64, rng FloatRange) bool {
+		if rng.Min == 0 && rng.Max == 0 {
+			return true
+		}
+		return v != nil && (rng.Min == 0 || *v >= rng.Min) && (rng.Max == 0 || *v <= rng.Max)
+	}
+	return (q.Prefecture == "" || r.Prefecture == q.Prefecture) &&
+		(q.Region == "" || r.Region == q.Region) &&
+		inInt(r.TopElevationM, q.TopElevationM) &&
+		inInt(r.BaseElevationM, q.BaseElevationM) &&
+		inInt(r.VerticalM, q.VerticalM) &&
+		inInt(r.NumCourses, q.NumCourses) &&
+		inFloat(r.SteepestCourseDeg, q.SteepestCourseDeg) &&
+		NameMatches(r.Name, q.Name)
+}
+
+// Search applies q to an in-memory list of resorts with the same semantics
+// as ReaderRepository.SearchResorts, including cursor format.
+func Search(resorts []models.Resort, q Query) (Page, error) {
+	q, key, cursor, err := q.Prepare()
+	if err != nil {
+		return Page{}, err
+	}
+
+	var matched []models.Resort
+	for _, r := range resorts {
+		if !q.matches(r) {
+			continue
+		}
+		if cursor != nil && q.compare(key, q.sortValue(key, r), r.Name, r.ID, cursor.Key, cursor.Name, cursor.ID) <= 0 {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		return q.compare(key, q.sortValue(key, a), a.Name, a.ID, q.sortValue(key, b), b.Name, b.ID) < 0
+	})
+	return q.Page(key, matched), nil
+}
+
+// Page trims resorts, which hold up to Limit+1 ordered matches, to one page.
+func (q Query) Page(key Key, resorts []models.Resort) Page {
+	page := Page{Resorts: resorts}
+	if page.Resorts == nil {
+		page.Resorts = []models.Resort{}
+	}
+	if len(resorts) > q.Limit {
+		page.Resorts = resorts[:q.Limit]
+		page.NextCursor = q.cursorAfter(key, page.Resorts[q.Limit-1])
+	}
+	return page
+}
@@ -0,0 +1,279 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const flySocket = "/.fly/api"
+
+// Defaults applied by FlyClient.
+const (
+	DefaultFlyRetries    = 3
+	DefaultFlyRetryDelay = 250 * time.Millisecond
+	// maxFlyWait is the longest wait the Machines API accepts per request.
+	maxFlyWait = 60 * time.Second
+)
+
+// MachineState is the state of a Fly machine.
+type MachineState string
+
+// Machine states reported by the Machines API.
+const (
+	MachineCreated    MachineState = "created"
+	MachineStarting   MachineState = "starting"
+	MachineStarted    MachineState = "started"
+	MachineStopping   MachineState = "stopping"
+	MachineStopped    MachineState = "stopped"
+	MachineSuspending MachineState = "suspending"
+	MachineSuspended  MachineState = "suspended"
+	MachineDestroyed  MachineState = "destroyed"
+)
+
+// Machine is the part of a Machines API machine the client uses.
+type Machine struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	State      MachineState `json:"state"`
+	Region     string       `json:"region"`
+	InstanceID string       `json:"instance_id"`
+}
+
+// ErrMachineNotFound matches a FlyAPIError for a machine or app that does not
+// exist.
+var ErrMachineNotFound = errors.New("fly machine not found")
+
+// FlyAPIError is an unsuccessful response from the Machines API.
+type FlyAPIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is the API's error message, or the response body.
+	Message string
+}
+
+func (e *FlyAPIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is reports whether a 404 matches ErrMachineNotFound.
+func (e *FlyAPIError) Is(target error) bool {
+	return target == ErrMachineNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Temporary reports whether the request may succeed if retried: the API was
+// rate limited or failed with a 5xx.
+func (e *FlyAPIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// FlyConnError is a request to the Machines API that got no response.
+type FlyConnError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *FlyConnError) Error() string { return fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err) }
+func (e *FlyConnError) Unwrap() error { return e.Err }
+
+// FlyClient calls the Fly Machines API for one machine. Requests failing with
+// a FlyConnError or a temporary FlyAPIError are retried with exponential
+// backoff.
+type FlyClient struct {
+	AppName   string
+	MachineID string
+	// Retries is the number of retries of a failed request. Zero means
+	// DefaultFlyRetries; negative disables retries.
+	Retries int
+	// RetryDelay is the delay before the first retry; it doubles on each
+	// further retry. Zero means DefaultFlyRetryDelay.
+	RetryDelay time.Duration
+
+	client httpDoer
+}
+
+// NewFlyClient returns a client for the machine that reaches the Machines API
+// through socketPath. An empty socketPath means /.fly/api, which Fly mounts in
+// every machine.
+func NewFlyClient(appName, machineID, socketPath string) *FlyClient {
+	if socketPath == "" {
+		socketPath = flySocket
+	}
+	return &FlyClient{AppName: appName, MachineID: machineID, client: unixSocketClient(socketPath)}
+}
+
+// unixSocketClient returns a client that sends every request to socket.
+// Requests are bounded by their context only, since waits can be long.
+func unixSocketClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Get returns the machine.
+func (c *FlyClient) Get(ctx context.Context) (*Machine, error) {
+	var m Machine
+	if err := c.do(ctx, http.MethodGet, "", nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// State returns the machine's state.
+func (c *FlyClient) State(ctx context.Context) (MachineState, error) {
+	m, err := c.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return m.State, nil
+}
+
+// Stop requests the machine stop.
+func (c *FlyClient) Stop(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/stop", nil, nil)
+}
+
+// Suspend requests the machine suspend, saving its memory so it resumes
+// faster than it starts after a stop.
+func (c *FlyClient) Suspend(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/suspend", nil, nil)
+}
+
+// WaitForState waits until the machine is in state, which must be one the
+// Machines API can wait for: started, stopped, suspended or destroyed. It
+// returns at once if the machine is already in state, and otherwise waits
+// until ctx is done.
+func (c *FlyClient) WaitForState(ctx context.Context, state MachineState) error {
+	m, err := c.Get(ctx)
+	if err != nil {
+		return err
+	}
+	if m.State == state {
+		return nil
+	}
+
+	for {
+		wait := maxFlyWait
+		if deadline, ok := ctx.Deadline(); ok {
+			wait = min(wait, time.Until(deadline))
+		}
+		query := url.Values{
+			"state":   {string(state)},
+			"timeout": {strconv.Itoa(max(int(wait.Seconds()), 1))},
+		}
+		if m.InstanceID != "" {
+			query.Set("instance_id", m.InstanceID)
+		}
+
+		err := c.do(ctx, http.MethodGet, "/wait", query, nil)
+		var apiErr *FlyAPIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusRequestTimeout {
+			return err
+		}
+		// The API gave up waiting; wait again until ctx is done.
+		if ctx.Err() != nil {
+			return fmt.Errorf("wait for machine %s: %w", state, ctx.Err())
+		}
+	}
+}
+
+// do sends a request for path under the machine, retrying temporary failures,
+// and decodes a successful response into out unless it is nil.
+func (c *FlyClient) do(ctx context.Context, method, path string, query url.Values, out any) error {
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultFlyRetries
+	}
+	delay := c.RetryDelay
+	if delay <= 0 {
+		delay = DefaultFlyRetryDelay
+	}
+	path = fmt.Sprintf("/v1/apps/%s/machines/%s%s", url.PathEscape(c.AppName), url.PathEscape(c.MachineID), path)
+
+	for attempt := 0; ; attempt++ {
+		err := c.doOnce(ctx, method, path, query, out)
+		if err == nil || attempt >= retries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		slog.Warn("fly machines api request failed, retrying", "request", method+" "+path, "attempt", attempt+1, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func retryable(err error) bool {
+	var apiErr *FlyAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var connErr *FlyConnError
+	return errors.As(err, &connErr)
+}
+
+func (c *FlyClient) doOnce(ctx context.Context, method, path string, query url.Values, out any) error {
+	target := "http://flaps" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return fmt.Errorf("build %s %s request: %w", method, path, err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &FlyConnError{Method: method, Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &FlyAPIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: string(body)}
+		var parsed struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &parsed) == nil && parsed.Error != "" {
+			apiErr.Message = parsed.Error
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFlaps is a stand-in for the Machines API of machine m1 of app app.
+type fakeFlaps struct {
+	mu    sync.Mutex
+	state MachineState
+	// failures is the number of 503s to return before serving each request.
+	failures int
+	// waitTimeouts is the number of 408s /wait returns before the machine
+	// reaches the requested state.
+	waitTimeouts int
+	requests     []string
+}
+
+func (f *fakeFlaps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	const prefix = "/v1/apps/app/machines/m1"
+	switch r.Method + " " + r.URL.Path {
+	case "GET " + prefix:
+		json.NewEncoder(w).Encode(Machine{ID: "m1", State: f.state, InstanceID: "i1"})
+	case "POST " + prefix + "/stop":
+		f.state = MachineStopping
+	case "POST " + prefix + "/suspend":
+		f.state = MachineSuspending
+	case "GET " + prefix + "/wait":
+		if r.URL.Query().Get("instance_id") != "i1" || r.URL.Query().Get("timeout") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.waitTimeouts > 0 {
+			f.waitTimeouts--
+			w.WriteHeader(http.StatusRequestTimeout)
+			json.NewEncoder(w).Encode(map[string]string{"error": "deadline_exceeded"})
+			return
+		}
+		f.state = MachineState(r.URL.Query().Get("state"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "machine not found"})
+	}
+}
+
+func newFakeFlapsClient(t *testing.T, flaps *fakeFlaps) *FlyClient {
+	t.Helper()
+
+	c := NewFlyClient("app", "m1", serveUnix(t, flaps))
+	c.RetryDelay = time.Millisecond
+	return c
+}
+
+func TestFlyClient_RetriesTemporaryFailures(t *testing.T) {
+	t.Parallel()
+
+	flaps := &fakeFlaps{state: MachineStarted, failures: 2}
+	c := newFakeFlapsClient(t, flaps)
+	ctx := context.Background()
+
+	state, err := c.State(ctx)
+	if err != nil || state != MachineStarted {
+		t.Fatalf("State() = %q, %v", state, err)
+	}
+	if len(flaps.requests) != 3 {
+		t.Fatalf("requests = %v, want two retries", flaps.requests)
+	}
+
+	flaps.failures = 5
+	err = c.Stop(ctx)
+	var apiErr *FlyAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !apiErr.Temporary() {
+		t.Fatalf("Stop() error = %v, want 503 after retries", err)
+	}
+	if len(flaps.requests) != 3+1+DefaultFlyRetries {
+		t.Fatalf("requests = %d, want %d", len(flaps.requests), 3+1+DefaultFlyRetries)
+	}
+}
+
+func TestFlyClient_DoesNotRetryClientErrors(t *testing.T) {
+	t.Parallel()
+
+	flaps := &fakeFlaps{state: MachineStarted}
+	c := newFakeFlapsClient(t, flaps)
+	c.MachineID = "missing"
+
+	_, err := c.Get(context.Background())
+	if !errors.Is(err, ErrMachineNotFound) {
+		t.Fatalf("Get() error = %v, want ErrMachineNotFound", err)
+	}
+	var apiErr *FlyAPIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "machine not found" {
+		t.Fatalf("Get() error = %#v", err)
+	}
+	if len(flaps.requests) != 1 {
+		t.Fatalf("requests = %v, want no retries", flaps.requests)
+	}
+}
+
+func TestFlyClient_RetriesConnectionErrors(t *testing.T) {
+	t.Parallel()
+
+	c := NewFlyClient("app", "m1", t.TempDir()+"/missing.sock")
+	c.RetryDelay = time.Millisecond
+
+	err := c.Stop(context.Background())
+	var connErr *FlyConnError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("Stop() error = %v, want FlyConnError", err)
+	}
+}
+
+func TestFlyClient_SuspendAndWaitForState(t *testing.T) {
+	t.Parallel()
+
+	flaps := &fakeFlaps{state: MachineStarted, waitTimeouts: 1}
+	c := newFakeFlapsClient(t, flaps)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Suspend(ctx); err != nil {
+		t.Fatalf("Suspend() error = %v", err)
+	}
+	if err := c.WaitForState(ctx, MachineSuspended); err != nil {
+		t.Fatalf("WaitForState() error = %v", err)
+	}
+	want := []string{
+		"POST /v1/apps/app/machines/m1/suspend",
+		"GET /v1/apps/app/machines/m1",
+		"GET /v1/apps/app/machines/m1/wait",
+		"GET /v1/apps/app/machines/m1/wait",
+	}
+	if !reflect.DeepEqual(flaps.requests, want) {
+		t.Fatalf("requests = %v, want %v", flaps.requests, want)
+	}
+
+	// Already in the state: no wait request.
+	if err := c.WaitForState(ctx, MachineSuspended); err != nil || len(flaps.requests) != len(want)+1 {
+		t.Fatalf("WaitForState() again = %v after %v", err, flaps.requests)
+	}
+}
+
+func TestFlyStopper_Suspends(t *testing.T) {
+	t.Parallel()
+
+	flaps := &fakeFlaps{state: MachineStarted}
+	socket := serveUnix(t, flaps)
+
+	s, err := DetectStopper(mapEnv(map[string]string{"FLY_APP_NAME": "app", "FLY_MACHINE_ID": "m1", EnvFlySuspend: "true"}))
+	if err != nil {
+		t.Fatalf("DetectStopper() error = %v", err)
+	}
+	fly := s.(*FlyStopper)
+	fly.SocketPath = socket
+	if err := fly.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if flaps.state != MachineSuspending {
+		t.Fatalf("state = %q, want %q", flaps.state, MachineSuspending)
+	}
+}
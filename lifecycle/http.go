@@ -0,0 +1,116 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// pingTimeout bounds the database ping of the readiness handler.
+const pingTimeout = 2 * time.Second
+
+// Pinger checks that a dependency is reachable. *sql.DB satisfies it.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Middleware resets the idle timer on every request, and again when it
+// completes, and counts the request as an in-flight "http" task, so the machine
+// is not stopped under it and Shutdown waits for it. While the machine is being
+// stopped or shut down, requests are refused with 503 Service Unavailable.
+//
+// Mount the health handlers outside Middleware: otherwise the platform's health
+// checks count as activity and keep the machine awake.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		end, ok := m.beginServing("http")
+		if !ok {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		m.ResetIdleTimer()
+		defer func() {
+			end()
+			m.ResetIdleTimer()
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HealthStatus is the body served by HealthHandler and ReadyHandler.
+type HealthStatus struct {
+	// Status is "ok", or "unavailable" when ReadyHandler reports not ready.
+	Status       string   `json:"status"`
+	Running      bool     `json:"running"`
+	ActiveTasks  []string `json:"active_tasks"`
+	Stopping     bool     `json:"stopping"`
+	ShuttingDown bool     `json:"shutting_down"`
+	// IdleShutdownInSeconds is the time left until the idle timeout, or nil
+	// when the idle timer is not running.
+	IdleShutdownInSeconds *float64 `json:"idle_shutdown_in_seconds"`
+	// Database is "ok" or "unreachable"; it is omitted by HealthHandler and
+	// when ReadyHandler has no database. The ping error itself is only logged.
+	Database string `json:"database,omitempty"`
+}
+
+func (m *Manager) healthStatus() HealthStatus {
+	s := m.Status()
+	status := HealthStatus{
+		Status:       "ok",
+		Running:      s.Running,
+		ActiveTasks:  s.ActiveTasks,
+		Stopping:     s.Stopping,
+		ShuttingDown: s.ShuttingDown,
+	}
+	if left, ok := s.IdleShutdownIn(time.Now()); ok {
+		seconds := left.Seconds()
+		status.IdleShutdownInSeconds = &seconds
+	}
+	return status
+}
+
+// HealthHandler serves liveness at e.g. /healthz: it always responds 200 with
+// a HealthStatus.
+func (m *Manager) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeHealth(w, http.StatusOK, m.healthStatus())
+	})
+}
+
+// ReadyHandler serves readiness at e.g. /readyz: it responds 200 with a
+// HealthStatus, or 503 while the machine is being stopped or shut down, or
+// when db, if not nil, does not answer a ping within 2 seconds.
+func (m *Manager) ReadyHandler(db Pinger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := m.healthStatus()
+		code := http.StatusOK
+		if db != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+			err := db.PingContext(ctx)
+			cancel()
+			status.Database = "ok"
+			if err != nil {
+				slog.Warn("readiness database ping failed", "error", err)
+				status.Database = "unreachable"
+				code = http.StatusServiceUnavailable
+			}
+		}
+		if status.Stopping || status.ShuttingDown {
+			code = http.StatusServiceUnavailable
+		}
+		if code != http.StatusOK {
+			status.Status = "unavailable"
+		}
+		writeHealth(w, code, status)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, status HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
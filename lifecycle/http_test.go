@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type pingerFunc func(context.Context) error
+
+func (f pingerFunc) PingContext(ctx context.Context) error { return f(ctx) }
+
+func getHealth(t *testing.T, h http.Handler) (int, HealthStatus) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var status HealthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decode health body: %v", err)
+	}
+	return rec.Code, status
+}
+
+func TestManagerMiddleware_TracksRequestAndResetsTimer(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer m.Stop()
+
+	var during HealthStatus
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, during = getHealth(t, m.HealthHandler())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/run", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !during.Running || !reflect.DeepEqual(during.ActiveTasks, []string{"http"}) {
+		t.Fatalf("health during request = %+v, want running with an http task", during)
+	}
+	if m.IsRunning() {
+		t.Fatal("expected request to end its task")
+	}
+	// Reset once when the request starts and once when it ends.
+	if m.timerVersion != 2 {
+		t.Fatalf("timerVersion = %d, want 2", m.timerVersion)
+	}
+}
+
+func TestManagerHealthHandler_ReportsTimeUntilIdleShutdown(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	code, status := getHealth(t, m.HealthHandler())
+	if code != http.StatusOK || status.Status != "ok" || status.IdleShutdownInSeconds != nil {
+		t.Fatalf("health before timer = %d %+v", code, status)
+	}
+
+	m.ResetIdleTimer()
+	defer m.Stop()
+	_, status = getHealth(t, m.HealthHandler())
+	if left := status.IdleShutdownInSeconds; left == nil || *left <= 3590 || *left > 3600 {
+		t.Fatalf("idle_shutdown_in_seconds = %v, want about an hour", left)
+	}
+	if status.Database != "" {
+		t.Fatalf("database = %q, want omitted", status.Database)
+	}
+}
+
+func TestManagerReadyHandler(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	dbErr := error(nil)
+	db := pingerFunc(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("ping context has no deadline")
+		}
+		return dbErr
+	})
+
+	if code, status := getHealth(t, m.ReadyHandler(db)); code != http.StatusOK || status.Database != "ok" {
+		t.Fatalf("ready = %d %+v", code, status)
+	}
+
+	dbErr = errors.New("database is locked")
+	code, status := getHealth(t, m.ReadyHandler(db))
+	if code != http.StatusServiceUnavailable || status.Status != "unavailable" || status.Database != "unreachable" {
+		t.Fatalf("ready with failing db = %d %+v", code, status)
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	code, status = getHealth(t, m.ReadyHandler(nil))
+	if code != http.StatusServiceUnavailable || !status.ShuttingDown {
+		t.Fatalf("ready while shutting down = %d %+v", code, status)
+	}
+
+	// Requests during shutdown are refused and do not restart the idle timer.
+	rec := httptest.NewRecorder()
+	m.Middleware(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("request during shutdown status = %d, want 503", rec.Code)
+	}
+	if m.idleTimer != nil {
+		t.Fatal("expected no idle timer after shutdown")
+	}
+}
@@ -1,5 +1,6 @@
-// Package lifecycle manages Fly.io machine lifecycle: idle timeout,
-// running state, and machine shutdown via the Fly Machines API.
+// Package lifecycle manages machine lifecycle: idle timeout, in-flight task
+// tracking, graceful shutdown, and stopping the machine when idle through a
+// pluggable Stopper, by default the Fly Machines API.
 package lifecycle
 
 import (
@@ -7,27 +8,135 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net"
-	"net/http"
 	"os"
 	"sync"
 	"time"
 )
 
-// ErrNotOnFly is returned by StopMachine when the process is not running on Fly.io
-// (i.e. FLY_APP_NAME or FLY_MACHINE_ID environment variables are not set).
-var ErrNotOnFly = errors.New("not running on Fly.io")
+// ErrNoStopper is returned by StopMachine and DetectStopper when there is no
+// way to stop the machine. The idle timeout then leaves the process running.
+var ErrNoStopper = errors.New("no machine stopper")
 
-const flySocket = "/.fly/api"
+// ErrNotOnFly is returned by StopMachine when no Stopper is set and the process
+// is not running on Fly.io (i.e. FLY_APP_NAME or FLY_MACHINE_ID environment
+// variables are not set). It wraps ErrNoStopper.
+var ErrNotOnFly = fmt.Errorf("not running on Fly.io: %w", ErrNoStopper)
+
+// EventKind identifies a step of the idle shutdown sequence.
+type EventKind int
+
+const (
+	// EventIdle is emitted when the idle timeout fires with no task running.
+	EventIdle EventKind = iota + 1
+	// EventStopRequested is emitted after the machine stop was requested.
+	EventStopRequested
+	// EventStopSkipped is emitted when there is no way to stop the machine,
+	// e.g. the process is not running on Fly.io.
+	EventStopSkipped
+	// EventStopFailed is emitted when the stop request fails; the idle timer
+	// is restarted afterwards.
+	EventStopFailed
+	// EventTimerReset is emitted when the idle timer is (re)started. It
+	// happens on every request, so it is only delivered to OnTimerReset
+	// callbacks, not to Observe.
+	EventTimerReset
+)
+
+// String returns the event name, e.g. "stop_requested".
+func (k EventKind) String() string {
+	switch k {
+	case EventIdle:
+		return "idle"
+	case EventStopRequested:
+		return "stop_requested"
+	case EventStopSkipped:
+		return "stop_skipped"
+	case EventStopFailed:
+		return "stop_failed"
+	case EventTimerReset:
+		return "timer_reset"
+	default:
+		return fmt.Sprintf("event(%d)", int(k))
+	}
+}
+
+// Event describes a step of the idle shutdown sequence.
+type Event struct {
+	Kind        EventKind
+	Time        time.Time
+	IdleTimeout time.Duration
+	// TimerVersion is the version of the idle timer the event belongs to;
+	// every reset increments it.
+	TimerVersion uint64
+	// Deadline is when the idle timer fires, for EventTimerReset.
+	Deadline time.Time
+	// StopAttempt numbers the stop attempts since New, for EventStopRequested,
+	// EventStopSkipped and EventStopFailed.
+	StopAttempt int
+	// Err is the stop error for EventStopFailed.
+	Err error
+}
+
+// Status is a snapshot of the Manager's state.
+type Status struct {
+	Running     bool
+	ActiveTasks []string
+	// Stopping is set while the idle timeout stops the machine, and
+	// ShuttingDown once the machine is going away for good. Middleware
+	// refuses requests in both.
+	Stopping     bool
+	ShuttingDown bool
+	IdleTimeout  time.Duration
+	// LastActivity is when the idle timer was last reset; zero if never.
+	LastActivity time.Time
+	// Deadline is when the idle timer fires; zero when it is not running.
+	Deadline     time.Time
+	TimerVersion uint64
+	// StopAttempts counts the machine stops attempted since New, and
+	// LastStopError is the error of the latest one, if it failed.
+	StopAttempts  int
+	LastStopError error
+}
+
+// IdleShutdownIn returns the time from now until the idle timer fires, or
+// false when it is not running.
+func (s Status) IdleShutdownIn(now time.Time) (time.Duration, bool) {
+	if s.Deadline.IsZero() {
+		return 0, false
+	}
+	return max(s.Deadline.Sub(now), 0), true
+}
+
+type observer struct {
+	// kind is the only kind delivered, or 0 for every kind but
+	// EventTimerReset.
+	kind EventKind
+	fn   func(Event)
+}
 
 // Manager handles Fly.io machine lifecycle: idle timeout, running state, and shutdown.
 type Manager struct {
 	stateMu       sync.Mutex
 	running       bool
+	tasks         map[string]int
+	activeTasks   int
+	drained       chan struct{}
 	idleTimeout   time.Duration
 	idleTimer     *time.Timer
+	idleDeadline  time.Time
+	lastActivity  time.Time
 	timerVersion  uint64
+	stopping      bool
+	shuttingDown  bool
+	stopAttempts  int
+	lastStopErr   error
+	stopper       Stopper
 	stopMachineFn func() error
+	observers     []observer
+
+	hookMu    sync.Mutex
+	hooks     []shutdownHook
+	hooksDone bool
 }
 
 // New creates a lifecycle Manager with the specified idle timeout.
@@ -36,20 +145,102 @@ func New(idleTimeout time.Duration) (*Manager, error) {
 		return nil, fmt.Errorf("idle timeout must be positive: %s", idleTimeout)
 	}
 
-	m := &Manager{idleTimeout: idleTimeout}
+	m := &Manager{idleTimeout: idleTimeout, tasks: make(map[string]int)}
 	m.stopMachineFn = m.StopMachine
 	return m, nil
 }
 
-// IsRunning returns whether a task is currently in progress.
+// Observe registers fn to be called for every idle shutdown event except
+// EventTimerReset. Observers run synchronously on the idle timer goroutine, so
+// they should return quickly.
+func (m *Manager) Observe(fn func(Event)) {
+	m.observe(0, fn)
+}
+
+// OnIdle registers fn to be called when the idle timeout fires with no task
+// running, before the shutdown hooks and the machine stop.
+func (m *Manager) OnIdle(fn func(Event)) {
+	m.observe(EventIdle, fn)
+}
+
+// OnStopRequested registers fn to be called after the machine stop was
+// requested.
+func (m *Manager) OnStopRequested(fn func(Event)) {
+	m.observe(EventStopRequested, fn)
+}
+
+// OnStopFailed registers fn to be called when a machine stop fails, with the
+// error in Event.Err.
+func (m *Manager) OnStopFailed(fn func(Event)) {
+	m.observe(EventStopFailed, fn)
+}
+
+// OnTimerReset registers fn to be called whenever the idle timer is
+// (re)started, with the new deadline in Event.Deadline. It runs on the
+// goroutine resetting the timer, often a request's, so it must return quickly.
+func (m *Manager) OnTimerReset(fn func(Event)) {
+	m.observe(EventTimerReset, fn)
+}
+
+func (m *Manager) observe(kind EventKind, fn func(Event)) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	m.observers = append(m.observers, observer{kind: kind, fn: fn})
+}
+
+// emit delivers event to the observers of its kind, filling in its time and
+// idle timeout. It must be called without stateMu held.
+func (m *Manager) emit(event Event) {
+	m.stateMu.Lock()
+	observers := m.observers
+	m.stateMu.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.IdleTimeout = m.idleTimeout
+	for _, o := range observers {
+		if o.kind == event.Kind || (o.kind == 0 && event.Kind != EventTimerReset) {
+			o.fn(event)
+		}
+	}
+}
+
+// Status returns a snapshot of the manager's state.
+func (m *Manager) Status() Status {
+	tasks := m.ActiveTasks()
+
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	return Status{
+		Running:       m.running || m.activeTasks > 0,
+		ActiveTasks:   tasks,
+		Stopping:      m.stopping,
+		ShuttingDown:  m.shuttingDown,
+		IdleTimeout:   m.idleTimeout,
+		LastActivity:  m.lastActivity,
+		Deadline:      m.idleDeadline,
+		TimerVersion:  m.timerVersion,
+		StopAttempts:  m.stopAttempts,
+		LastStopError: m.lastStopErr,
+	}
+}
+
+// IsRunning returns whether a task is currently in progress, either begun with
+// Begin or flagged with SetRunning.
 func (m *Manager) IsRunning() bool {
 	m.stateMu.Lock()
 	defer m.stateMu.Unlock()
 
-	return m.running
+	return m.running || m.activeTasks > 0
 }
 
 // SetRunning sets the running state.
+//
+// Deprecated: SetRunning is a single flag, so overlapping tasks clear it for
+// each other. Use Begin, which counts tasks.
 func (m *Manager) SetRunning(v bool) {
 	m.stateMu.Lock()
 	defer m.stateMu.Unlock()
@@ -57,33 +248,45 @@ func (m *Manager) SetRunning(v bool) {
 	m.running = v
 }
 
-// ResetIdleTimer (re)starts the idle timeout. Call from the /run handler
-// and at server startup.
+// ResetIdleTimer (re)starts the idle timeout. Call at server startup; wrap
+// handlers in Middleware to reset it on every request. It does nothing while
+// the machine is being stopped or shut down.
 func (m *Manager) ResetIdleTimer() {
 	m.stateMu.Lock()
-	defer m.stateMu.Unlock()
-
+	if m.stopping || m.shuttingDown {
+		m.stateMu.Unlock()
+		return
+	}
 	m.timerVersion++
 	version := m.timerVersion
 	if m.idleTimer != nil {
 		m.idleTimer.Stop()
 	}
+	now := time.Now()
+	m.lastActivity = now
+	m.idleDeadline = now.Add(m.idleTimeout)
+	deadline := m.idleDeadline
 	m.idleTimer = time.AfterFunc(m.idleTimeout, func() {
 		m.onIdleTimeout(version)
 	})
+	m.stateMu.Unlock()
+
+	m.emit(Event{Kind: EventTimerReset, Time: now, TimerVersion: version, Deadline: deadline})
 }
 
 func (m *Manager) onIdleTimeout(version uint64) {
 	m.stateMu.Lock()
-	if version != m.timerVersion || m.idleTimer == nil {
+	if version != m.timerVersion || m.idleTimer == nil || m.shuttingDown {
 		m.stateMu.Unlock()
 		return
 	}
 
-	running := m.running
-	if !running {
-		m.idleTimer = nil
-	}
+	// Deciding the machine is idle and refusing new requests happen together,
+	// so no request can start between the check and the stop.
+	running := m.running || m.activeTasks > 0
+	m.idleTimer = nil
+	m.idleDeadline = time.Time{}
+	m.stopping = !running
 	m.stateMu.Unlock()
 
 	if running {
@@ -91,21 +294,85 @@ func (m *Manager) onIdleTimeout(version uint64) {
 		return
 	}
 	slog.Info("idle timeout, stopping machine", "timeout", m.idleTimeout)
+	m.emit(Event{Kind: EventIdle, TimerVersion: version})
+
+	// Begin and SetRunning are not refused while stopping, so look again.
+	if m.IsRunning() {
+		m.resumeServing()
+		m.ResetIdleTimer()
+		return
+	}
+
+	hookCtx, cancel := context.WithTimeout(context.Background(), shutdownHookTimeout)
+	if err := m.runShutdownHooks(hookCtx); err != nil {
+		slog.Error("shutdown hooks failed", "error", err)
+	}
+	cancel()
 	stopMachine := m.stopMachineFn
 	if stopMachine == nil {
 		stopMachine = m.StopMachine
 	}
-	if err := stopMachine(); err != nil {
-		if errors.Is(err, ErrNotOnFly) {
-			slog.Info("not on Fly.io, idle timeout handler returning")
+	err := stopMachine()
+
+	m.stateMu.Lock()
+	m.stopAttempts++
+	attempt := m.stopAttempts
+	m.lastStopErr = err
+	if errors.Is(err, ErrNoStopper) {
+		m.lastStopErr = nil
+	}
+	m.stateMu.Unlock()
+
+	event := Event{TimerVersion: version, StopAttempt: attempt}
+	if err != nil {
+		m.resumeServing()
+		if errors.Is(err, ErrNoStopper) {
+			slog.Info("no machine stopper, idle timeout handler returning", "reason", err)
+			event.Kind = EventStopSkipped
+			m.emit(event)
 			return
 		}
-		slog.Error("failed to stop idle machine", "error", err)
+		slog.Error("failed to stop idle machine", "error", err, "attempt", attempt)
+		event.Kind, event.Err = EventStopFailed, err
+		m.emit(event)
 		m.ResetIdleTimer()
+		return
 	}
+
+	// The machine is going away: never serve again.
+	m.stateMu.Lock()
+	m.shuttingDown = true
+	m.stopping = false
+	m.stateMu.Unlock()
+	event.Kind = EventStopRequested
+	m.emit(event)
 }
 
-// Stop stops idle timeout handling and marks the manager as not running.
+// resumeServing accepts new requests again after an idle stop did not happen.
+func (m *Manager) resumeServing() {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	m.stopping = false
+}
+
+// shutDownForGood refuses new requests from now on and runs the shutdown
+// hooks.
+func (m *Manager) shutDownForGood() {
+	m.stateMu.Lock()
+	m.shuttingDown = true
+	m.stopping = false
+	m.stateMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownHookTimeout)
+	defer cancel()
+	if err := m.runShutdownHooks(ctx); err != nil {
+		slog.Error("shutdown hooks failed", "error", err)
+	}
+}
+
+// Stop stops idle timeout handling and clears the SetRunning flag. Tasks begun
+// with Begin stay in flight until they end.
 func (m *Manager) Stop() {
 	m.stateMu.Lock()
 	defer m.stateMu.Unlock()
@@ -116,60 +383,47 @@ func (m *Manager) Stop() {
 		m.idleTimer.Stop()
 		m.idleTimer = nil
 	}
+	m.idleDeadline = time.Time{}
 }
 
-type httpDoer interface {
-	Do(*http.Request) (*http.Response, error)
+// SetStopper sets how StopMachine stops the machine. Nil restores the
+// default, the Fly Machines API configured from the environment.
+func (m *Manager) SetStopper(s Stopper) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	m.stopper = s
 }
 
-// StopMachine stops this Fly machine via the Machines API Unix socket.
-// Returns ErrNotOnFly when not running on Fly.io (FLY_APP_NAME or FLY_MACHINE_ID unset).
+// StopMachine stops this machine with the Stopper set by SetStopper, or by
+// default via the Fly Machines API Unix socket. The default returns
+// ErrNotOnFly when not running on Fly.io (FLY_APP_NAME or FLY_MACHINE_ID
+// unset). Before an ExitStopper exits the process, new requests are refused
+// and the shutdown hooks run.
 func (m *Manager) StopMachine() error {
-	appName := os.Getenv("FLY_APP_NAME")
-	machineID := os.Getenv("FLY_MACHINE_ID")
-	if appName == "" || machineID == "" {
-		slog.Info("not on Fly.io, skipping machine stop")
-		return ErrNotOnFly
+	m.stateMu.Lock()
+	stopper := m.stopper
+	m.stateMu.Unlock()
+
+	if stopper == nil {
+		fly, err := flyStopperFromEnv(os.LookupEnv)
+		if err != nil {
+			slog.Info("not on Fly.io, skipping machine stop")
+			return err
+		}
+		stopper = fly
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
-				var dialer net.Dialer
-				return dialer.DialContext(ctx, "unix", flySocket)
-			},
-		},
-		Timeout: 10 * time.Second,
+	if _, ok := stopper.(processExiter); ok {
+		// Stop does not return, so release resources first.
+		m.shutDownForGood()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
 	defer cancel()
 
-	status, err := requestFlyMachineStop(ctx, client, appName, machineID)
-	if err != nil {
-		return fmt.Errorf("request machine stop: %w", err)
+	if err := stopper.Stop(ctx); err != nil {
+		return fmt.Errorf("stop machine via %s: %w", stopper.Name(), err)
 	}
-
-	slog.Info("machine stop requested", "status", status)
 	return nil
 }
-
-func requestFlyMachineStop(ctx context.Context, client httpDoer, appName, machineID string) (string, error) {
-	url := fmt.Sprintf("http://flaps/v1/apps/%s/machines/%s/stop", appName, machineID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
-	if err != nil {
-		return "", fmt.Errorf("build stop request: %w", err)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("send stop request: %w", err)
-	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return "", fmt.Errorf("unexpected stop status: %s", resp.Status)
-	}
-
-	return resp.Status, nil
-}
@@ -3,6 +3,7 @@ package lifecycle
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -20,10 +21,10 @@ func (f fakeHTTPDoer) Do(req *http.Request) (*http.Response, error) {
 	return f.do(req)
 }
 
-func TestRequestFlyMachineStop_SucceedsOn2xx(t *testing.T) {
+func TestFlyClientStop_SucceedsOn2xx(t *testing.T) {
 	t.Parallel()
 
-	client := fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
+	client := &FlyClient{AppName: "test-app", MachineID: "test-machine", client: fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
 		if req.Method != http.MethodPost {
 			t.Fatalf("method = %s, want %s", req.Method, http.MethodPost)
 		}
@@ -36,31 +37,28 @@ func TestRequestFlyMachineStop_SucceedsOn2xx(t *testing.T) {
 			Status:     "202 Accepted",
 			Body:       io.NopCloser(strings.NewReader("")),
 		}, nil
-	}}
+	}}}
 
-	status, err := requestFlyMachineStop(context.Background(), client, "test-app", "test-machine")
-	if err != nil {
-		t.Fatalf("requestFlyMachineStop() error = %v", err)
-	}
-	if status != "202 Accepted" {
-		t.Fatalf("status = %q, want %q", status, "202 Accepted")
+	if err := client.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
 	}
 }
 
-func TestRequestFlyMachineStop_ReturnsErrorOnNon2xx(t *testing.T) {
+func TestFlyClientStop_ReturnsErrorOnNon2xx(t *testing.T) {
 	t.Parallel()
 
-	client := fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
+	client := &FlyClient{AppName: "test-app", MachineID: "test-machine", Retries: -1, client: fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
 		return &http.Response{
 			StatusCode: http.StatusBadGateway,
 			Status:     "502 Bad Gateway",
 			Body:       io.NopCloser(strings.NewReader("")),
 		}, nil
-	}}
+	}}}
 
-	_, err := requestFlyMachineStop(context.Background(), client, "test-app", "test-machine")
-	if err == nil {
-		t.Fatal("expected error")
+	err := client.Stop(context.Background())
+	var apiErr *FlyAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Stop() error = %v, want a 502 FlyAPIError", err)
 	}
 }
 
@@ -90,22 +88,65 @@ func TestManagerOnIdleTimeout_ReschedulesAfterStopFailure(t *testing.T) {
 	m.idleTimer.Stop()
 }
 
-func TestRequestFlyMachineStop_ContextCancellation(t *testing.T) {
+func TestManagerObserve_ReportsIdleShutdownEvents(t *testing.T) {
+	t.Parallel()
+
+	stopErr := errors.New("stop failed")
+	for _, tt := range []struct {
+		name    string
+		stopErr error
+		want    []EventKind
+	}{
+		{"stopped", nil, []EventKind{EventIdle, EventStopRequested}},
+		{"not on fly", ErrNotOnFly, []EventKind{EventIdle, EventStopSkipped}},
+		{"failed", stopErr, []EventKind{EventIdle, EventStopFailed}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := New(time.Hour)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			m.stopMachineFn = func() error { return tt.stopErr }
+			var got []Event
+			m.Observe(func(e Event) { got = append(got, e) })
+
+			m.timerVersion = 1
+			m.idleTimer = time.NewTimer(time.Hour)
+			m.onIdleTimeout(1)
+			m.Stop()
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("events = %+v, want kinds %v", got, tt.want)
+			}
+			for i, kind := range tt.want {
+				if got[i].Kind != kind || got[i].IdleTimeout != time.Hour {
+					t.Fatalf("event %d = %+v, want kind %s", i, got[i], kind)
+				}
+			}
+			if last := got[len(got)-1]; (last.Kind == EventStopFailed) != errors.Is(last.Err, stopErr) {
+				t.Fatalf("last event error = %v", last.Err)
+			}
+		})
+	}
+}
+
+func TestFlyClientStop_ContextCancellation(t *testing.T) {
 	t.Parallel()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	client := fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
+	client := &FlyClient{AppName: "test-app", MachineID: "test-machine", client: fakeHTTPDoer{do: func(req *http.Request) (*http.Response, error) {
 		if err := req.Context().Err(); err == nil {
 			t.Fatal("expected canceled request context")
 		}
 		return nil, req.Context().Err()
-	}}
+	}}}
 
-	_, err := requestFlyMachineStop(ctx, client, "test-app", "test-machine")
-	if err == nil {
-		t.Fatal("expected error")
+	if err := client.Stop(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Stop() error = %v, want context canceled", err)
 	}
 }
 
@@ -210,3 +251,84 @@ func TestManagerResetIdleTimer_AfterStopStartsFreshTimer(t *testing.T) {
 		m.idleTimer.Stop()
 	}
 }
+
+func TestManagerOnTimerReset_ReportsDeadlineButNotToObserve(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var resets, observed []Event
+	m.OnTimerReset(func(e Event) { resets = append(resets, e) })
+	m.Observe(func(e Event) { observed = append(observed, e) })
+
+	m.ResetIdleTimer()
+	m.ResetIdleTimer()
+	defer m.Stop()
+
+	if len(observed) != 0 {
+		t.Fatalf("Observe got %+v, want no timer resets", observed)
+	}
+	if len(resets) != 2 {
+		t.Fatalf("OnTimerReset calls = %d, want 2", len(resets))
+	}
+	last := resets[1]
+	status := m.Status()
+	if last.Kind != EventTimerReset || last.TimerVersion != 2 || !last.Deadline.Equal(last.Time.Add(time.Hour)) {
+		t.Fatalf("last reset = %+v", last)
+	}
+	if status.TimerVersion != 2 || !status.Deadline.Equal(last.Deadline) || !status.LastActivity.Equal(last.Time) {
+		t.Fatalf("Status() = %+v, want it to match %+v", status, last)
+	}
+	if left, ok := status.IdleShutdownIn(last.Time.Add(40 * time.Minute)); !ok || left != 20*time.Minute {
+		t.Fatalf("IdleShutdownIn() = %s, %v, want 20m", left, ok)
+	}
+}
+
+func TestManagerStatus_TracksStopAttempts(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	stopErr := errors.New("flaps unavailable")
+	m.stopMachineFn = func() error { return stopErr }
+
+	var events []string
+	record := func(e Event) {
+		events = append(events, fmt.Sprintf("%s#%d", e.Kind, e.StopAttempt))
+	}
+	m.OnIdle(record)
+	m.OnStopFailed(func(e Event) {
+		if !errors.Is(e.Err, stopErr) {
+			t.Errorf("stop failed error = %v", e.Err)
+		}
+		record(e)
+	})
+	m.OnStopRequested(record)
+	m.OnTimerReset(record)
+
+	m.ResetIdleTimer()
+	m.onIdleTimeout(m.Status().TimerVersion)
+	status := m.Status()
+	if status.StopAttempts != 1 || !errors.Is(status.LastStopError, stopErr) || status.Deadline.IsZero() {
+		t.Fatalf("Status() after failed stop = %+v", status)
+	}
+
+	m.stopMachineFn = func() error { return nil }
+	m.onIdleTimeout(status.TimerVersion)
+	status = m.Status()
+	if status.StopAttempts != 2 || status.LastStopError != nil || !status.Deadline.IsZero() {
+		t.Fatalf("Status() after stop = %+v", status)
+	}
+	if _, ok := status.IdleShutdownIn(time.Now()); ok {
+		t.Fatal("IdleShutdownIn() reported a deadline after the stop")
+	}
+
+	want := []string{"timer_reset#0", "idle#0", "stop_failed#1", "timer_reset#0", "idle#0", "stop_requested#2"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,180 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// shutdownHookTimeout bounds the shutdown hooks, which run after draining
+// and so cannot share its deadline.
+const shutdownHookTimeout = 10 * time.Second
+
+type shutdownHook struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Begin marks a task named name as in flight and returns a function that ends
+// it. While any task is in flight the idle timeout does not stop the machine
+// and Shutdown waits for it. Tasks are counted, so overlapping tasks, even with
+// the same name, do not end each other. The returned function may be called
+// more than once; only the first call ends the task.
+func (m *Manager) Begin(name string) func() {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	return m.beginLocked(name)
+}
+
+// beginServing is Begin for work that must be refused while the machine is
+// being stopped or shut down; ok is false, and no task is started, then.
+func (m *Manager) beginServing(name string) (end func(), ok bool) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	if m.stopping || m.shuttingDown {
+		return nil, false
+	}
+	return m.beginLocked(name), true
+}
+
+// beginLocked starts a task. The caller must hold stateMu.
+func (m *Manager) beginLocked(name string) func() {
+	if m.activeTasks == 0 {
+		m.drained = make(chan struct{})
+	}
+	m.activeTasks++
+	m.tasks[name]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { m.end(name) })
+	}
+}
+
+func (m *Manager) end(name string) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	m.activeTasks--
+	if m.tasks[name]--; m.tasks[name] == 0 {
+		delete(m.tasks, name)
+	}
+	if m.activeTasks == 0 {
+		close(m.drained)
+		m.drained = nil
+	}
+}
+
+// ActiveTasks returns the names of the tasks in flight, sorted, with a name
+// repeated once per task.
+func (m *Manager) ActiveTasks() []string {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	names := make([]string, 0, m.activeTasks)
+	for name, n := range m.tasks {
+		for range n {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Drain waits until no task begun with Begin is in flight, or ctx is done.
+// The SetRunning flag is not waited for.
+func (m *Manager) Drain(ctx context.Context) error {
+	m.stateMu.Lock()
+	drained := m.drained
+	m.stateMu.Unlock()
+
+	if drained == nil {
+		return nil
+	}
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tasks still in flight %v: %w", m.ActiveTasks(), ctx.Err())
+	}
+}
+
+// OnShutdown registers fn to run when the process shuts down: in Shutdown, or
+// when the idle timeout stops the machine, before StopMachine is called.
+// Requests through Middleware are refused while they run, so the hooks may
+// close resources such as the database. Hooks run once, in reverse order of
+// registration, so a hook registered after opening a resource runs before the
+// hooks of resources it depends on. If the stop then fails the manager serves
+// again, but the hooks do not run a second time.
+func (m *Manager) OnShutdown(name string, fn func(ctx context.Context) error) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+
+	m.hooks = append(m.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// runShutdownHooks runs the hooks unless they already ran, and returns their
+// errors joined. Every hook runs even if an earlier one fails.
+func (m *Manager) runShutdownHooks(ctx context.Context) error {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+
+	if m.hooksDone {
+		return nil
+	}
+	m.hooksDone = true
+
+	var errs []error
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		hook := m.hooks[i]
+		if err := hook.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", hook.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown stops idle timeout handling and refuses new requests for good,
+// waits for in-flight tasks until ctx is done, and then runs the shutdown
+// hooks. The hooks run even if draining timed out, with their own 10 second
+// deadline. The returned error joins the drain and hook errors.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stateMu.Lock()
+	m.shuttingDown = true
+	m.stateMu.Unlock()
+	m.Stop()
+
+	if tasks := m.ActiveTasks(); len(tasks) > 0 {
+		slog.Info("shutting down, waiting for tasks", "tasks", tasks)
+	}
+	drainErr := m.Drain(ctx)
+	if drainErr != nil {
+		slog.Error("shutting down with tasks in flight", "error", drainErr)
+	}
+
+	hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownHookTimeout)
+	defer cancel()
+	return errors.Join(drainErr, m.runShutdownHooks(hookCtx))
+}
+
+// ShutdownOnSignal waits for SIGTERM or SIGINT, or for ctx to be done, and
+// then calls Shutdown, allowing drainTimeout for in-flight tasks. Call it from
+// main once the server is started and exit when it returns.
+func (m *Manager) ShutdownOnSignal(ctx context.Context, drainTimeout time.Duration) error {
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	<-sigCtx.Done()
+	stop()
+
+	slog.Info("shutdown signal received", "drain_timeout", drainTimeout)
+	drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+	defer cancel()
+	return m.Shutdown(drainCtx)
+}
@@ -0,0 +1,233 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagerBegin_OverlappingTasksKeepManagerRunning(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	stops := 0
+	m.stopMachineFn = func() error {
+		stops++
+		return nil
+	}
+
+	endFirst := m.Begin("scrape")
+	endSecond := m.Begin("scrape")
+	endPredict := m.Begin("predict")
+	if got, want := m.ActiveTasks(), []string{"predict", "scrape", "scrape"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ActiveTasks() = %v, want %v", got, want)
+	}
+
+	endFirst()
+	endFirst()
+	endPredict()
+	if !m.IsRunning() {
+		t.Fatal("expected manager to be running while the second scrape is in flight")
+	}
+
+	m.timerVersion = 1
+	m.idleTimer = time.NewTimer(time.Hour)
+	m.onIdleTimeout(1)
+	if stops != 0 {
+		t.Fatalf("stopMachineFn calls = %d, want 0 while a task is in flight", stops)
+	}
+
+	endSecond()
+	if m.IsRunning() || len(m.ActiveTasks()) != 0 {
+		t.Fatalf("expected no tasks in flight, got %v", m.ActiveTasks())
+	}
+	m.onIdleTimeout(m.timerVersion)
+	if stops != 1 {
+		t.Fatalf("stopMachineFn calls = %d, want 1", stops)
+	}
+}
+
+func TestManagerShutdown_DrainsTasksThenRunsHooksInReverse(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var order []string
+	m.OnShutdown("db", func(context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	m.OnShutdown("cache", func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Error("hook context already done")
+		}
+		order = append(order, "cache")
+		return errors.New("flush failed")
+	})
+
+	end := m.Begin("scrape")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		order = append(order, "task")
+		end()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "shutdown hook cache: flush failed") {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if want := []string{"task", "cache", "db"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+
+	// Hooks run only once, and no new request is served.
+	if err := m.Shutdown(ctx); err != nil || len(order) != 3 {
+		t.Fatalf("second Shutdown() = %v, order %v", err, order)
+	}
+	if _, ok := m.beginServing("late"); ok {
+		t.Fatal("beginServing() after Shutdown started a task")
+	}
+}
+
+func TestManagerShutdown_RunsHooksWhenDrainTimesOut(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	hookRan := false
+	m.OnShutdown("db", func(context.Context) error {
+		hookRan = true
+		return nil
+	})
+	defer m.Begin("stuck")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("Shutdown() error = %v, want deadline naming the stuck task", err)
+	}
+	if !hookRan {
+		t.Fatal("expected shutdown hook to run after drain timeout")
+	}
+}
+
+func TestManagerOnIdleTimeout_RunsHooksBeforeStopAndResumesIfItFails(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name         string
+		stopErr      error
+		shuttingDown bool
+	}{
+		{"stopped", nil, true},
+		{"no stopper", ErrNotOnFly, false},
+		{"failed", errors.New("flaps unavailable"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := New(time.Hour)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			defer m.Stop()
+			var order []string
+			m.OnShutdown("db", func(context.Context) error {
+				order = append(order, "db")
+				if _, ok := m.beginServing("late"); ok {
+					t.Error("beginServing() during shutdown hooks started a task")
+				}
+				return nil
+			})
+			m.stopMachineFn = func() error {
+				order = append(order, "stop")
+				return tt.stopErr
+			}
+
+			m.timerVersion = 1
+			m.idleTimer = time.NewTimer(time.Hour)
+			m.onIdleTimeout(1)
+			if want := []string{"db", "stop"}; !reflect.DeepEqual(order, want) {
+				t.Fatalf("order = %v, want %v", order, want)
+			}
+			status := m.Status()
+			if status.ShuttingDown != tt.shuttingDown || status.Stopping {
+				t.Fatalf("Status() = %+v, want shutting down %v", status, tt.shuttingDown)
+			}
+			end, ok := m.beginServing("next")
+			if ok == tt.shuttingDown {
+				t.Fatalf("beginServing() after stop ok = %v, want %v", ok, !tt.shuttingDown)
+			}
+			if ok {
+				end()
+			}
+		})
+	}
+}
+
+func TestManagerStopMachine_RunsHooksBeforeExit(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var order []string
+	m.OnShutdown("db", func(context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	m.SetStopper(&ExitStopper{exit: func(int) { order = append(order, "exit") }})
+
+	if err := m.StopMachine(); err != nil {
+		t.Fatalf("StopMachine() error = %v", err)
+	}
+	if want := []string{"db", "exit"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	if !m.Status().ShuttingDown {
+		t.Fatal("expected manager to be shutting down before exit")
+	}
+}
+
+func TestManagerShutdownOnSignal_ShutsDownWhenContextDone(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	hookRan := make(chan struct{})
+	m.OnShutdown("db", func(context.Context) error {
+		close(hookRan)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.ShutdownOnSignal(ctx, time.Second) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ShutdownOnSignal() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ShutdownOnSignal() did not return")
+	}
+	<-hookRan
+}
@@ -0,0 +1,233 @@
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stopTimeout bounds StopMachine, including retries.
+const stopTimeout = 10 * time.Second
+
+// Environment variables read by DetectStopper.
+const (
+	// EnvStopper selects the stopper: "fly", "webhook", "exit" or "none".
+	// Unset means detect it from the environment.
+	EnvStopper = "MACHINE_STOPPER"
+	// EnvStopWebhookURL is the URL the webhook stopper POSTs to.
+	EnvStopWebhookURL = "MACHINE_STOP_WEBHOOK_URL"
+	// EnvStopWebhookToken, if set, is sent as a bearer token to the webhook.
+	EnvStopWebhookToken = "MACHINE_STOP_WEBHOOK_TOKEN"
+	// EnvFlySuspend, if true, makes the fly stopper suspend the machine
+	// instead of stopping it.
+	EnvFlySuspend = "MACHINE_FLY_SUSPEND"
+)
+
+// Stopper stops the machine the process runs on when it has been idle.
+type Stopper interface {
+	// Name identifies the stopper in logs and errors, e.g. "fly".
+	Name() string
+	// Stop asks for the machine to be stopped. It returns once the request is
+	// accepted; the process may keep running for a while afterwards.
+	Stop(ctx context.Context) error
+}
+
+// DetectStopper picks a Stopper from the environment, read with lookupEnv (nil
+// means os.LookupEnv). MACHINE_STOPPER selects one explicitly. Otherwise it
+// is, in order:
+//
+//   - fly, when FLY_APP_NAME and FLY_MACHINE_ID are set;
+//   - webhook, when MACHINE_STOP_WEBHOOK_URL is set;
+//   - exit, when the process was socket-activated by systemd (LISTEN_FDS is
+//     set), which starts it again on the next connection.
+//
+// Exiting to scale to zero under Kubernetes or by hand must be selected with
+// MACHINE_STOPPER=exit. DetectStopper returns ErrNoStopper when nothing
+// matches or MACHINE_STOPPER is "none".
+func DetectStopper(lookupEnv func(key string) (string, bool)) (Stopper, error) {
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	getenv := func(key string) string {
+		v, _ := lookupEnv(key)
+		return strings.TrimSpace(v)
+	}
+
+	switch kind := strings.ToLower(getenv(EnvStopper)); kind {
+	case "fly":
+		return flyStopperFromEnv(lookupEnv)
+	case "webhook":
+		return webhookStopperFromEnv(getenv)
+	case "exit":
+		return &ExitStopper{}, nil
+	case "none":
+		return nil, fmt.Errorf("%s=none: %w", EnvStopper, ErrNoStopper)
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown %s %q (want fly, webhook, exit or none)", EnvStopper, kind)
+	}
+
+	if fly, err := flyStopperFromEnv(lookupEnv); err == nil {
+		return fly, nil
+	}
+	if getenv(EnvStopWebhookURL) != "" {
+		return webhookStopperFromEnv(getenv)
+	}
+	if getenv("LISTEN_FDS") != "" {
+		return &ExitStopper{}, nil
+	}
+	return nil, ErrNoStopper
+}
+
+// FlyStopper stops a Fly.io machine through the Machines API.
+type FlyStopper struct {
+	AppName   string
+	MachineID string
+	// SocketPath is the Machines API Unix socket. Empty means /.fly/api.
+	SocketPath string
+	// Suspend suspends the machine instead of stopping it, so it resumes
+	// faster on the next request.
+	Suspend bool
+}
+
+func flyStopperFromEnv(lookupEnv func(key string) (string, bool)) (*FlyStopper, error) {
+	appName, _ := lookupEnv("FLY_APP_NAME")
+	machineID, _ := lookupEnv("FLY_MACHINE_ID")
+	if appName == "" || machineID == "" {
+		return nil, ErrNotOnFly
+	}
+	s := &FlyStopper{AppName: appName, MachineID: machineID}
+	if v, ok := lookupEnv(EnvFlySuspend); ok && strings.TrimSpace(v) != "" {
+		suspend, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", EnvFlySuspend, v, err)
+		}
+		s.Suspend = suspend
+	}
+	return s, nil
+}
+
+// Name returns "fly".
+func (s *FlyStopper) Name() string { return "fly" }
+
+// Stop requests the machine stop, or suspend if s.Suspend is set.
+func (s *FlyStopper) Stop(ctx context.Context) error {
+	client := NewFlyClient(s.AppName, s.MachineID, s.SocketPath)
+	if s.Suspend {
+		if err := client.Suspend(ctx); err != nil {
+			return fmt.Errorf("request machine suspend: %w", err)
+		}
+		slog.Info("machine suspend requested")
+		return nil
+	}
+	if err := client.Stop(ctx); err != nil {
+		return fmt.Errorf("request machine stop: %w", err)
+	}
+	slog.Info("machine stop requested")
+	return nil
+}
+
+// WebhookStopper asks an external service to stop the machine by POSTing a
+// JSON body with the hostname and reason to URL. Any 2xx response is success.
+type WebhookStopper struct {
+	URL string
+	// Header is added to the request, e.g. an Authorization header.
+	Header http.Header
+	// Client sends the request. Nil means a client with a 10 second timeout.
+	Client *http.Client
+}
+
+func webhookStopperFromEnv(getenv func(string) string) (*WebhookStopper, error) {
+	url := getenv(EnvStopWebhookURL)
+	if url == "" {
+		return nil, fmt.Errorf("%s is required for the webhook stopper", EnvStopWebhookURL)
+	}
+	s := &WebhookStopper{URL: url}
+	if token := getenv(EnvStopWebhookToken); token != "" {
+		s.Header = http.Header{"Authorization": {"Bearer " + token}}
+	}
+	return s, nil
+}
+
+// Name returns "webhook".
+func (s *WebhookStopper) Name() string { return "webhook" }
+
+// Stop sends the webhook.
+func (s *WebhookStopper) Stop(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	body, err := json.Marshal(struct {
+		Hostname string `json:"hostname"`
+		Reason   string `json:"reason"`
+	}{hostname, "idle"})
+	if err != nil {
+		return fmt.Errorf("encode webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: stopTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected webhook status: %s", resp.Status)
+	}
+
+	slog.Info("machine stop requested via webhook", "status", resp.Status)
+	return nil
+}
+
+// processExiter is implemented by stoppers whose Stop ends the process, so the
+// shutdown hooks must run before it rather than after.
+type processExiter interface {
+	exitsProcess()
+}
+
+// ExitStopper stops the machine by exiting the process, for platforms that
+// scale to zero when it exits: systemd socket activation, Kubernetes with an
+// external autoscaler, or running by hand. Manager.StopMachine runs the
+// shutdown hooks before calling Stop.
+type ExitStopper struct {
+	// Code is the exit status. Zero tells the supervisor the process finished
+	// cleanly rather than crashed.
+	Code int
+
+	exit func(int)
+}
+
+// Name returns "exit".
+func (s *ExitStopper) Name() string { return "exit" }
+
+func (s *ExitStopper) exitsProcess() {}
+
+// Stop exits the process, so it only returns in tests.
+func (s *ExitStopper) Stop(context.Context) error {
+	exit := s.exit
+	if exit == nil {
+		exit = os.Exit
+	}
+	slog.Info("exiting idle process", "code", s.Code)
+	exit(s.Code)
+	return nil
+}
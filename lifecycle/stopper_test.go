@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// serveUnix serves handler on a Unix socket in a temporary directory and
+// returns the socket path.
+func serveUnix(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", socket, err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return socket
+}
+
+func mapEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestDetectStopper(t *testing.T) {
+	t.Parallel()
+
+	fly := map[string]string{"FLY_APP_NAME": "app", "FLY_MACHINE_ID": "m1"}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr error
+	}{
+		{"fly", fly, "fly", nil},
+		{"fly before webhook", map[string]string{"FLY_APP_NAME": "app", "FLY_MACHINE_ID": "m1", EnvStopWebhookURL: "http://x"}, "fly", nil},
+		{"webhook", map[string]string{EnvStopWebhookURL: "http://x"}, "webhook", nil},
+		{"socket activated", map[string]string{"LISTEN_FDS": "1"}, "exit", nil},
+		{"explicit exit", map[string]string{EnvStopper: "Exit", "FLY_APP_NAME": "app", "FLY_MACHINE_ID": "m1"}, "exit", nil},
+		{"explicit none", map[string]string{EnvStopper: "none", "FLY_APP_NAME": "app", "FLY_MACHINE_ID": "m1"}, "", ErrNoStopper},
+		{"explicit fly off Fly", map[string]string{EnvStopper: "fly"}, "", ErrNotOnFly},
+		{"nothing", map[string]string{}, "", ErrNoStopper},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := DetectStopper(mapEnv(tt.env))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("DetectStopper() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || s.Name() != tt.want {
+				t.Fatalf("DetectStopper() = %v, %v, want %s", s, err, tt.want)
+			}
+		})
+	}
+
+	for _, env := range []map[string]string{{EnvStopper: "webhook"}, {EnvStopper: "k8s"}} {
+		if _, err := DetectStopper(mapEnv(env)); err == nil || errors.Is(err, ErrNoStopper) {
+			t.Errorf("DetectStopper(%v) error = %v, want a configuration error", env, err)
+		}
+	}
+}
+
+func TestFlyStopper_StopsOverUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	var gotPath string
+	socket := serveUnix(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	s := &FlyStopper{AppName: "app", MachineID: "m1", SocketPath: socket}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if want := "POST /v1/apps/app/machines/m1/stop"; gotPath != want {
+		t.Fatalf("request = %q, want %q", gotPath, want)
+	}
+}
+
+func TestWebhookStopper_PostsJSONWithHeaders(t *testing.T) {
+	t.Parallel()
+
+	status := http.StatusNoContent
+	var body map[string]string
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	s, err := DetectStopper(mapEnv(map[string]string{EnvStopWebhookURL: srv.URL, EnvStopWebhookToken: "secret"}))
+	if err != nil {
+		t.Fatalf("DetectStopper() error = %v", err)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if auth != "Bearer secret" || body["reason"] != "idle" {
+		t.Fatalf("webhook got auth %q, body %v", auth, body)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := s.Stop(context.Background()); err == nil {
+		t.Fatal("Stop() on 503 expected error")
+	}
+}
+
+func TestManagerStopMachine_UsesStopper(t *testing.T) {
+	t.Parallel()
+
+	m, err := New(time.Hour)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	exited := -1
+	m.SetStopper(&ExitStopper{Code: 3, exit: func(code int) { exited = code }})
+	var kinds []EventKind
+	m.Observe(func(e Event) { kinds = append(kinds, e.Kind) })
+
+	m.SetStopper(stopperFunc(func(context.Context) error { return ErrNoStopper }))
+	m.timerVersion = 1
+	m.idleTimer = time.NewTimer(time.Hour)
+	m.onIdleTimeout(1)
+
+	m.SetStopper(&ExitStopper{Code: 3, exit: func(code int) { exited = code }})
+	m.ResetIdleTimer()
+	m.onIdleTimeout(m.Status().TimerVersion)
+	if exited != 3 {
+		t.Fatalf("exit code = %d, want 3", exited)
+	}
+	if want := []EventKind{EventIdle, EventStopSkipped, EventIdle, EventStopRequested}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("events = %v, want %v", kinds, want)
+	}
+}
+
+type stopperFunc func(context.Context) error
+
+func (f stopperFunc) Name() string                   { return "func" }
+func (f stopperFunc) Stop(ctx context.Context) error { return f(ctx) }
@@ -0,0 +1,256 @@
+// Package migrations embeds the versioned SQL schema shared by the scraper and
+// web projects and applies it to a database at startup.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/dialect"
+)
+
+// Each dialect has its own copy of the schema under sql/<dialect>; both
+// directories must contain the same versions.
+//
+//go:embed sql/sqlite/*.sql sql/postgres/*.sql
+var sqlFiles embed.FS
+
+// Migration is a single versioned schema change.
+// Files are named "NNNN_description.sql"; NNNN is the version.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+const createSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at %s NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// schemaMigrationsDDL returns the schema_migrations definition for d.
+func schemaMigrationsDDL(d dialect.Dialect) string {
+	if d == dialect.Postgres {
+		return fmt.Sprintf(createSchemaMigrations, "TIMESTAMPTZ")
+	}
+	return fmt.Sprintf(createSchemaMigrations, "DATETIME")
+}
+
+// hasSchemaMigrations reports whether schema_migrations exists, without
+// creating it.
+func hasSchemaMigrations(ctx context.Context, db *sql.DB, d dialect.Dialect) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	if d == dialect.Postgres {
+		query = `SELECT COUNT(*) FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return false, fmt.Errorf("look up schema_migrations: %w", err)
+	}
+	return n > 0, nil
+}
+
+// All returns the embedded SQLite migrations ordered by version ascending.
+func All() ([]Migration, error) {
+	return AllDialect(dialect.SQLite)
+}
+
+// AllDialect returns the embedded migrations for d ordered by version ascending.
+func AllDialect(d dialect.Dialect) ([]Migration, error) {
+	dir := path.Join("sql", d.String())
+	entries, err := fs.ReadDir(sqlFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read embedded migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		version, name, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if prev, dup := seen[version]; dup {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, prev, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		body, err := fs.ReadFile(sqlFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFileName splits "0001_initial_schema.sql" into (1, "initial_schema").
+func parseFileName(fileName string) (int, string, error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	prefix, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", fmt.Errorf("migration file %q must be named NNNN_description.sql", fileName)
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version <= 0 {
+		return 0, "", fmt.Errorf("migration file %q has invalid version %q", fileName, prefix)
+	}
+	return version, name, nil
+}
+
+// Migrate applies every embedded migration that is not yet recorded in
+// schema_migrations. Each migration runs in its own transaction together with
+// its bookkeeping row, so a failed migration leaves no partial state behind.
+// Migrate is safe to call on every startup.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	return MigrateDialect(ctx, db, dialect.SQLite)
+}
+
+// MigrateDialect is Migrate for a database of the given dialect.
+func MigrateDialect(ctx context.Context, db *sql.DB, d dialect.Dialect) error {
+	migrations, err := AllDialect(d)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, schemaMigrationsDDL(d)); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := apply(ctx, db, d, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply runs a single migration and records it in one transaction.
+// The version is re-checked inside the transaction so that two processes
+// migrating the same database at startup do not apply it twice.
+func apply(ctx context.Context, db *sql.DB, d dialect.Dialect, m Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migration %04d: %w", m.Version, err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var exists int
+	err = tx.QueryRowContext(ctx, d.Rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), m.Version).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check migration %04d: %w", m.Version, err)
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("apply migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		d.Rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"),
+		m.Version, m.Name,
+	); err != nil {
+		return fmt.Errorf("record migration %04d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit migration %04d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// Status reports every embedded migration and whether it has been applied.
+// A database that has never been migrated reports all migrations as pending;
+// Status only reads, so it does not create schema_migrations.
+func Status(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	return StatusDialect(ctx, db, dialect.SQLite)
+}
+
+// StatusDialect is Status for a database of the given dialect.
+func StatusDialect(ctx context.Context, db *sql.DB, d dialect.Dialect) ([]MigrationStatus, error) {
+	migrations, err := AllDialect(d)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := hasSchemaMigrations(ctx, db, d)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	if exists {
+		if applied, err = appliedVersions(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate schema_migrations rows: %w", err)
+	}
+	return applied, nil
+}
@@ -0,0 +1,233 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/amaumene/snowfinder_common/dialect"
+	_ "modernc.org/sqlite"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	// A single connection keeps every query on the same in-memory database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestAll_OrderedAndNamed(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected at least one embedded migration")
+	}
+	for i, m := range migrations {
+		if m.Name == "" || m.SQL == "" {
+			t.Fatalf("migration %d has empty name or SQL: %+v", m.Version, m)
+		}
+		if i > 0 && migrations[i-1].Version >= m.Version {
+			t.Fatalf("migrations out of order: %d before %d", migrations[i-1].Version, m.Version)
+		}
+	}
+}
+
+func TestAllDialect_VersionsMatchAcrossDialects(t *testing.T) {
+	t.Parallel()
+
+	sqlite, err := AllDialect(dialect.SQLite)
+	if err != nil {
+		t.Fatalf("AllDialect(sqlite) error = %v", err)
+	}
+	postgres, err := AllDialect(dialect.Postgres)
+	if err != nil {
+		t.Fatalf("AllDialect(postgres) error = %v", err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("sqlite has %d migrations, postgres has %d", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].Version != postgres[i].Version || sqlite[i].Name != postgres[i].Name {
+			t.Fatalf("migration %d differs: sqlite %04d_%s, postgres %04d_%s",
+				i, sqlite[i].Version, sqlite[i].Name, postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	t.Parallel()
+
+	version, name, err := parseFileName("0007_add_things.sql")
+	if err != nil {
+		t.Fatalf("parseFileName() error = %v", err)
+	}
+	if version != 7 || name != "add_things" {
+		t.Fatalf("parseFileName() = (%d, %q)", version, name)
+	}
+
+	for _, bad := range []string{"initial.sql", "abc_initial.sql", "0000_zero.sql", "0001_.sql"} {
+		if _, _, err := parseFileName(bad); err == nil {
+			t.Fatalf("parseFileName(%q) expected error", bad)
+		}
+	}
+}
+
+func TestMigrate_CreatesTablesAndIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("first Migrate() error = %v", err)
+	}
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+
+	for _, table := range []string{
+		"resorts", "daily_snowfall", "snow_depth_readings", "resort_peak_periods",
+		"failed_scrape_attempts", "predictions", "prediction_config", "prediction_global_params",
+	} {
+		var name string
+		err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&name)
+		if err != nil {
+			t.Fatalf("table %s missing after Migrate(): %v", table, err)
+		}
+	}
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&count); err != nil {
+		t.Fatalf("count schema_migrations: %v", err)
+	}
+	if count != len(migrations) {
+		t.Fatalf("schema_migrations rows = %d, want %d", count, len(migrations))
+	}
+}
+
+func TestStatus_ReportsPendingThenApplied(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	before, err := Status(ctx, db)
+	if err != nil {
+		t.Fatalf("Status() before Migrate error = %v", err)
+	}
+	for _, s := range before {
+		if s.Applied || s.AppliedAt != nil {
+			t.Fatalf("migration %d reported applied before Migrate()", s.Version)
+		}
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		t.Fatalf("count tables: %v", err)
+	}
+	if tables != 0 {
+		t.Fatalf("Status() left %d tables in an unmigrated database, want 0", tables)
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	after, err := Status(ctx, db)
+	if err != nil {
+		t.Fatalf("Status() after Migrate error = %v", err)
+	}
+	if len(after) != len(before) {
+		t.Fatalf("Status() returned %d entries, want %d", len(after), len(before))
+	}
+	for _, s := range after {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Fatalf("migration %d not reported applied after Migrate()", s.Version)
+		}
+	}
+}
+
+func TestMigrate_MergesPendingScrapeAttemptsPerURL(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All() error = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, schemaMigrationsDDL(dialect.SQLite)); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 6 {
+			break
+		}
+		if err := apply(ctx, db, dialect.SQLite, m); err != nil {
+			t.Fatalf("apply(%04d) error = %v", m.Version, err)
+		}
+	}
+
+	// Before retry scheduling, every failure of a URL inserted a row.
+	for _, row := range []struct {
+		id, url, failedAt string
+		retried           bool
+	}{
+		{"a1", "https://example.com/a", "2024-01-01 00:00:00", false},
+		{"a2", "https://example.com/a", "2024-01-03 00:00:00", false},
+		{"a3", "https://example.com/a", "2024-01-02 00:00:00", false},
+		{"a0", "https://example.com/a", "2023-12-01 00:00:00", true},
+		{"b1", "https://example.com/b", "2024-01-01 00:00:00", false},
+	} {
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO failed_scrape_attempts (id, resort_url, error_message, failed_at, retried) VALUES (?, ?, 'timeout', ?, ?)",
+			row.id, row.url, row.failedAt, row.retried,
+		); err != nil {
+			t.Fatalf("insert %s: %v", row.id, err)
+		}
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT id, attempts FROM failed_scrape_attempts ORDER BY id")
+	if err != nil {
+		t.Fatalf("query failed_scrape_attempts: %v", err)
+	}
+	defer rows.Close()
+	got := map[string]int{}
+	for rows.Next() {
+		var id string
+		var attempts int
+		if err := rows.Scan(&id, &attempts); err != nil {
+			t.Fatalf("scan failed_scrape_attempts: %v", err)
+		}
+		got[id] = attempts
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("iterate failed_scrape_attempts: %v", err)
+	}
+	if len(got) != 3 || got["a2"] != 3 || got["a0"] != 1 || got["b1"] != 1 {
+		t.Fatalf("attempts after Migrate() = %v, want a0:1 a2:3 b1:1", got)
+	}
+
+	if _, err := db.ExecContext(ctx,
+		"INSERT INTO failed_scrape_attempts (id, resort_url, error_message) VALUES ('a4', 'https://example.com/a', 'timeout')",
+	); err == nil {
+		t.Fatal("second open attempt for a URL inserted, want unique index violation")
+	}
+}
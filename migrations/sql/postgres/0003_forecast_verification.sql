@@ -0,0 +1,19 @@
+-- Forecast skill metrics per resort and lead time, computed by comparing
+-- prediction_history against observed daily_snowfall.
+
+CREATE TABLE IF NOT EXISTS forecast_verification (
+	resort_id TEXT NOT NULL,
+	lead_days INTEGER NOT NULL CHECK (lead_days >= 0),
+	sample_count INTEGER NOT NULL,
+	mae_cm DOUBLE PRECISION NOT NULL,
+	bias_cm DOUBLE PRECISION NOT NULL,
+	range_sample_count INTEGER NOT NULL DEFAULT 0,
+	range_hit_rate DOUBLE PRECISION,
+	brier_sample_count INTEGER NOT NULL DEFAULT 0,
+	brier_5cm DOUBLE PRECISION,
+	brier_10cm DOUBLE PRECISION,
+	brier_20cm DOUBLE PRECISION,
+	brier_30cm DOUBLE PRECISION,
+	computed_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (resort_id, lead_days)
+);
@@ -0,0 +1,39 @@
+-- Powder alerts: rules defined by users and the events fired when a
+-- prediction run matches them. An event is unique per rule, run and
+-- resort-day, so re-evaluating the same run never fires it twice.
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	resort_id TEXT NOT NULL DEFAULT '',
+	prefecture TEXT NOT NULL DEFAULT '',
+	threshold_cm INTEGER NOT NULL CHECK (threshold_cm IN (5, 10, 20, 30)),
+	min_probability_pct INTEGER NOT NULL CHECK (min_probability_pct BETWEEN 1 AND 100),
+	min_lead_days INTEGER NOT NULL DEFAULT 0 CHECK (min_lead_days >= 0),
+	max_lead_days INTEGER NOT NULL CHECK (max_lead_days >= min_lead_days),
+	enabled BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules (user_id);
+
+CREATE TABLE IF NOT EXISTS alert_events (
+	id TEXT PRIMARY KEY,
+	rule_id TEXT NOT NULL REFERENCES alert_rules (id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	run_generated_at TEXT NOT NULL,
+	resort_id TEXT NOT NULL,
+	resort_name TEXT NOT NULL DEFAULT '',
+	forecast_date TEXT NOT NULL,
+	lead_days INTEGER NOT NULL,
+	threshold_cm INTEGER NOT NULL,
+	probability_pct INTEGER NOT NULL,
+	snowfall_cm DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	delivered_at TIMESTAMPTZ,
+	UNIQUE (rule_id, run_generated_at, resort_id, forecast_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_pending ON alert_events (delivered_at, created_at);
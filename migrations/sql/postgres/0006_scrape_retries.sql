@@ -0,0 +1,45 @@
+-- Retry scheduling for failed scrapes. Each row tracks the failures of one
+-- resort URL: how many attempts failed, how the last error was classified,
+-- when the next retry is due, and whether retries were given up on
+-- (dead-lettered).
+
+ALTER TABLE failed_scrape_attempts ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE failed_scrape_attempts ADD COLUMN error_class TEXT NOT NULL DEFAULT 'transient';
+ALTER TABLE failed_scrape_attempts ADD COLUMN next_retry_at TIMESTAMPTZ;
+ALTER TABLE failed_scrape_attempts ADD COLUMN dead_lettered BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Attempts recorded before scheduling existed are due immediately.
+UPDATE failed_scrape_attempts
+SET next_retry_at = failed_at
+WHERE retried = FALSE;
+
+DROP INDEX IF EXISTS idx_failed_scrape_attempts_pending;
+CREATE INDEX IF NOT EXISTS idx_failed_scrape_attempts_due
+	ON failed_scrape_attempts (retried, dead_lettered, next_retry_at);
+
+-- Before scheduling existed every failure inserted a row. Fold the pending
+-- rows of each URL into its latest one, summing their attempts, so that a
+-- URL has at most one open attempt.
+UPDATE failed_scrape_attempts AS f
+SET attempts = (
+	SELECT SUM(d.attempts) FROM failed_scrape_attempts AS d
+	WHERE d.resort_url = f.resort_url AND d.retried = FALSE AND d.dead_lettered = FALSE
+)
+WHERE f.retried = FALSE AND f.dead_lettered = FALSE AND f.id = (
+	SELECT l.id FROM failed_scrape_attempts AS l
+	WHERE l.resort_url = f.resort_url AND l.retried = FALSE AND l.dead_lettered = FALSE
+	ORDER BY l.failed_at DESC, l.id DESC
+	LIMIT 1
+);
+
+DELETE FROM failed_scrape_attempts AS f
+WHERE f.retried = FALSE AND f.dead_lettered = FALSE AND f.id <> (
+	SELECT l.id FROM failed_scrape_attempts AS l
+	WHERE l.resort_url = f.resort_url AND l.retried = FALSE AND l.dead_lettered = FALSE
+	ORDER BY l.failed_at DESC, l.id DESC
+	LIMIT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_failed_scrape_attempts_open_url
+	ON failed_scrape_attempts (resort_url)
+	WHERE retried = FALSE AND dead_lettered = FALSE;
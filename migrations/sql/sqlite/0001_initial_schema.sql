@@ -0,0 +1,82 @@
+-- Core tables shared by the scraper and web projects.
+
+CREATE TABLE IF NOT EXISTS resorts (
+	id TEXT PRIMARY KEY,
+	slug TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	prefecture TEXT NOT NULL DEFAULT '',
+	region TEXT NOT NULL DEFAULT '',
+	top_elevation_m INTEGER,
+	base_elevation_m INTEGER,
+	vertical_m INTEGER,
+	num_courses INTEGER,
+	longest_course_km REAL,
+	steepest_course_deg REAL,
+	last_updated DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_resorts_prefecture ON resorts (prefecture);
+
+CREATE TABLE IF NOT EXISTS daily_snowfall (
+	resort_id TEXT NOT NULL REFERENCES resorts (id) ON DELETE CASCADE,
+	date DATE NOT NULL,
+	snowfall_cm INTEGER NOT NULL,
+	PRIMARY KEY (resort_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS snow_depth_readings (
+	resort_id TEXT NOT NULL REFERENCES resorts (id) ON DELETE CASCADE,
+	date DATE NOT NULL,
+	depth_cm INTEGER NOT NULL,
+	PRIMARY KEY (resort_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS resort_peak_periods (
+	id TEXT PRIMARY KEY,
+	resort_id TEXT NOT NULL REFERENCES resorts (id) ON DELETE CASCADE,
+	peak_rank INTEGER NOT NULL,
+	start_doy INTEGER NOT NULL CHECK (start_doy BETWEEN 1 AND 366),
+	end_doy INTEGER NOT NULL CHECK (end_doy BETWEEN 1 AND 366),
+	center_doy INTEGER NOT NULL CHECK (center_doy BETWEEN 1 AND 366),
+	avg_daily_snowfall REAL NOT NULL,
+	total_period_snowfall REAL NOT NULL,
+	prominence_score REAL NOT NULL,
+	years_of_data INTEGER NOT NULL,
+	confidence_level TEXT NOT NULL,
+	reliability_score REAL NOT NULL,
+	winters_present INTEGER NOT NULL,
+	total_winters INTEGER NOT NULL,
+	regional_consistency REAL NOT NULL,
+	calculated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (resort_id, peak_rank)
+);
+
+CREATE TABLE IF NOT EXISTS failed_scrape_attempts (
+	id TEXT PRIMARY KEY,
+	resort_url TEXT NOT NULL,
+	error_message TEXT NOT NULL,
+	failed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	retried BOOLEAN NOT NULL DEFAULT FALSE,
+	retried_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_failed_scrape_attempts_pending
+	ON failed_scrape_attempts (retried, failed_at);
+
+CREATE TABLE IF NOT EXISTS predictions (
+	resort_id TEXT PRIMARY KEY,
+	prediction_data BLOB NOT NULL,
+	generated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS prediction_config (
+	resort_id TEXT PRIMARY KEY,
+	config_data BLOB NOT NULL,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS prediction_global_params (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	params_data BLOB NOT NULL,
+	updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- Versioned prediction history: one prediction_runs row per PredictionData
+-- run, with per-resort forecasts linked to the run in prediction_history.
+
+CREATE TABLE IF NOT EXISTS prediction_runs (
+	id TEXT PRIMARY KEY,
+	generated_at DATETIME NOT NULL,
+	source TEXT NOT NULL DEFAULT '',
+	forecast_days INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_prediction_runs_generated_at ON prediction_runs (generated_at);
+
+CREATE TABLE IF NOT EXISTS prediction_history (
+	run_id TEXT NOT NULL REFERENCES prediction_runs (id) ON DELETE CASCADE,
+	resort_id TEXT NOT NULL,
+	prediction_data BLOB NOT NULL,
+	PRIMARY KEY (run_id, resort_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_prediction_history_resort ON prediction_history (resort_id);
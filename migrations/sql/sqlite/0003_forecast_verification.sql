@@ -0,0 +1,19 @@
+-- Forecast skill metrics per resort and lead time, computed by comparing
+-- prediction_history against observed daily_snowfall.
+
+CREATE TABLE IF NOT EXISTS forecast_verification (
+	resort_id TEXT NOT NULL,
+	lead_days INTEGER NOT NULL CHECK (lead_days >= 0),
+	sample_count INTEGER NOT NULL,
+	mae_cm REAL NOT NULL,
+	bias_cm REAL NOT NULL,
+	range_sample_count INTEGER NOT NULL DEFAULT 0,
+	range_hit_rate REAL,
+	brier_sample_count INTEGER NOT NULL DEFAULT 0,
+	brier_5cm REAL,
+	brier_10cm REAL,
+	brier_20cm REAL,
+	brier_30cm REAL,
+	computed_at DATETIME NOT NULL,
+	PRIMARY KEY (resort_id, lead_days)
+);
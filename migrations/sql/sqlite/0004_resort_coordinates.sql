@@ -0,0 +1,10 @@
+-- Resort coordinates for nearest-resort and map queries. geo_cell is the
+-- resort's cell in a 0.5 degree latitude/longitude grid, computed by the
+-- repository, so spatial lookups can use a plain index.
+
+ALTER TABLE resorts ADD COLUMN latitude REAL;
+ALTER TABLE resorts ADD COLUMN longitude REAL;
+ALTER TABLE resorts ADD COLUMN geo_cell INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_resorts_geo_cell ON resorts (geo_cell);
+CREATE INDEX IF NOT EXISTS idx_resorts_latitude ON resorts (latitude);
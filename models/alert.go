@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// AlertRule asks for an alert when a resort is likely to get more than
+// ThresholdCM of snow on a day within the rule's lead-time window.
+type AlertRule struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// ResortID and Prefecture restrict the resorts the rule applies to; empty
+	// values match every resort.
+	ResortID   string `json:"resort_id"`
+	Prefecture string `json:"prefecture"`
+	// ThresholdCM is one of the PowderProb thresholds: 5, 10, 20 or 30.
+	ThresholdCM int `json:"threshold_cm"`
+	// MinProbabilityPct is the lowest probability of exceeding ThresholdCM,
+	// from 1 to 100, that fires the rule.
+	MinProbabilityPct int `json:"min_probability_pct"`
+	// MinLeadDays and MaxLeadDays bound (inclusive) the number of days
+	// between the run's generation date and the forecast date.
+	MinLeadDays int       `json:"min_lead_days"`
+	MaxLeadDays int       `json:"max_lead_days"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AlertEvent records an AlertRule firing for one resort-day of a prediction
+// run. RunGeneratedAt is the run's PredictionData.GeneratedAt and, together
+// with RuleID, ResortID and ForecastDate, identifies the event.
+// DeliveredAt is nil until the web app has delivered the alert.
+type AlertEvent struct {
+	ID             string `json:"id"`
+	RuleID         string `json:"rule_id"`
+	UserID         string `json:"user_id"`
+	RunGeneratedAt string `json:"run_generated_at"`
+	ResortID       string `json:"resort_id"`
+	ResortName     string `json:"resort_name"`
+	// ForecastDate is formatted as "YYYY-MM-DD".
+	ForecastDate   string     `json:"forecast_date"`
+	LeadDays       int        `json:"lead_days"`
+	ThresholdCM    int        `json:"threshold_cm"`
+	ProbabilityPct int        `json:"probability_pct"`
+	SnowfallCM     float64    `json:"snowfall_cm"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
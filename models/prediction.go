@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // PredictionData holds the full predictions output.
 type PredictionData struct {
 	// GeneratedAt is the RFC3339 timestamp when the predictions were generated.
@@ -28,6 +30,12 @@ type Prediction struct {
 	HourlyRain         []float64       `json:"hourly_rain"`
 	HourlyApparentTemp []float64       `json:"hourly_apparent_temp"`
 	HourlyTimes        []string        `json:"hourly_times"`
+	// BiasFactors are the per-source bias factors the predictor applied to
+	// this forecast, a source missing from it having none applied. They are
+	// set by the caller of SavePredictionRun and kept in the prediction
+	// history only, so calibration can recover the raw model output; the
+	// served JSON leaves them out. Nil when the caller did not record them.
+	BiasFactors map[string]float64 `json:"-"`
 }
 
 // DailyForecast holds one day of forecast data.
@@ -67,3 +75,41 @@ type PowderProb struct {
 	Exceeds20cm int `json:"exceeds_20cm"`
 	Exceeds30cm int `json:"exceeds_30cm"`
 }
+
+// PredictionRun describes one stored PredictionData run in the prediction history.
+type PredictionRun struct {
+	ID           string    `json:"id"`
+	GeneratedAt  time.Time `json:"generated_at"`
+	Source       string    `json:"source"`
+	ForecastDays int       `json:"forecast_days"`
+	ResortCount  int       `json:"resort_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RegionForecast rolls up the stored predictions of the resorts in one
+// prefecture, or one region of a prefecture, over the next few days. Region is
+// empty when forecasts are grouped by prefecture.
+//
+// ResortCount is the number of resorts with a forecast for at least one day
+// of the window. TotalSnowfallCM sums every resort and day; AvgSnowfallCM is
+// the average per-resort total.
+type RegionForecast struct {
+	Prefecture      string              `json:"prefecture"`
+	Region          string              `json:"region"`
+	ResortCount     int                 `json:"resort_count"`
+	TotalSnowfallCM float64             `json:"total_snowfall_cm"`
+	AvgSnowfallCM   float64             `json:"avg_snowfall_cm"`
+	TopResort       *RegionTopResort    `json:"top_resort"`
+	Daily           []RegionForecastDay `json:"daily"`
+}
+
+// RegionForecastDay is the expected snowfall across a region on one day.
+// AvgSnowfallCM is taken over the resorts with a forecast for that day.
+type RegionForecastDay struct {
+	// Date is formatted as "YYYY-MM-DD".
+	Date            string  `json:"date"`
+	Resorts         int     `json:"resorts"`
+	TotalSnowfallCM float64 `json:"total_snowfall_cm"`
+	AvgSnowfallCM   float64 `json:"avg_snowfall_cm"`
+	MaxSnowfallCM   float64 `json:"max_snowfall_cm"`
+}
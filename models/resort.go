@@ -15,9 +15,17 @@ type Resort struct {
 	NumCourses        *int      `json:"num_courses"`
 	LongestCourseKM   *float64  `json:"longest_course_km"`
 	SteepestCourseDeg *float64  `json:"steepest_course_deg"`
+	Latitude          *float64  `json:"lat"`
+	Longitude         *float64  `json:"lon"`
 	LastUpdated       time.Time `json:"last_updated"`
 }
 
+// ResortDistance is a resort and its great-circle distance from a query point.
+type ResortDistance struct {
+	Resort     Resort  `json:"resort"`
+	DistanceKM float64 `json:"distance_km"`
+}
+
 // SnowDepthReading is a point-in-time snow depth measurement at a resort.
 type SnowDepthReading struct {
 	ResortID string    `json:"resort_id"`
@@ -75,12 +83,71 @@ type ResortWithPeaks struct {
 	Peaks  []PeakPeriod `json:"peaks"`
 }
 
-// FailedScrapeAttempt records a scrape that failed and whether it has been retried.
+// ScrapeErrorClass classifies why a scrape failed, which decides how it is retried.
+type ScrapeErrorClass string
+
+// Scrape error classes.
+const (
+	// ScrapeErrorTransient is a failure that may succeed on retry, such as a
+	// timeout, a connection error or a 5xx response.
+	ScrapeErrorTransient ScrapeErrorClass = "transient"
+	// ScrapeErrorParse is a page that was fetched but could not be parsed.
+	ScrapeErrorParse ScrapeErrorClass = "parse"
+	// ScrapeErrorNotFound is a page that does not exist.
+	ScrapeErrorNotFound ScrapeErrorClass = "not_found"
+)
+
+// FailedScrapeAttempt records a scrape that failed and tracks its retries.
+// Retried is set once a retry succeeds; DeadLettered once retries are given
+// up on. Until then the attempt is due for a retry at NextRetryAt.
 type FailedScrapeAttempt struct {
-	ID           string     `json:"id"`
-	ResortURL    string     `json:"resort_url"`
-	ErrorMessage string     `json:"error_message"`
+	ID           string `json:"id"`
+	ResortURL    string `json:"resort_url"`
+	ErrorMessage string `json:"error_message"`
+	// ErrorClass classifies the latest error.
+	ErrorClass ScrapeErrorClass `json:"error_class"`
+	// Attempts counts the failed scrapes, including the first.
+	Attempts int `json:"attempts"`
+	// FailedAt is the time of the latest failure.
 	FailedAt     time.Time  `json:"failed_at"`
+	NextRetryAt  *time.Time `json:"next_retry_at"`
+	DeadLettered bool       `json:"dead_lettered"`
 	Retried      bool       `json:"retried"`
 	RetriedAt    *time.Time `json:"retried_at"`
 }
+
+// SeasonSnowfallTotal is a resort's total recorded snowfall for one season.
+// Season is labelled by its winter, e.g. "2024-25" for July 2024 to June 2025.
+// Rank orders the seasons returned together, 1 being the snowiest.
+type SeasonSnowfallTotal struct {
+	ResortID        string `json:"resort_id"`
+	Season          string `json:"season"`
+	StartYear       int    `json:"start_year"`
+	TotalSnowfallCM int    `json:"total_snowfall_cm"`
+	DaysWithData    int    `json:"days_with_data"`
+	Rank            int    `json:"rank"`
+}
+
+// RegionSummary aggregates the recorded snowfall of the resorts in one
+// prefecture, or one region of a prefecture, over a date range. Region is
+// empty when summaries are grouped by prefecture.
+//
+// ResortCount includes resorts without any data in the range;
+// ReportingResorts only those with data, over which AvgSnowfallCM is taken.
+type RegionSummary struct {
+	Prefecture       string           `json:"prefecture"`
+	Region           string           `json:"region"`
+	ResortCount      int              `json:"resort_count"`
+	ReportingResorts int              `json:"reporting_resorts"`
+	TotalSnowfallCM  int              `json:"total_snowfall_cm"`
+	AvgSnowfallCM    float64          `json:"avg_snowfall_cm"`
+	TopResort        *RegionTopResort `json:"top_resort"`
+}
+
+// RegionTopResort is the resort with the most snowfall in a region summary or
+// forecast. SnowfallCM is its total over the same period.
+type RegionTopResort struct {
+	ResortID   string  `json:"resort_id"`
+	Name       string  `json:"name"`
+	SnowfallCM float64 `json:"snowfall_cm"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ForecastVerification holds forecast skill metrics for one resort at one lead time,
+// computed from stored prediction runs and the daily snowfall observed afterwards.
+// LeadDays is the number of days between the run's generation date and the forecast date.
+type ForecastVerification struct {
+	ResortID    string `json:"resort_id"`
+	LeadDays    int    `json:"lead_days"`
+	SampleCount int    `json:"sample_count"`
+	// MAECM is the mean absolute error of SnowfallCM against observed snowfall.
+	MAECM float64 `json:"mae_cm"`
+	// BiasCM is the mean of forecast minus observed snowfall; positive means over-forecast.
+	BiasCM float64 `json:"bias_cm"`
+	// RangeSampleCount is the number of samples whose forecast carried a
+	// snowfall range. RangeHitRate is nil when it is zero.
+	RangeSampleCount int `json:"range_sample_count"`
+	// RangeHitRate is the fraction of those samples where the observation fell
+	// inside [SnowfallRangeLow, SnowfallRangeHigh].
+	RangeHitRate *float64 `json:"range_hit_rate"`
+	// BrierSampleCount is the number of samples that carried a PowderProbability.
+	// The Brier scores are nil when it is zero.
+	BrierSampleCount int       `json:"brier_sample_count"`
+	Brier5cm         *float64  `json:"brier_5cm"`
+	Brier10cm        *float64  `json:"brier_10cm"`
+	Brier20cm        *float64  `json:"brier_20cm"`
+	Brier30cm        *float64  `json:"brier_30cm"`
+	ComputedAt       time.Time `json:"computed_at"`
+}
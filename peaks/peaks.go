@@ -0,0 +1,426 @@
+// Package peaks detects historically significant snowfall peak periods from
+// daily_snowfall history and writes them to resort_peak_periods.
+package peaks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// Confidence levels assigned to detected peaks.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// Store is the persistence needed to detect and save peak periods.
+// *repository.WriterRepository satisfies it.
+type Store interface {
+	GetAllResorts(ctx context.Context) ([]models.Resort, error)
+	GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error)
+	ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error
+}
+
+// Options tunes peak detection. Zero values select the defaults noted on each field.
+type Options struct {
+	// SmoothWindow is the half-width in days of the moving average applied to
+	// the mean daily snowfall curve before looking for maxima. Default 3.
+	SmoothWindow int
+	// HalfWidth is the number of days on each side of a peak's center that
+	// make up the peak period. Default 7.
+	HalfWidth int
+	// MaxPeaks is the maximum number of peaks kept per resort. Default 3.
+	MaxPeaks int
+	// MinWinters is the number of winters of history a resort needs before
+	// peaks are detected. Default 2.
+	MinWinters int
+	// MinProminence is the minimum ratio of period to season average daily
+	// snowfall for a peak to be kept. Default 1.2.
+	MinProminence float64
+	// RegionalToleranceDays is how close, in days, another resort's peak
+	// center must be to count towards RegionalConsistency. Default 10.
+	RegionalToleranceDays int
+}
+
+func (o Options) withDefaults() Options {
+	if o.SmoothWindow <= 0 {
+		o.SmoothWindow = 3
+	}
+	if o.HalfWidth <= 0 {
+		o.HalfWidth = 7
+	}
+	if o.MaxPeaks <= 0 {
+		o.MaxPeaks = 3
+	}
+	if o.MinWinters <= 0 {
+		o.MinWinters = 2
+	}
+	if o.MinProminence <= 0 {
+		o.MinProminence = 1.2
+	}
+	if o.RegionalToleranceDays <= 0 {
+		o.RegionalToleranceDays = 10
+	}
+	return o
+}
+
+// seasonDays is the length of the season calendar. Seasons run from Jul 1 to
+// Jun 30 so that winter peaks never straddle the calendar's ends; Feb 29 is
+// folded into Feb 28.
+const seasonDays = 365
+
+// seasonStart anchors offset 0 of the season calendar in a non-leap season.
+var seasonStart = time.Date(2001, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+// seasonOffset returns the day offset of t within its season and the year the season starts in.
+func seasonOffset(t time.Time) (offset, winter int) {
+	winter = t.Year()
+	if t.Month() < time.July {
+		winter--
+	}
+	month, day := t.Month(), t.Day()
+	if month == time.February && day == 29 {
+		day = 28
+	}
+	year := 2001
+	if month < time.July {
+		year = 2002
+	}
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return int(d.Sub(seasonStart).Hours() / 24), winter
+}
+
+// offsetToMMDD converts a season offset back to "MM-DD".
+func offsetToMMDD(offset int) string {
+	return seasonStart.AddDate(0, 0, offset).Format("01-02")
+}
+
+// history holds one resort's observations indexed by season offset and winter.
+type history struct {
+	byWinter map[int]map[int]float64 // winter -> offset -> cm
+}
+
+func newHistory(observed map[string]int) (*history, error) {
+	h := &history{byWinter: make(map[int]map[int]float64)}
+	for date, cm := range observed {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("parse observation date %q: %w", date, err)
+		}
+		offset, winter := seasonOffset(t)
+		if h.byWinter[winter] == nil {
+			h.byWinter[winter] = make(map[int]float64)
+		}
+		// Feb 28 and a folded Feb 29 share an offset; keep the larger value.
+		if prev, ok := h.byWinter[winter][offset]; !ok || float64(cm) > prev {
+			h.byWinter[winter][offset] = float64(cm)
+		}
+	}
+	return h, nil
+}
+
+// meanCurve returns the mean snowfall per season offset across winters, with
+// NaN for offsets that have no observations.
+func (h *history) meanCurve() []float64 {
+	var sum, count [seasonDays]float64
+	for _, days := range h.byWinter {
+		for offset, cm := range days {
+			sum[offset] += cm
+			count[offset]++
+		}
+	}
+	curve := make([]float64, seasonDays)
+	for i := range curve {
+		if count[i] == 0 {
+			curve[i] = math.NaN()
+			continue
+		}
+		curve[i] = sum[i] / count[i]
+	}
+	return curve
+}
+
+// smooth applies a centered moving average that ignores missing (NaN) days.
+func smooth(curve []float64, halfWidth int) []float64 {
+	out := make([]float64, len(curve))
+	for i := range curve {
+		var sum float64
+		var n int
+		for j := max(0, i-halfWidth); j <= min(len(curve)-1, i+halfWidth); j++ {
+			if !math.IsNaN(curve[j]) {
+				sum += curve[j]
+				n++
+			}
+		}
+		if n == 0 || math.IsNaN(curve[i]) {
+			out[i] = math.NaN()
+			continue
+		}
+		out[i] = sum / float64(n)
+	}
+	return out
+}
+
+// meanOver returns the mean of one winter's observations between two season
+// offsets (inclusive) and how many observations there were.
+func meanOver(days map[int]float64, from, to int) (float64, int) {
+	var sum float64
+	var n int
+	for offset := from; offset <= to; offset++ {
+		if cm, ok := days[offset]; ok {
+			sum += cm
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return sum / float64(n), n
+}
+
+// Detect finds up to opts.MaxPeaks non-overlapping peak periods in a resort's
+// observed daily snowfall, keyed by "YYYY-MM-DD" date. RegionalConsistency is
+// left zero; see ApplyRegionalConsistency.
+//
+// Peaks are the highest local maxima of the smoothed mean daily snowfall curve.
+// Each period spans opts.HalfWidth days either side of its center and is scored by:
+//   - ProminenceScore: period average daily snowfall over the season average
+//   - WintersPresent/TotalWinters: winters whose own period average beat their
+//     own season average, out of winters with data in the period
+//   - ReliabilityScore: WintersPresent / TotalWinters
+func Detect(observed map[string]int, opts Options, calculatedAt time.Time) ([]models.PeakPeriod, error) {
+	opts = opts.withDefaults()
+
+	h, err := newHistory(observed)
+	if err != nil {
+		return nil, err
+	}
+	if len(h.byWinter) < opts.MinWinters {
+		return []models.PeakPeriod{}, nil
+	}
+
+	curve := h.meanCurve()
+	smoothed := smooth(curve, opts.SmoothWindow)
+
+	var seasonSum float64
+	var seasonN int
+	for _, v := range curve {
+		if !math.IsNaN(v) {
+			seasonSum += v
+			seasonN++
+		}
+	}
+	seasonAvg := seasonSum / float64(seasonN)
+	if seasonAvg <= 0 {
+		return []models.PeakPeriod{}, nil
+	}
+
+	var candidates []int
+	for i, v := range smoothed {
+		if math.IsNaN(v) || v <= 0 {
+			continue
+		}
+		if (i > 0 && !math.IsNaN(smoothed[i-1]) && smoothed[i-1] > v) ||
+			(i < len(smoothed)-1 && !math.IsNaN(smoothed[i+1]) && smoothed[i+1] >= v) {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return smoothed[candidates[a]] > smoothed[candidates[b]]
+	})
+
+	winterAvgs := make(map[int]float64, len(h.byWinter))
+	for winter, days := range h.byWinter {
+		winterAvgs[winter], _ = meanOver(days, 0, seasonDays-1)
+	}
+
+	found := []models.PeakPeriod{}
+	var centers []int
+	for _, center := range candidates {
+		if len(found) == opts.MaxPeaks {
+			break
+		}
+		overlaps := false
+		for _, c := range centers {
+			if abs(c-center) <= 2*opts.HalfWidth {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+
+		start := max(0, center-opts.HalfWidth)
+		end := min(seasonDays-1, center+opts.HalfWidth)
+
+		var total float64
+		var withData int
+		for offset := start; offset <= end; offset++ {
+			if !math.IsNaN(curve[offset]) {
+				total += curve[offset]
+				withData++
+			}
+		}
+		avgDaily := total / float64(withData)
+		prominence := avgDaily / seasonAvg
+		if prominence < opts.MinProminence {
+			continue
+		}
+
+		var present, totalWinters int
+		for winter, days := range h.byWinter {
+			periodAvg, n := meanOver(days, start, end)
+			if n == 0 {
+				continue
+			}
+			totalWinters++
+			if periodAvg > winterAvgs[winter] {
+				present++
+			}
+		}
+		reliability := 0.0
+		if totalWinters > 0 {
+			reliability = float64(present) / float64(totalWinters)
+		}
+
+		found = append(found, models.PeakPeriod{
+			StartDate:           offsetToMMDD(start),
+			EndDate:             offsetToMMDD(end),
+			CenterDate:          offsetToMMDD(center),
+			AvgDailySnowfall:    avgDaily,
+			TotalPeriodSnowfall: avgDaily * float64(end-start+1),
+			ProminenceScore:     prominence,
+			YearsOfData:         len(h.byWinter),
+			ReliabilityScore:    reliability,
+			WintersPresent:      present,
+			TotalWinters:        totalWinters,
+			CalculatedAt:        calculatedAt,
+		})
+		centers = append(centers, center)
+	}
+
+	for i := range found {
+		found[i].PeakRank = i + 1
+		found[i].ConfidenceLevel = confidenceLevel(found[i])
+	}
+	return found, nil
+}
+
+// confidenceLevel derives the display label from reliability and history length.
+func confidenceLevel(p models.PeakPeriod) string {
+	switch {
+	case p.ReliabilityScore >= 0.7 && p.YearsOfData >= 5:
+		return ConfidenceHigh
+	case p.ReliabilityScore >= 0.5 && p.YearsOfData >= 3:
+		return ConfidenceMedium
+	default:
+		return ConfidenceLow
+	}
+}
+
+// ApplyRegionalConsistency sets RegionalConsistency on every peak to the
+// fraction of other resorts in the same prefecture and region that have a peak
+// centered within toleranceDays, since region names repeat across prefectures.
+// Resorts without a region, or alone in their region, get zero.
+func ApplyRegionalConsistency(resorts []models.Resort, peaksByResort map[string][]models.PeakPeriod, toleranceDays int) {
+	type regionKey struct{ prefecture, region string }
+	byRegion := make(map[regionKey][]string)
+	for _, r := range resorts {
+		region := strings.TrimSpace(strings.ToLower(r.Region))
+		if region == "" {
+			continue
+		}
+		k := regionKey{strings.TrimSpace(strings.ToLower(r.Prefecture)), region}
+		byRegion[k] = append(byRegion[k], r.ID)
+	}
+
+	for _, ids := range byRegion {
+		for _, id := range ids {
+			peaks := peaksByResort[id]
+			for i := range peaks {
+				peaks[i].RegionalConsistency = 0
+				if len(ids) < 2 {
+					continue
+				}
+				center := centerOffset(peaks[i])
+				var matching int
+				for _, other := range ids {
+					if other == id {
+						continue
+					}
+					for _, op := range peaksByResort[other] {
+						if abs(centerOffset(op)-center) <= toleranceDays {
+							matching++
+							break
+						}
+					}
+				}
+				peaks[i].RegionalConsistency = float64(matching) / float64(len(ids)-1)
+			}
+		}
+	}
+}
+
+func centerOffset(p models.PeakPeriod) int {
+	t, err := time.Parse("01-02", p.CenterDate)
+	if err != nil {
+		return -seasonDays
+	}
+	offset, _ := seasonOffset(time.Date(2000, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	return offset
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Run detects peaks for every resort, scores regional consistency across
+// resorts in the same prefecture and region, and replaces each resort's stored peaks.
+// Resorts without enough history have their stored peaks cleared.
+func Run(ctx context.Context, store Store, opts Options) (map[string][]models.PeakPeriod, error) {
+	if store == nil {
+		return nil, errors.New("nil store")
+	}
+	opts = opts.withDefaults()
+
+	resorts, err := store.GetAllResorts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get resorts: %w", err)
+	}
+	observed, err := store.GetObservedSnowfall(ctx, "0001-01-01", time.Now().UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("get observed snowfall: %w", err)
+	}
+
+	calculatedAt := time.Now().UTC()
+	peaksByResort := make(map[string][]models.PeakPeriod, len(resorts))
+	for _, r := range resorts {
+		peaks, err := Detect(observed[r.ID], opts, calculatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("detect peaks for %s: %w", r.ID, err)
+		}
+		peaksByResort[r.ID] = peaks
+	}
+
+	ApplyRegionalConsistency(resorts, peaksByResort, opts.RegionalToleranceDays)
+
+	for _, r := range resorts {
+		if err := store.ReplacePeakPeriods(ctx, r.ID, peaksByResort[r.ID]); err != nil {
+			return nil, fmt.Errorf("replace peak periods for %s: %w", r.ID, err)
+		}
+	}
+	return peaksByResort, nil
+}
@@ -0,0 +1,163 @@
+package peaks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// syntheticHistory returns winters starting 2019..2019+winters-1 with a
+// 5 cm/day baseline from Dec 1 to Mar 31 and a 30 cm/day spike around peakMMDD.
+func syntheticHistory(winters int, peakMonth time.Month, peakDay int) map[string]int {
+	observed := map[string]int{}
+	for w := range winters {
+		start := time.Date(2019+w, time.December, 1, 0, 0, 0, 0, time.UTC)
+		end := time.Date(2020+w, time.March, 31, 0, 0, 0, 0, time.UTC)
+		peakYear := 2019 + w
+		if peakMonth < time.July {
+			peakYear++
+		}
+		peak := time.Date(peakYear, peakMonth, peakDay, 0, 0, 0, 0, time.UTC)
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			cm := 5
+			if diff := d.Sub(peak).Hours() / 24; diff >= -3 && diff <= 3 {
+				cm = 30
+			}
+			observed[d.Format("2006-01-02")] = cm
+		}
+	}
+	return observed
+}
+
+func TestDetect_FindsPeakAcrossYearBoundary(t *testing.T) {
+	t.Parallel()
+
+	calculatedAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	peaks, err := Detect(syntheticHistory(5, time.January, 2), Options{MaxPeaks: 1}, calculatedAt)
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(peaks) != 1 {
+		t.Fatalf("Detect() returned %d peaks, want 1", len(peaks))
+	}
+
+	p := peaks[0]
+	if p.PeakRank != 1 || p.CenterDate != "01-02" {
+		t.Fatalf("peak rank/center = %d/%s, want 1/01-02", p.PeakRank, p.CenterDate)
+	}
+	if p.StartDate != "12-26" || p.EndDate != "01-09" {
+		t.Fatalf("peak window = %s..%s, want 12-26..01-09", p.StartDate, p.EndDate)
+	}
+	if p.ProminenceScore <= 1.2 {
+		t.Fatalf("ProminenceScore = %v, want > 1.2", p.ProminenceScore)
+	}
+	if p.YearsOfData != 5 || p.WintersPresent != 5 || p.TotalWinters != 5 || p.ReliabilityScore != 1 {
+		t.Fatalf("reliability fields = %+v", p)
+	}
+	if p.ConfidenceLevel != ConfidenceHigh {
+		t.Fatalf("ConfidenceLevel = %q, want %q", p.ConfidenceLevel, ConfidenceHigh)
+	}
+	if !p.CalculatedAt.Equal(calculatedAt) {
+		t.Fatalf("CalculatedAt = %s, want %s", p.CalculatedAt, calculatedAt)
+	}
+}
+
+func TestDetect_RequiresMinWinters(t *testing.T) {
+	t.Parallel()
+
+	peaks, err := Detect(syntheticHistory(1, time.February, 1), Options{}, time.Now())
+	if err != nil {
+		t.Fatalf("Detect() error = %v", err)
+	}
+	if len(peaks) != 0 {
+		t.Fatalf("Detect() returned %d peaks for one winter, want 0", len(peaks))
+	}
+}
+
+func TestApplyRegionalConsistency(t *testing.T) {
+	t.Parallel()
+
+	resorts := []models.Resort{
+		{ID: "a", Prefecture: "Nagano", Region: "Hakuba"},
+		{ID: "b", Prefecture: "nagano", Region: "hakuba "},
+		{ID: "c", Prefecture: "Nagano", Region: "Hakuba"},
+		{ID: "d", Prefecture: "Hokkaido", Region: "Niseko"},
+		// Same region name in another prefecture.
+		{ID: "e", Prefecture: "Niigata", Region: "Hakuba"},
+	}
+	peaksByResort := map[string][]models.PeakPeriod{
+		"a": {{CenterDate: "12-28"}},
+		"b": {{CenterDate: "01-03"}},
+		"c": {{CenterDate: "02-20"}},
+		"d": {{CenterDate: "01-01"}},
+		"e": {{CenterDate: "12-30"}},
+	}
+
+	ApplyRegionalConsistency(resorts, peaksByResort, 10)
+
+	if got := peaksByResort["a"][0].RegionalConsistency; got != 0.5 {
+		t.Fatalf("a consistency = %v, want 0.5", got)
+	}
+	if got := peaksByResort["c"][0].RegionalConsistency; got != 0 {
+		t.Fatalf("c consistency = %v, want 0", got)
+	}
+	if got := peaksByResort["d"][0].RegionalConsistency; got != 0 {
+		t.Fatalf("d consistency = %v, want 0 when alone in region", got)
+	}
+	if got := peaksByResort["e"][0].RegionalConsistency; got != 0 {
+		t.Fatalf("e consistency = %v, want 0 when alone in its prefecture's region", got)
+	}
+}
+
+type fakeStore struct {
+	resorts  []models.Resort
+	observed map[string]map[string]int
+	replaced map[string][]models.PeakPeriod
+}
+
+func (f *fakeStore) GetAllResorts(context.Context) ([]models.Resort, error) {
+	return f.resorts, nil
+}
+
+func (f *fakeStore) GetObservedSnowfall(context.Context, string, string) (map[string]map[string]int, error) {
+	return f.observed, nil
+}
+
+func (f *fakeStore) ReplacePeakPeriods(_ context.Context, resortID string, peaks []models.PeakPeriod) error {
+	if f.replaced == nil {
+		f.replaced = map[string][]models.PeakPeriod{}
+	}
+	f.replaced[resortID] = peaks
+	return nil
+}
+
+func TestRun_ReplacesPeaksForEveryResort(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeStore{
+		resorts: []models.Resort{
+			{ID: "a", Region: "hakuba"},
+			{ID: "b", Region: "hakuba"},
+			{ID: "empty", Region: "hakuba"},
+		},
+		observed: map[string]map[string]int{
+			"a": syntheticHistory(3, time.January, 20),
+			"b": syntheticHistory(3, time.January, 25),
+		},
+	}
+
+	if _, err := Run(context.Background(), store, Options{MaxPeaks: 1}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(store.replaced) != 3 {
+		t.Fatalf("replaced peaks for %d resorts, want 3", len(store.replaced))
+	}
+	if len(store.replaced["empty"]) != 0 {
+		t.Fatalf("expected no peaks for resort without history, got %+v", store.replaced["empty"])
+	}
+	if got := store.replaced["a"][0].RegionalConsistency; got != 0.5 {
+		t.Fatalf("a consistency = %v, want 0.5", got)
+	}
+}
@@ -0,0 +1,288 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/google/uuid"
+)
+
+// AlertRepository provides access to powder alert rules and events.
+type AlertRepository struct {
+	db *database
+}
+
+// NewAlertRepository creates a new alert repository.
+func NewAlertRepository(db *sql.DB, opts ...Option) *AlertRepository {
+	return &AlertRepository{
+		db: newDatabase(db, newOptions(opts)),
+	}
+}
+
+const alertRuleColumns = `id, user_id, name, resort_id, prefecture, threshold_cm, min_probability_pct,
+	min_lead_days, max_lead_days, enabled, created_at, updated_at`
+
+func scanAlertRule(row rowScanner) (models.AlertRule, error) {
+	var rule models.AlertRule
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Name, &rule.ResortID, &rule.Prefecture,
+		&rule.ThresholdCM, &rule.MinProbabilityPct, &rule.MinLeadDays, &rule.MaxLeadDays,
+		&rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	return rule, err
+}
+
+// SaveAlertRule inserts rule, or updates it if a rule with its ID exists.
+// A rule without an ID is given a new one. CreatedAt and UpdatedAt are set to
+// the persisted values. Rules are not validated beyond the schema's checks;
+// see alerts.ValidateRule.
+func (r *AlertRepository) SaveAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	query := `
+		INSERT INTO alert_rules (` + alertRuleColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			name = EXCLUDED.name,
+			resort_id = EXCLUDED.resort_id,
+			prefecture = EXCLUDED.prefecture,
+			threshold_cm = EXCLUDED.threshold_cm,
+			min_probability_pct = EXCLUDED.min_probability_pct,
+			min_lead_days = EXCLUDED.min_lead_days,
+			max_lead_days = EXCLUDED.max_lead_days,
+			enabled = EXCLUDED.enabled,
+			updated_at = EXCLUDED.updated_at
+		RETURNING created_at
+	`
+
+	var createdAt time.Time
+	err := r.db.QueryRowContext(ctx, query,
+		rule.ID, rule.UserID, rule.Name, rule.ResortID, rule.Prefecture,
+		rule.ThresholdCM, rule.MinProbabilityPct, rule.MinLeadDays, rule.MaxLeadDays,
+		rule.Enabled, now.Format(time.RFC3339), now.Format(time.RFC3339),
+	).Scan(&createdAt)
+	if err != nil {
+		return fmt.Errorf("save alert rule %s: %w", rule.ID, err)
+	}
+	rule.CreatedAt = createdAt.UTC()
+	rule.UpdatedAt = now
+	return nil
+}
+
+// GetAlertRule returns the alert rule with the given ID.
+// Returns sql.ErrNoRows (wrapped) if no such rule exists.
+func (r *AlertRepository) GetAlertRule(ctx context.Context, id string) (*models.AlertRule, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	rule, err := scanAlertRule(r.db.QueryRowContext(ctx,
+		"SELECT "+alertRuleColumns+" FROM alert_rules WHERE id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("get alert rule %s: %w", id, err)
+	}
+	return &rule, nil
+}
+
+// ListAlertRules returns the alert rules of a user, oldest first. An empty
+// userID lists the rules of every user.
+func (r *AlertRepository) ListAlertRules(ctx context.Context, userID string) ([]models.AlertRule, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := "SELECT " + alertRuleColumns + " FROM alert_rules"
+	var args []any
+	if userID != "" {
+		query += " WHERE user_id = ?"
+		args = append(args, userID)
+	}
+	return r.queryAlertRules(ctx, query+" ORDER BY created_at, id", args...)
+}
+
+// ListEnabledAlertRules returns every enabled alert rule, oldest first.
+func (r *AlertRepository) ListEnabledAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	return r.queryAlertRules(ctx,
+		"SELECT "+alertRuleColumns+" FROM alert_rules WHERE enabled = TRUE ORDER BY created_at, id")
+}
+
+func (r *AlertRepository) queryAlertRules(ctx context.Context, query string, args ...any) ([]models.AlertRule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate alert rule rows: %w", err)
+	}
+	return rules, nil
+}
+
+// DeleteAlertRule deletes an alert rule and its events.
+// Returns sql.ErrNoRows (wrapped) if no such rule exists.
+func (r *AlertRepository) DeleteAlertRule(ctx context.Context, id string) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete alert rule transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// Events are deleted explicitly as SQLite only cascades with foreign keys enabled.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM alert_events WHERE rule_id = ?", id); err != nil {
+		return fmt.Errorf("delete alert events for rule %s: %w", id, err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM alert_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("delete alert rule %s: %w", id, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete alert rule %s: rows affected: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("delete alert rule %s: %w", id, sql.ErrNoRows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete alert rule: %w", err)
+	}
+	return nil
+}
+
+const alertEventColumns = `id, rule_id, user_id, run_generated_at, resort_id, resort_name, forecast_date,
+	lead_days, threshold_cm, probability_pct, snowfall_cm, created_at, delivered_at`
+
+// SaveAlertEvents stores events in a single transaction and returns the ones
+// that were new. Events already stored for the same rule, run and resort-day
+// are skipped, so a run can be evaluated repeatedly without firing twice.
+// Each returned event has its ID and CreatedAt set.
+func (r *AlertRepository) SaveAlertEvents(ctx context.Context, events []models.AlertEvent) ([]models.AlertEvent, error) {
+	fired := []models.AlertEvent{}
+	if len(events) == 0 {
+		return fired, nil
+	}
+
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin alert events transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	query := `
+		INSERT INTO alert_events (` + alertEventColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL)
+		ON CONFLICT (rule_id, run_generated_at, resort_id, forecast_date) DO NOTHING
+	`
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, e := range events {
+		e.ID = uuid.New().String()
+		e.CreatedAt = now
+		e.DeliveredAt = nil
+		result, err := tx.ExecContext(ctx, query,
+			e.ID, e.RuleID, e.UserID, e.RunGeneratedAt, e.ResortID, e.ResortName, e.ForecastDate,
+			e.LeadDays, e.ThresholdCM, e.ProbabilityPct, e.SnowfallCM, now.Format(time.RFC3339),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("save alert event for rule %s, %s on %s: %w", e.RuleID, e.ResortID, e.ForecastDate, err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("save alert event: rows affected: %w", err)
+		}
+		if inserted > 0 {
+			fired = append(fired, e)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit alert events: %w", err)
+	}
+	return fired, nil
+}
+
+// ListPendingAlertEvents returns up to limit undelivered alert events, oldest
+// first.
+func (r *AlertRepository) ListPendingAlertEvents(ctx context.Context, limit int) ([]models.AlertEvent, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	query := `
+		SELECT ` + alertEventColumns + `
+		FROM alert_events
+		WHERE delivered_at IS NULL
+		ORDER BY created_at, id
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query alert events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AlertEvent{}
+	for rows.Next() {
+		var e models.AlertEvent
+		if err := rows.Scan(&e.ID, &e.RuleID, &e.UserID, &e.RunGeneratedAt, &e.ResortID, &e.ResortName,
+			&e.ForecastDate, &e.LeadDays, &e.ThresholdCM, &e.ProbabilityPct, &e.SnowfallCM,
+			&e.CreatedAt, &e.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("scan alert event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate alert event rows: %w", err)
+	}
+	return events, nil
+}
+
+// MarkAlertEventDelivered records that the alert event with the given ID has
+// been delivered. Returns an error if no undelivered event was updated.
+func (r *AlertRepository) MarkAlertEventDelivered(ctx context.Context, id string) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE alert_events SET delivered_at = ? WHERE id = ? AND delivered_at IS NULL",
+		time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("mark alert event delivered: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("mark alert event delivered: rows affected: %w", err)
+	}
+	if rowsAffected != 1 {
+		return fmt.Errorf("mark alert event delivered: affected %d rows, want 1", rowsAffected)
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// CacheOptions configures a CachedReader.
+type CacheOptions struct {
+	// TTL is how long an entry is served before it is reloaded. Defaults to 5 minutes.
+	TTL time.Duration
+	// MaxEntries bounds the cache; the least recently used entry is evicted
+	// first. Defaults to 1024.
+	MaxEntries int
+}
+
+func (o CacheOptions) withDefaults() CacheOptions {
+	if o.TTL <= 0 {
+		o.TTL = 5 * time.Minute
+	}
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = 1024
+	}
+	return o
+}
+
+// CacheStats counts cache lookups.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// cacheTable names the data an entry was read from; writes invalidate every
+// entry that depends on the tables they touch.
+type cacheTable int
+
+const (
+	tableResorts cacheTable = iota
+	tableSnowfall
+	tableSnowDepth
+	tablePeaks
+	numCacheTables
+)
+
+type cacheEntry struct {
+	key     string
+	value   any
+	tables  []cacheTable
+	expires time.Time
+}
+
+// CachedReader is a Reader decorator with a read-through TTL/LRU cache keyed
+// by method and arguments. Errors are not cached, and failed scrape attempts
+// are always read from the underlying Reader.
+//
+// Results are deep-copied into the cache and again for every caller, so they
+// may be modified freely.
+type CachedReader struct {
+	reader Reader
+	opts   CacheOptions
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+	// generations are bumped by invalidate so that a load racing with a write
+	// does not store a result read before the write.
+	generations [numCacheTables]uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+var _ Reader = (*CachedReader)(nil)
+
+// NewCachedReader wraps reader with a cache.
+func NewCachedReader(reader Reader, opts CacheOptions) *CachedReader {
+	return &CachedReader{
+		reader:  reader,
+		opts:    opts.withDefaults(),
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the hit and miss counts since the cache was created.
+func (c *CachedReader) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (c *CachedReader) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Purge drops every cached entry.
+func (c *CachedReader) Purge() {
+	c.invalidate(tableResorts, tableSnowfall, tableSnowDepth, tablePeaks)
+}
+
+// lookup returns the cached value for key, or the tables' current generations
+// to pass to store after loading.
+func (c *CachedReader) lookup(key string, tables []cacheTable) (any, bool, []uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			return entry.value, true, nil
+		}
+		c.remove(elem)
+	}
+
+	generations := make([]uint64, len(tables))
+	for i, table := range tables {
+		generations[i] = c.generations[table]
+	}
+	return nil, false, generations
+}
+
+// store caches value unless one of its tables was invalidated since lookup.
+func (c *CachedReader) store(key string, value any, tables []cacheTable, generations []uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, table := range tables {
+		if c.generations[table] != generations[i] {
+			return
+		}
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		value:   value,
+		tables:  tables,
+		expires: c.now().Add(c.opts.TTL),
+	})
+	for c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate drops every entry that depends on any of tables.
+func (c *CachedReader) invalidate(tables ...cacheTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var affected [numCacheTables]bool
+	for _, table := range tables {
+		affected[table] = true
+		c.generations[table]++
+	}
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		for _, table := range elem.Value.(*cacheEntry).tables {
+			if affected[table] {
+				c.remove(elem)
+				break
+			}
+		}
+		elem = next
+	}
+}
+
+// remove unlinks elem. c.mu must be held.
+func (c *CachedReader) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
+
+// cached serves key from c or loads and caches it. clone deep-copies a value,
+// so neither the loader's result nor the cached entry is shared with callers.
+func cached[T any](c *CachedReader, key string, tables []cacheTable, clone func(T) T, load func() (T, error)) (T, error) {
+	value, ok, generations := c.lookup(key, tables)
+	if ok {
+		c.hits.Add(1)
+		return clone(value.(T)), nil
+	}
+
+	c.misses.Add(1)
+	result, err := load()
+	if err != nil {
+		return result, err
+	}
+	c.store(key, clone(result), tables, generations)
+	return result, nil
+}
+
+// cacheKey joins a method name and its arguments into a cache key.
+func cacheKey(method string, args ...any) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, arg := range args {
+		b.WriteByte(0)
+		switch v := arg.(type) {
+		case time.Time:
+			b.WriteString(v.Format(time.RFC3339Nano))
+		case []string:
+			b.WriteString(strings.Join(v, "\x01"))
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	return b.String()
+}
+
+// GetResortBySlug implements Reader.
+func (c *CachedReader) GetResortBySlug(ctx context.Context, slug string) (*models.Resort, error) {
+	return cached(c, cacheKey("GetResortBySlug", slug), []cacheTable{tableResorts}, cloneResortPtr, func() (*models.Resort, error) {
+		return c.reader.GetResortBySlug(ctx, slug)
+	})
+}
+
+// GetResortByID implements Reader.
+func (c *CachedReader) GetResortByID(ctx context.Context, id string) (*models.Resort, error) {
+	return cached(c, cacheKey("GetResortByID", id), []cacheTable{tableResorts}, cloneResortPtr, func() (*models.Resort, error) {
+		return c.reader.GetResortByID(ctx, id)
+	})
+}
+
+// GetAllResorts implements Reader.
+func (c *CachedReader) GetAllResorts(ctx context.Context) ([]models.Resort, error) {
+	return cached(c, cacheKey("GetAllResorts"), []cacheTable{tableResorts}, cloneResorts, func() ([]models.Resort, error) {
+		return c.reader.GetAllResorts(ctx)
+	})
+}
+
+// SearchResorts implements Reader.
+func (c *CachedReader) SearchResorts(ctx context.Context, q ResortQuery) (ResortPage, error) {
+	return cached(c, cacheKey("SearchResorts", fmt.Sprintf("%#v", q)), []cacheTable{tableResorts}, cloneResortPage, func() (ResortPage, error) {
+		return c.reader.SearchResorts(ctx, q)
+	})
+}
+
+// GetResortsNear implements Reader.
+func (c *CachedReader) GetResortsNear(ctx context.Context, lat, lon, radiusKM float64, limit int) ([]models.ResortDistance, error) {
+	return cached(c, cacheKey("GetResortsNear", lat, lon, radiusKM, limit), []cacheTable{tableResorts}, cloneResortDistances, func() ([]models.ResortDistance, error) {
+		return c.reader.GetResortsNear(ctx, lat, lon, radiusKM, limit)
+	})
+}
+
+// GetResortsInBBox implements Reader.
+func (c *CachedReader) GetResortsInBBox(ctx context.Context, minLat, minLon, maxLat, maxLon float64) ([]models.Resort, error) {
+	return cached(c, cacheKey("GetResortsInBBox", minLat, minLon, maxLat, maxLon), []cacheTable{tableResorts}, cloneResorts, func() ([]models.Resort, error) {
+		return c.reader.GetResortsInBBox(ctx, minLat, minLon, maxLat, maxLon)
+	})
+}
+
+// GetSnowiestResorts implements Reader.
+func (c *CachedReader) GetSnowiestResorts(ctx context.Context, startDate, endDate, prefecture string, limit int) ([]models.WeeklyResortStats, error) {
+	key := cacheKey("GetSnowiestResorts", startDate, endDate, prefecture, limit)
+	return cached(c, key, []cacheTable{tableResorts, tableSnowfall}, cloneWeeklyResortStats, func() ([]models.WeeklyResortStats, error) {
+		return c.reader.GetSnowiestResorts(ctx, startDate, endDate, prefecture, limit)
+	})
+}
+
+// GetAllResortsWithPeaks implements Reader.
+func (c *CachedReader) GetAllResortsWithPeaks(ctx context.Context) ([]models.ResortWithPeaks, error) {
+	return cached(c, cacheKey("GetAllResortsWithPeaks"), []cacheTable{tableResorts, tablePeaks}, cloneResortsWithPeaks, func() ([]models.ResortWithPeaks, error) {
+		return c.reader.GetAllResortsWithPeaks(ctx)
+	})
+}
+
+// GetPeakPeriodsForResort implements Reader.
+func (c *CachedReader) GetPeakPeriodsForResort(ctx context.Context, resortID string) ([]models.PeakPeriod, error) {
+	return cached(c, cacheKey("GetPeakPeriodsForResort", resortID), []cacheTable{tablePeaks}, clonePeakPeriods, func() ([]models.PeakPeriod, error) {
+		return c.reader.GetPeakPeriodsForResort(ctx, resortID)
+	})
+}
+
+// GetPendingFailedScrapeAttempts implements Reader. It is never cached.
+func (c *CachedReader) GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	return c.reader.GetPendingFailedScrapeAttempts(ctx)
+}
+
+// GetOpenFailedScrapeAttempt implements Reader. It is never cached.
+func (c *CachedReader) GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error) {
+	return c.reader.GetOpenFailedScrapeAttempt(ctx, resortURL)
+}
+
+// GetDeadLetteredScrapeAttempts implements Reader. It is never cached.
+func (c *CachedReader) GetDeadLetteredScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	return c.reader.GetDeadLetteredScrapeAttempts(ctx)
+}
+
+// GetSnowDepthHistory implements Reader.
+func (c *CachedReader) GetSnowDepthHistory(ctx context.Context, resortID string, from, to time.Time) ([]models.SnowDepthReading, error) {
+	key := cacheKey("GetSnowDepthHistory", resortID, from, to)
+	return cached(c, key, []cacheTable{tableSnowDepth}, cloneSnowDepthReadings, func() ([]models.SnowDepthReading, error) {
+		return c.reader.GetSnowDepthHistory(ctx, resortID, from, to)
+	})
+}
+
+// GetLatestSnowDepth implements Reader.
+func (c *CachedReader) GetLatestSnowDepth(ctx context.Context, resortIDs []string) (map[string]models.SnowDepthReading, error) {
+	key := cacheKey("GetLatestSnowDepth", resortIDs)
+	return cached(c, key, []cacheTable{tableSnowDepth}, cloneSnowDepthByResort, func() (map[string]models.SnowDepthReading, error) {
+		return c.reader.GetLatestSnowDepth(ctx, resortIDs)
+	})
+}
+
+// GetSeasonMaxSnowDepth implements Reader.
+func (c *CachedReader) GetSeasonMaxSnowDepth(ctx context.Context, resortIDs []string, asOf time.Time) (map[string]models.SnowDepthReading, error) {
+	key := cacheKey("GetSeasonMaxSnowDepth", resortIDs, asOf)
+	return cached(c, key, []cacheTable{tableSnowDepth}, cloneSnowDepthByResort, func() (map[string]models.SnowDepthReading, error) {
+		return c.reader.GetSeasonMaxSnowDepth(ctx, resortIDs, asOf)
+	})
+}
+
+// GetDailySnowfall implements Reader.
+func (c *CachedReader) GetDailySnowfall(ctx context.Context, resortID string, from, to time.Time) ([]models.DailySnowfall, error) {
+	key := cacheKey("GetDailySnowfall", resortID, from, to)
+	return cached(c, key, []cacheTable{tableSnowfall}, cloneDailySnowfall, func() ([]models.DailySnowfall, error) {
+		return c.reader.GetDailySnowfall(ctx, resortID, from, to)
+	})
+}
+
+// GetSeasonSnowfallTotals implements Reader.
+func (c *CachedReader) GetSeasonSnowfallTotals(ctx context.Context, resortID string, seasons int, throughMMDD string) ([]models.SeasonSnowfallTotal, error) {
+	key := cacheKey("GetSeasonSnowfallTotals", resortID, seasons, throughMMDD)
+	return cached(c, key, []cacheTable{tableSnowfall}, cloneSeasonSnowfallTotals, func() ([]models.SeasonSnowfallTotal, error) {
+		return c.reader.GetSeasonSnowfallTotals(ctx, resortID, seasons, throughMMDD)
+	})
+}
+
+// GetRegionSummaries implements Reader.
+func (c *CachedReader) GetRegionSummaries(ctx context.Context, from, to time.Time, groupBy RegionGrouping) ([]models.RegionSummary, error) {
+	key := cacheKey("GetRegionSummaries", from, to, groupBy)
+	return cached(c, key, []cacheTable{tableResorts, tableSnowfall}, cloneRegionSummaries, func() ([]models.RegionSummary, error) {
+		return c.reader.GetRegionSummaries(ctx, from, to, groupBy)
+	})
+}
+
+// CachedWriter is a Writer whose reads go through a CachedReader. Each write
+// is passed to the underlying Writer and then invalidates the cached entries
+// it could have changed, even if the write failed part-way.
+type CachedWriter struct {
+	*CachedReader
+	writer Writer
+}
+
+var _ Writer = (*CachedWriter)(nil)
+
+// NewCachedWriter wraps writer with a cache for its reads.
+func NewCachedWriter(writer Writer, opts CacheOptions) *CachedWriter {
+	return &CachedWriter{
+		CachedReader: NewCachedReader(writer, opts),
+		writer:       writer,
+	}
+}
+
+// SaveResort implements Writer and invalidates resort-derived entries.
+func (c *CachedWriter) SaveResort(ctx context.Context, resort *models.Resort) error {
+	defer c.invalidate(tableResorts)
+	return c.writer.SaveResort(ctx, resort)
+}
+
+// SaveSnowDepthReadings implements Writer and invalidates snow depth entries.
+func (c *CachedWriter) SaveSnowDepthReadings(ctx context.Context, readings []models.SnowDepthReading) error {
+	defer c.invalidate(tableSnowDepth)
+	return c.writer.SaveSnowDepthReadings(ctx, readings)
+}
+
+// SaveDailySnowfall implements Writer and invalidates snowfall-derived entries.
+func (c *CachedWriter) SaveDailySnowfall(ctx context.Context, snowfalls []models.DailySnowfall) error {
+	defer c.invalidate(tableSnowfall)
+	return c.writer.SaveDailySnowfall(ctx, snowfalls)
+}
+
+// SaveFailedScrapeAttempt implements Writer.
+func (c *CachedWriter) SaveFailedScrapeAttempt(ctx context.Context, resortURL, errorMessage string) error {
+	return c.writer.SaveFailedScrapeAttempt(ctx, resortURL, errorMessage)
+}
+
+// SaveScrapeFailure implements Writer.
+func (c *CachedWriter) SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error {
+	return c.writer.SaveScrapeFailure(ctx, attempt)
+}
+
+// MarkFailedAttemptRetried implements Writer.
+func (c *CachedWriter) MarkFailedAttemptRetried(ctx context.Context, id string) error {
+	return c.writer.MarkFailedAttemptRetried(ctx, id)
+}
+
+// ReplacePeakPeriods implements Writer and invalidates peak-derived entries.
+func (c *CachedWriter) ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error {
+	defer c.invalidate(tablePeaks)
+	return c.writer.ReplacePeakPeriods(ctx, resortID, peaks)
+}
@@ -0,0 +1,123 @@
+package repository
+
+import "github.com/amaumene/snowfinder_common/models"
+
+// The clone functions deep-copy cached values, so callers of a CachedReader
+// can modify what they get without affecting the cache or each other.
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+func cloneSlice[T any](s []T, clone func(T) T) []T {
+	if s == nil {
+		return nil
+	}
+	out := make([]T, len(s))
+	for i, v := range s {
+		out[i] = clone(v)
+	}
+	return out
+}
+
+func cloneMap[K comparable, V any](m map[K]V, clone func(V) V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	out := make(map[K]V, len(m))
+	for k, v := range m {
+		out[k] = clone(v)
+	}
+	return out
+}
+
+// cloneValue copies values without pointers, slices or maps.
+func cloneValue[T any](v T) T { return v }
+
+func cloneResort(r models.Resort) models.Resort {
+	r.TopElevationM = clonePtr(r.TopElevationM)
+	r.BaseElevationM = clonePtr(r.BaseElevationM)
+	r.VerticalM = clonePtr(r.VerticalM)
+	r.NumCourses = clonePtr(r.NumCourses)
+	r.LongestCourseKM = clonePtr(r.LongestCourseKM)
+	r.SteepestCourseDeg = clonePtr(r.SteepestCourseDeg)
+	r.Latitude = clonePtr(r.Latitude)
+	r.Longitude = clonePtr(r.Longitude)
+	return r
+}
+
+func cloneResortPtr(r *models.Resort) *models.Resort {
+	if r == nil {
+		return nil
+	}
+	c := cloneResort(*r)
+	return &c
+}
+
+func cloneResorts(resorts []models.Resort) []models.Resort {
+	return cloneSlice(resorts, cloneResort)
+}
+
+func cloneResortPage(p ResortPage) ResortPage {
+	p.Resorts = cloneResorts(p.Resorts)
+	return p
+}
+
+func cloneResortDistances(resorts []models.ResortDistance) []models.ResortDistance {
+	return cloneSlice(resorts, func(r models.ResortDistance) models.ResortDistance {
+		r.Resort = cloneResort(r.Resort)
+		return r
+	})
+}
+
+func cloneWeeklyResortStats(stats []models.WeeklyResortStats) []models.WeeklyResortStats {
+	return cloneSlice(stats, func(s models.WeeklyResortStats) models.WeeklyResortStats {
+		s.TotalSnowfall = clonePtr(s.TotalSnowfall)
+		s.YearsWithData = clonePtr(s.YearsWithData)
+		s.TopElevationM = clonePtr(s.TopElevationM)
+		s.BaseElevationM = clonePtr(s.BaseElevationM)
+		s.VerticalM = clonePtr(s.VerticalM)
+		s.NumCourses = clonePtr(s.NumCourses)
+		s.LongestCourseKM = clonePtr(s.LongestCourseKM)
+		return s
+	})
+}
+
+func cloneResortsWithPeaks(resorts []models.ResortWithPeaks) []models.ResortWithPeaks {
+	return cloneSlice(resorts, func(r models.ResortWithPeaks) models.ResortWithPeaks {
+		r.Resort = cloneResort(r.Resort)
+		r.Peaks = cloneSlice(r.Peaks, cloneValue[models.PeakPeriod])
+		return r
+	})
+}
+
+func cloneRegionSummaries(summaries []models.RegionSummary) []models.RegionSummary {
+	return cloneSlice(summaries, func(s models.RegionSummary) models.RegionSummary {
+		s.TopResort = clonePtr(s.TopResort)
+		return s
+	})
+}
+
+func clonePeakPeriods(peaks []models.PeakPeriod) []models.PeakPeriod {
+	return cloneSlice(peaks, cloneValue[models.PeakPeriod])
+}
+
+func cloneSnowDepthReadings(readings []models.SnowDepthReading) []models.SnowDepthReading {
+	return cloneSlice(readings, cloneValue[models.SnowDepthReading])
+}
+
+func cloneSnowDepthByResort(readings map[string]models.SnowDepthReading) map[string]models.SnowDepthReading {
+	return cloneMap(readings, cloneValue[models.SnowDepthReading])
+}
+
+func cloneDailySnowfall(snowfalls []models.DailySnowfall) []models.DailySnowfall {
+	return cloneSlice(snowfalls, cloneValue[models.DailySnowfall])
+}
+
+func cloneSeasonSnowfallTotals(totals []models.SeasonSnowfallTotal) []models.SeasonSnowfallTotal {
+	return cloneSlice(totals, cloneValue[models.SeasonSnowfallTotal])
+}
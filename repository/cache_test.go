@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func TestCachedWriter_ServesRepeatedReadsFromCache(t *testing.T) {
+	t.Parallel()
+
+	writer := NewCachedWriter(NewWriter(newTestDB(t)), CacheOptions{})
+	id := seedSnowfall(t, writer.writer.(*WriterRepository))
+	ctx := context.Background()
+
+	for range 3 {
+		stats, err := writer.GetSnowiestResorts(ctx, "12-01", "01-31", "", 10)
+		if err != nil {
+			t.Fatalf("GetSnowiestResorts() error = %v", err)
+		}
+		if len(stats) != 1 || stats[0].ResortID != id {
+			t.Fatalf("GetSnowiestResorts() = %+v", stats)
+		}
+	}
+	// A different limit is a different query.
+	if _, err := writer.GetSnowiestResorts(ctx, "12-01", "01-31", "", 5); err != nil {
+		t.Fatalf("GetSnowiestResorts() error = %v", err)
+	}
+
+	if got, want := writer.Stats(), (CacheStats{Hits: 2, Misses: 2}); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCachedWriter_ReturnsCopies(t *testing.T) {
+	t.Parallel()
+
+	writer := NewCachedWriter(NewWriter(newTestDB(t)), CacheOptions{})
+	top := 1800
+	resort := &models.Resort{Slug: "a", Name: "A", Prefecture: "nagano", TopElevationM: &top}
+	if err := writer.SaveResort(context.Background(), resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+	ctx := context.Background()
+
+	// Modify both the result that filled the cache and one served from it.
+	for range 2 {
+		got, err := writer.GetResortBySlug(ctx, "a")
+		if err != nil {
+			t.Fatalf("GetResortBySlug() error = %v", err)
+		}
+		if got.Name != "A" || got.TopElevationM == nil || *got.TopElevationM != 1800 {
+			t.Fatalf("GetResortBySlug() = %+v, want the stored resort", got)
+		}
+		got.Name = "changed"
+		*got.TopElevationM = 0
+
+		all, err := writer.GetAllResorts(ctx)
+		if err != nil {
+			t.Fatalf("GetAllResorts() error = %v", err)
+		}
+		if len(all) != 1 || all[0].Name != "A" || *all[0].TopElevationM != 1800 {
+			t.Fatalf("GetAllResorts() = %+v, want the stored resort", all)
+		}
+		all[0].Name = "changed"
+		*all[0].TopElevationM = 0
+	}
+
+	if got, want := writer.Stats(), (CacheStats{Hits: 2, Misses: 2}); got != want {
+		t.Fatalf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCachedWriter_InvalidatesOnWrites(t *testing.T) {
+	t.Parallel()
+
+	writer := NewCachedWriter(NewWriter(newTestDB(t)), CacheOptions{})
+	ctx := context.Background()
+
+	resort := &models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"}
+	if err := writer.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+	if _, err := writer.GetAllResortsWithPeaks(ctx); err != nil {
+		t.Fatalf("GetAllResortsWithPeaks() error = %v", err)
+	}
+	if _, err := writer.GetDailySnowfall(ctx, resort.ID, day(2025, time.January, 1), day(2025, time.January, 31)); err != nil {
+		t.Fatalf("GetDailySnowfall() error = %v", err)
+	}
+
+	peaks := []models.PeakPeriod{{PeakRank: 1, StartDate: "01-10", EndDate: "01-24", CenterDate: "01-17", ConfidenceLevel: "high"}}
+	if err := writer.ReplacePeakPeriods(ctx, resort.ID, peaks); err != nil {
+		t.Fatalf("ReplacePeakPeriods() error = %v", err)
+	}
+	// Only the peak-dependent entry is dropped.
+	if got := writer.Len(); got != 1 {
+		t.Fatalf("Len() after ReplacePeakPeriods = %d, want 1", got)
+	}
+	withPeaks, err := writer.GetAllResortsWithPeaks(ctx)
+	if err != nil {
+		t.Fatalf("GetAllResortsWithPeaks() error = %v", err)
+	}
+	if len(withPeaks) != 1 {
+		t.Fatalf("GetAllResortsWithPeaks() after write = %+v", withPeaks)
+	}
+
+	if err := writer.SaveDailySnowfall(ctx, []models.DailySnowfall{{ResortID: resort.ID, Date: day(2025, time.January, 5), SnowfallCM: 20}}); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+	daily, err := writer.GetDailySnowfall(ctx, resort.ID, day(2025, time.January, 1), day(2025, time.January, 31))
+	if err != nil {
+		t.Fatalf("GetDailySnowfall() error = %v", err)
+	}
+	if len(daily) != 1 {
+		t.Fatalf("GetDailySnowfall() after write = %+v", daily)
+	}
+
+	if got := writer.Stats(); got.Hits != 0 || got.Misses != 4 {
+		t.Fatalf("Stats() = %+v, want 0 hits and 4 misses", got)
+	}
+}
+
+func TestCachedReader_ExpiresAndEvicts(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCachedReader(NewReader(db), CacheOptions{TTL: time.Minute, MaxEntries: 2})
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, slug := range []string{"a", "b", "a", "c"} {
+		cache.GetResortBySlug(ctx, slug) //nolint:errcheck
+	}
+	// Errors are not cached, so every lookup of a missing resort is a miss.
+	if got := cache.Stats(); got.Hits != 0 || got.Misses != 4 {
+		t.Fatalf("Stats() = %+v, want 4 misses", got)
+	}
+
+	for range 3 {
+		if _, err := cache.GetAllResorts(ctx); err != nil {
+			t.Fatalf("GetAllResorts() error = %v", err)
+		}
+		if _, err := cache.GetPeakPeriodsForResort(ctx, "x"); err != nil {
+			t.Fatalf("GetPeakPeriodsForResort() error = %v", err)
+		}
+	}
+	if _, err := cache.GetLatestSnowDepth(ctx, []string{"x"}); err != nil {
+		t.Fatalf("GetLatestSnowDepth() error = %v", err)
+	}
+	// GetAllResorts was least recently used and was evicted.
+	if got := cache.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+	if _, err := cache.GetAllResorts(ctx); err != nil {
+		t.Fatalf("GetAllResorts() error = %v", err)
+	}
+	if got := cache.Stats(); got.Hits != 4 || got.Misses != 8 {
+		t.Fatalf("Stats() after eviction = %+v, want 4 hits and 8 misses", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.GetAllResorts(ctx); err != nil {
+		t.Fatalf("GetAllResorts() error = %v", err)
+	}
+	if got := cache.Stats(); got.Misses != 9 {
+		t.Fatalf("Stats() after expiry = %+v, want 9 misses", got)
+	}
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/config"
+	"github.com/amaumene/snowfinder_common/dialect"
+)
+
+// Option configures a repository.
+type Option func(*options)
+
+type options struct {
+	dialect        dialect.Dialect
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	batchChunkSize int
+}
+
+// WithDialect selects the SQL dialect of the underlying database. The default
+// is dialect.SQLite.
+func WithDialect(d dialect.Dialect) Option {
+	return func(o *options) {
+		o.dialect = d
+	}
+}
+
+// WithTimeouts bounds each read and write call, and each chunk of a batch
+// write. Non-positive values keep the defaults, config.DefaultReadTimeout and
+// config.DefaultWriteTimeout.
+func WithTimeouts(read, write time.Duration) Option {
+	return func(o *options) {
+		if read > 0 {
+			o.readTimeout = read
+		}
+		if write > 0 {
+			o.writeTimeout = write
+		}
+	}
+}
+
+// WithBatchChunkSize sets how many records or IDs batch calls send per
+// statement or transaction. Non-positive values keep
+// config.DefaultBatchChunkSize.
+func WithBatchChunkSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.batchChunkSize = n
+		}
+	}
+}
+
+// WithConfig applies the timeouts and batch chunk size from cfg.
+func WithConfig(cfg config.Config) Option {
+	return func(o *options) {
+		WithTimeouts(cfg.ReadTimeout, cfg.WriteTimeout)(o)
+		WithBatchChunkSize(cfg.BatchChunkSize)(o)
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		readTimeout:    config.DefaultReadTimeout,
+		writeTimeout:   config.DefaultWriteTimeout,
+		batchChunkSize: config.DefaultBatchChunkSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// database wraps *sql.DB so that queries written with "?" placeholders are
+// rebound for the configured dialect.
+type database struct {
+	db             *sql.DB
+	dialect        dialect.Dialect
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+	batchChunkSize int
+}
+
+func newDatabase(db *sql.DB, o options) *database {
+	return &database{
+		db:             db,
+		dialect:        o.dialect,
+		readTimeout:    o.readTimeout,
+		writeTimeout:   o.writeTimeout,
+		batchChunkSize: o.batchChunkSize,
+	}
+}
+
+// readContext bounds a read call by the configured read timeout.
+func (d *database) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d.readTimeout)
+}
+
+// writeContext bounds a write call by the configured write timeout.
+func (d *database) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d.writeTimeout)
+}
+
+func (d *database) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.db.QueryContext(ctx, d.dialect.Rebind(query), args...)
+}
+
+func (d *database) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.db.QueryRowContext(ctx, d.dialect.Rebind(query), args...)
+}
+
+func (d *database) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.db.ExecContext(ctx, d.dialect.Rebind(query), args...)
+}
+
+func (d *database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*transaction, error) {
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{tx: tx, dialect: d.dialect}, nil
+}
+
+// transaction is the *sql.Tx counterpart of database.
+type transaction struct {
+	tx      *sql.Tx
+	dialect dialect.Dialect
+}
+
+func (t *transaction) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *transaction) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *transaction) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *transaction) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *transaction) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/config"
+)
+
+func TestNewOptions_AppliesConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.ReadTimeout = time.Second
+	cfg.WriteTimeout = 2 * time.Second
+	cfg.BatchChunkSize = 7
+
+	o := newOptions([]Option{WithConfig(cfg)})
+	if o.readTimeout != time.Second || o.writeTimeout != 2*time.Second || o.batchChunkSize != 7 {
+		t.Fatalf("newOptions(WithConfig) = %+v", o)
+	}
+
+	o = newOptions([]Option{WithConfig(config.Config{})})
+	if o.readTimeout != config.DefaultReadTimeout || o.writeTimeout != config.DefaultWriteTimeout || o.batchChunkSize != config.DefaultBatchChunkSize {
+		t.Fatalf("newOptions(WithConfig(zero)) = %+v, want defaults", o)
+	}
+}
+
+func TestWithBatchChunkSize_ChunksBatchCalls(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t), WithBatchChunkSize(1))
+	a, b := seedSnowDepth(t, writer)
+
+	got, err := writer.GetLatestSnowDepth(context.Background(), []string{a, b, "missing"})
+	if err != nil {
+		t.Fatalf("GetLatestSnowDepth() error = %v", err)
+	}
+	if len(got) != 2 || got[a].DepthCM != 120 || got[b].DepthCM != 60 {
+		t.Fatalf("GetLatestSnowDepth() = %+v", got)
+	}
+}
+
+func TestWithTimeouts_BoundsReads(t *testing.T) {
+	t.Parallel()
+
+	reader := NewReader(newTestDB(t), WithTimeouts(time.Nanosecond, 0))
+	if _, err := reader.GetAllResorts(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetAllResorts() error = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// ListPredictionRunsBetween returns all prediction runs generated within
+// [from, to], oldest first.
+func (r *PredictionRepository) ListPredictionRunsBetween(ctx context.Context, from, to time.Time) ([]models.PredictionRun, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT pr.id, pr.generated_at, pr.source, pr.forecast_days, pr.created_at,
+			   (SELECT COUNT(*) FROM prediction_history ph WHERE ph.run_id = pr.id)
+		FROM prediction_runs pr
+		WHERE pr.generated_at >= ? AND pr.generated_at <= ?
+		ORDER BY pr.generated_at ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("query prediction runs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPredictionRuns(rows)
+}
+
+// LoadPredictionRun returns every per-resort forecast stored for the given run,
+// keyed by resort ID.
+func (r *PredictionRepository) LoadPredictionRun(ctx context.Context, runID string) (map[string]models.Prediction, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT resort_id, prediction_data FROM prediction_history WHERE run_id = ?", runID)
+	if err != nil {
+		return nil, fmt.Errorf("query prediction history: %w", err)
+	}
+	defer rows.Close()
+
+	predictions := make(map[string]models.Prediction)
+	for rows.Next() {
+		var resortID string
+		var predData []byte
+		if err := rows.Scan(&resortID, &predData); err != nil {
+			return nil, fmt.Errorf("scan prediction history: %w", err)
+		}
+		pred, err := unmarshalHistoryPrediction(predData)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal prediction for %s in run %s: %w", resortID, runID, err)
+		}
+		predictions[resortID] = pred
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prediction history rows: %w", err)
+	}
+
+	return predictions, nil
+}
+
+// GetObservedSnowfall returns observed daily snowfall between the from and to
+// dates (inclusive, "YYYY-MM-DD"), keyed by resort ID and then by date.
+func (r *PredictionRepository) GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	return queryObservedSnowfall(ctx, r.db, from, to)
+}
+
+// SaveForecastVerification upserts forecast skill metrics keyed by resort and lead time.
+func (r *PredictionRepository) SaveForecastVerification(ctx context.Context, results []models.ForecastVerification) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin verification transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	query := `
+		INSERT INTO forecast_verification (
+			resort_id, lead_days, sample_count, mae_cm, bias_cm, range_sample_count, range_hit_rate,
+			brier_sample_count, brier_5cm, brier_10cm, brier_20cm, brier_30cm, computed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (resort_id, lead_days) DO UPDATE SET
+			sample_count = EXCLUDED.sample_count,
+			mae_cm = EXCLUDED.mae_cm,
+			bias_cm = EXCLUDED.bias_cm,
+			range_sample_count = EXCLUDED.range_sample_count,
+			range_hit_rate = EXCLUDED.range_hit_rate,
+			brier_sample_count = EXCLUDED.brier_sample_count,
+			brier_5cm = EXCLUDED.brier_5cm,
+			brier_10cm = EXCLUDED.brier_10cm,
+			brier_20cm = EXCLUDED.brier_20cm,
+			brier_30cm = EXCLUDED.brier_30cm,
+			computed_at = EXCLUDED.computed_at
+	`
+
+	for _, v := range results {
+		if _, err := tx.ExecContext(ctx, query,
+			v.ResortID, v.LeadDays, v.SampleCount, v.MAECM, v.BiasCM, v.RangeSampleCount, v.RangeHitRate,
+			v.BrierSampleCount, v.Brier5cm, v.Brier10cm, v.Brier20cm, v.Brier30cm,
+			v.ComputedAt.UTC().Format(time.RFC3339),
+		); err != nil {
+			return fmt.Errorf("save verification for %s lead %d: %w", v.ResortID, v.LeadDays, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit verification: %w", err)
+	}
+	return nil
+}
+
+// GetForecastVerification returns the stored skill metrics for a resort,
+// ordered by lead time ascending.
+func (r *PredictionRepository) GetForecastVerification(ctx context.Context, resortID string) ([]models.ForecastVerification, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT resort_id, lead_days, sample_count, mae_cm, bias_cm, range_sample_count, range_hit_rate,
+			   brier_sample_count, brier_5cm, brier_10cm, brier_20cm, brier_30cm, computed_at
+		FROM forecast_verification
+		WHERE resort_id = ?
+		ORDER BY lead_days
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, resortID)
+	if err != nil {
+		return nil, fmt.Errorf("query forecast verification: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.ForecastVerification{}
+	for rows.Next() {
+		var v models.ForecastVerification
+		if err := rows.Scan(
+			&v.ResortID, &v.LeadDays, &v.SampleCount, &v.MAECM, &v.BiasCM, &v.RangeSampleCount, &v.RangeHitRate,
+			&v.BrierSampleCount, &v.Brier5cm, &v.Brier10cm, &v.Brier20cm, &v.Brier30cm, &v.ComputedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan forecast verification: %w", err)
+		}
+		results = append(results, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate forecast verification rows: %w", err)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/amaumene/snowfinder_common/internal/geo"
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// EarthRadiusKM is the mean Earth radius used for haversine distances.
+const EarthRadiusKM = geo.EarthRadiusKM
+
+// Resorts are indexed by the cell of a geoCellDeg-degree latitude/longitude
+// grid that contains them. Lookups covering more than maxGeoCells cells fall
+// back to a latitude range scan.
+const (
+	geoCellDeg  = 0.5
+	geoCellCols = int(360 / geoCellDeg)
+	maxGeoCells = 256
+)
+
+// HaversineKM returns the great-circle distance in kilometres between two
+// points given in decimal degrees.
+func HaversineKM(lat1, lon1, lat2, lon2 float64) float64 {
+	return geo.HaversineKM(lat1, lon1, lat2, lon2)
+}
+
+// ValidateCoordinates checks that lat and lon are decimal degrees within
+// [-90, 90] and [-180, 180].
+func ValidateCoordinates(lat, lon float64) error {
+	return geo.ValidateCoordinates(lat, lon)
+}
+
+func geoCellRow(lat float64) int {
+	return min(int(math.Floor((lat+90)/geoCellDeg)), int(180/geoCellDeg)-1)
+}
+
+func geoCellCol(lon float64) int {
+	return min(int(math.Floor((lon+180)/geoCellDeg)), geoCellCols-1)
+}
+
+// geoCell returns the grid cell of a resort, or nil without coordinates.
+func geoCell(lat, lon *float64) *int {
+	if lat == nil || lon == nil {
+		return nil
+	}
+	cell := geoCellRow(*lat)*geoCellCols + geoCellCol(*lon)
+	return &cell
+}
+
+// boxWhere returns a predicate selecting resorts that may lie in b: the grid
+// cells covering b when there are few enough, otherwise a latitude range.
+// Callers must still check b.Contains.
+func boxWhere(b geo.Box) (string, []any) {
+	var cols []int
+	switch {
+	case b.AllLon:
+		cols = nil
+	case b.Wraps:
+		for c := geoCellCol(b.MinLon); c < geoCellCols; c++ {
+			cols = append(cols, c)
+		}
+		for c := 0; c <= geoCellCol(b.MaxLon); c++ {
+			cols = append(cols, c)
+		}
+	default:
+		for c := geoCellCol(b.MinLon); c <= geoCellCol(b.MaxLon); c++ {
+			cols = append(cols, c)
+		}
+	}
+
+	minRow, maxRow := geoCellRow(b.MinLat), geoCellRow(b.MaxLat)
+	if cols != nil && (maxRow-minRow+1)*len(cols) <= maxGeoCells {
+		var cells []any
+		for row := minRow; row <= maxRow; row++ {
+			for _, col := range cols {
+				cells = append(cells, row*geoCellCols+col)
+			}
+		}
+		return "geo_cell IN (" + placeholders(len(cells)) + ")", cells
+	}
+	return "latitude BETWEEN ? AND ? AND longitude IS NOT NULL", []any{b.MinLat, b.MaxLat}
+}
+
+// queryResortsInBox returns the resorts inside b ordered by prefecture and name.
+func (r *ReaderRepository) queryResortsInBox(ctx context.Context, b geo.Box) ([]models.Resort, error) {
+	// SAFETY: the predicate is built from constants and placeholders only.
+	where, args := boxWhere(b)
+	query := `
+		SELECT ` + resortColumns + `
+		FROM resorts
+		WHERE ` + where + `
+		ORDER BY prefecture, name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query resorts in area: %w", err)
+	}
+	defer rows.Close()
+
+	resorts := []models.Resort{}
+	for rows.Next() {
+		resort, err := scanResort(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan resort: %w", err)
+		}
+		if resort.Latitude != nil && resort.Longitude != nil && b.Contains(*resort.Latitude, *resort.Longitude) {
+			resorts = append(resorts, resort)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return resorts, nil
+}
+
+// GetResortsNear returns up to limit resorts within radiusKM of lat/lon,
+// nearest first. Distances are haversine great-circle distances. Resorts
+// without coordinates are never returned.
+func (r *ReaderRepository) GetResortsNear(ctx context.Context, lat, lon, radiusKM float64, limit int) ([]models.ResortDistance, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if err := ValidateCoordinates(lat, lon); err != nil {
+		return nil, err
+	}
+	if !(radiusKM > 0) {
+		return nil, fmt.Errorf("radius must be positive: %v", radiusKM)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	candidates, err := r.queryResortsInBox(ctx, geo.AroundPoint(lat, lon, radiusKM))
+	if err != nil {
+		return nil, err
+	}
+	return geo.Nearest(candidates, lat, lon, radiusKM, limit), nil
+}
+
+// GetResortsInBBox returns the resorts inside the box bounded by the given
+// corners (inclusive), ordered by prefecture and name. A box with minLon
+// greater than maxLon crosses the antimeridian.
+func (r *ReaderRepository) GetResortsInBBox(ctx context.Context, minLat, minLon, maxLat, maxLon float64) ([]models.Resort, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	b, err := geo.NewBox(minLat, minLon, maxLat, maxLon)
+	if err != nil {
+		return nil, err
+	}
+	return r.queryResortsInBox(ctx, b)
+}
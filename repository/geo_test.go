@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amaumene/snowfinder_common/internal/geo"
+)
+
+func TestBoxWhere_UsesGridCellsForSmallAreas(t *testing.T) {
+	t.Parallel()
+
+	where, args := boxWhere(geo.AroundPoint(36.7, 137.8, 20))
+	if !strings.HasPrefix(where, "geo_cell IN") || len(args) == 0 || len(args) > maxGeoCells {
+		t.Fatalf("boxWhere() = %q with %d args, want grid cells", where, len(args))
+	}
+	cell := *geoCell(ptr(36.7), ptr(137.8))
+	found := false
+	for _, a := range args {
+		found = found || a == cell
+	}
+	if !found {
+		t.Fatalf("boxWhere() cells %v miss the centre cell %d", args, cell)
+	}
+
+	where, _ = boxWhere(geo.AroundPoint(36.7, 137.8, 2000))
+	if !strings.HasPrefix(where, "latitude BETWEEN") {
+		t.Fatalf("boxWhere() = %q, want latitude range for large areas", where)
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/amaumene/snowfinder_common/models"
 )
@@ -11,6 +12,10 @@ import (
 type Reader interface {
 	GetResortBySlug(ctx context.Context, slug string) (*models.Resort, error)
 	GetResortByID(ctx context.Context, id string) (*models.Resort, error)
+	GetAllResorts(ctx context.Context) ([]models.Resort, error)
+	SearchResorts(ctx context.Context, q ResortQuery) (ResortPage, error)
+	GetResortsNear(ctx context.Context, lat, lon, radiusKM float64, limit int) ([]models.ResortDistance, error)
+	GetResortsInBBox(ctx context.Context, minLat, minLon, maxLat, maxLon float64) ([]models.Resort, error)
 	// GetSnowiestResorts supports two input modes:
 	//   - weekly mode when endDate == "": startDate must be YYYY-MM-DD and the query covers 7 days
 	//   - seasonal range mode when endDate != "": startDate and endDate must both be MM-DD
@@ -18,15 +23,39 @@ type Reader interface {
 	GetAllResortsWithPeaks(ctx context.Context) ([]models.ResortWithPeaks, error)
 	GetPeakPeriodsForResort(ctx context.Context, resortID string) ([]models.PeakPeriod, error)
 	GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error)
+	GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error)
+	GetDeadLetteredScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error)
+	GetSnowDepthHistory(ctx context.Context, resortID string, from, to time.Time) ([]models.SnowDepthReading, error)
+	GetLatestSnowDepth(ctx context.Context, resortIDs []string) (map[string]models.SnowDepthReading, error)
+	GetSeasonMaxSnowDepth(ctx context.Context, resortIDs []string, asOf time.Time) (map[string]models.SnowDepthReading, error)
+	GetDailySnowfall(ctx context.Context, resortID string, from, to time.Time) ([]models.DailySnowfall, error)
+	GetSeasonSnowfallTotals(ctx context.Context, resortID string, seasons int, throughMMDD string) ([]models.SeasonSnowfallTotal, error)
+	GetRegionSummaries(ctx context.Context, from, to time.Time, groupBy RegionGrouping) ([]models.RegionSummary, error)
+}
+
+// PredictionReader provides read-only access to stored predictions.
+// This interface is used by the web application to render forecasts.
+type PredictionReader interface {
+	GetPrediction(ctx context.Context, resortID string) (*models.Prediction, error)
+	GetPredictionsForResorts(ctx context.Context, resortIDs []string) (map[string]models.Prediction, error)
+	LoadPredictionData(ctx context.Context) (*models.PredictionData, error)
+	ListPredictionRuns(ctx context.Context, limit int) ([]models.PredictionRun, error)
+	GetPredictionForRun(ctx context.Context, runID, resortID string) (*models.Prediction, error)
+	GetForecastVerification(ctx context.Context, resortID string) ([]models.ForecastVerification, error)
+	GetRegionForecasts(ctx context.Context, from time.Time, days int, groupBy RegionGrouping) ([]models.RegionForecast, error)
 }
 
 // Writer provides full read-write access to the database.
 // This interface is used by the scraper to save collected data.
 type Writer interface {
 	Reader
+	// SaveResort keeps a resort's stored coordinates when it is saved without
+	// any; coordinates can be replaced but not removed.
 	SaveResort(ctx context.Context, resort *models.Resort) error
 	SaveSnowDepthReadings(ctx context.Context, readings []models.SnowDepthReading) error
 	SaveDailySnowfall(ctx context.Context, snowfalls []models.DailySnowfall) error
 	SaveFailedScrapeAttempt(ctx context.Context, resortURL, errorMessage string) error
+	SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error
 	MarkFailedAttemptRetried(ctx context.Context, id string) error
+	ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error
 }
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// ListPredictionRuns returns the most recent prediction runs, newest first,
+// with the number of resorts stored for each run.
+func (r *PredictionRepository) ListPredictionRuns(ctx context.Context, limit int) ([]models.PredictionRun, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	query := `
+		SELECT pr.id, pr.generated_at, pr.source, pr.forecast_days, pr.created_at,
+			   (SELECT COUNT(*) FROM prediction_history ph WHERE ph.run_id = pr.id)
+		FROM prediction_runs pr
+		ORDER BY pr.generated_at DESC, pr.created_at DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query prediction runs: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPredictionRuns(rows)
+}
+
+// scanPredictionRuns scans sql rows into a slice of PredictionRun.
+func scanPredictionRuns(rows *sql.Rows) ([]models.PredictionRun, error) {
+	runs := []models.PredictionRun{}
+	for rows.Next() {
+		var run models.PredictionRun
+		if err := rows.Scan(&run.ID, &run.GeneratedAt, &run.Source, &run.ForecastDays, &run.CreatedAt, &run.ResortCount); err != nil {
+			return nil, fmt.Errorf("scan prediction run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prediction run rows: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetPredictionForRun returns a resort's forecast as it was stored by the given run.
+// Returns sql.ErrNoRows (wrapped) if the run did not include the resort.
+func (r *PredictionRepository) GetPredictionForRun(ctx context.Context, runID, resortID string) (*models.Prediction, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	var predData []byte
+	err := r.db.QueryRowContext(ctx,
+		"SELECT prediction_data FROM prediction_history WHERE run_id = ? AND resort_id = ?",
+		runID, resortID,
+	).Scan(&predData)
+	if err != nil {
+		return nil, fmt.Errorf("get prediction for %s in run %s: %w", resortID, runID, err)
+	}
+
+	pred, err := unmarshalHistoryPrediction(predData)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal prediction for %s in run %s: %w", resortID, runID, err)
+	}
+	return &pred, nil
+}
+
+// PrunePredictionRuns deletes runs generated before now minus retention,
+// together with their per-resort history rows. The latest per-resort
+// predictions table is not affected. Returns the number of runs deleted.
+func (r *PredictionRepository) PrunePredictionRuns(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	if retention <= 0 {
+		return 0, errors.New("retention must be positive")
+	}
+	cutoff := time.Now().UTC().Add(-retention).Format(time.RFC3339)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin prune transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	// History rows are deleted explicitly rather than relying on ON DELETE CASCADE,
+	// which SQLite only honours when foreign_keys is enabled on the connection.
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM prediction_history
+		WHERE run_id IN (SELECT id FROM prediction_runs WHERE generated_at < ?)
+	`, cutoff); err != nil {
+		return 0, fmt.Errorf("prune prediction history: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM prediction_runs WHERE generated_at < ?", cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("prune prediction runs: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune prediction runs: rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit prune: %w", err)
+	}
+
+	return deleted, nil
+}
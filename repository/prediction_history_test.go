@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func TestPredictionRepositorySavePredictionRun_KeepsHistory(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	first, err := repo.SavePredictionRun(ctx, &models.PredictionData{
+		GeneratedAt:  "2025-01-15T06:00:00Z",
+		Source:       "jma",
+		ForecastDays: 7,
+		Resorts:      map[string]models.Prediction{"resort-1": {Name: "First"}},
+	})
+	if err != nil {
+		t.Fatalf("first SavePredictionRun() error = %v", err)
+	}
+	second, err := repo.SavePredictionRun(ctx, &models.PredictionData{
+		GeneratedAt:  "2025-01-15T18:00:00+09:00",
+		Source:       "jma",
+		ForecastDays: 7,
+		Resorts:      map[string]models.Prediction{"resort-1": {Name: "Second"}},
+	})
+	if err != nil {
+		t.Fatalf("second SavePredictionRun() error = %v", err)
+	}
+
+	runs, err := repo.ListPredictionRuns(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListPredictionRuns() error = %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("ListPredictionRuns() returned %d runs, want 2", len(runs))
+	}
+	if runs[0].ID != second.ID || runs[1].ID != first.ID {
+		t.Fatalf("ListPredictionRuns() order = [%s %s], want [%s %s]", runs[0].ID, runs[1].ID, second.ID, first.ID)
+	}
+	if runs[0].Source != "jma" || runs[0].ForecastDays != 7 || runs[0].ResortCount != 1 {
+		t.Fatalf("ListPredictionRuns()[0] = %+v", runs[0])
+	}
+	if !runs[0].GeneratedAt.Equal(time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("GeneratedAt = %s, want 2025-01-15T09:00:00Z", runs[0].GeneratedAt)
+	}
+
+	old, err := repo.GetPredictionForRun(ctx, first.ID, "resort-1")
+	if err != nil {
+		t.Fatalf("GetPredictionForRun() error = %v", err)
+	}
+	if old.Name != "First" {
+		t.Fatalf("GetPredictionForRun() name = %q, want First", old.Name)
+	}
+
+	latest, err := repo.GetPrediction(ctx, "resort-1")
+	if err != nil {
+		t.Fatalf("GetPrediction() error = %v", err)
+	}
+	if latest.Name != "Second" {
+		t.Fatalf("GetPrediction() name = %q, want Second", latest.Name)
+	}
+}
+
+func TestPredictionRepositoryGetPredictionForRun_NotFound(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+
+	_, err := repo.GetPredictionForRun(context.Background(), "missing-run", "resort-1")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetPredictionForRun() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestPredictionRepositoryPrunePredictionRuns_DeletesOldRuns(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	for _, generatedAt := range []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -1)} {
+		_, err := repo.SavePredictionRun(ctx, &models.PredictionData{
+			GeneratedAt: generatedAt.Format(time.RFC3339),
+			Resorts:     map[string]models.Prediction{"resort-1": {Name: "One"}},
+		})
+		if err != nil {
+			t.Fatalf("SavePredictionRun() error = %v", err)
+		}
+	}
+
+	deleted, err := repo.PrunePredictionRuns(ctx, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("PrunePredictionRuns() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("PrunePredictionRuns() deleted %d runs, want 1", deleted)
+	}
+
+	var historyRows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM prediction_history").Scan(&historyRows); err != nil {
+		t.Fatalf("count prediction history: %v", err)
+	}
+	if historyRows != 1 {
+		t.Fatalf("prediction_history rows = %d, want 1", historyRows)
+	}
+
+	if _, err := repo.PrunePredictionRuns(ctx, 0); err == nil {
+		t.Fatal("expected error for non-positive retention")
+	}
+}
+
+func TestPredictionRepositorySavePredictionRun_RecordsCallerBiasFactors(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	// Configured factors are not what a run applied unless the caller says so.
+	if err := repo.SavePredictionConfig(ctx, map[string]models.PredictorResortConfig{
+		"resort-1": {Name: "One", BiasFactors: map[string]float64{"msm": 1.2}},
+	}); err != nil {
+		t.Fatalf("SavePredictionConfig() error = %v", err)
+	}
+	run, err := repo.SavePredictionRun(ctx, &models.PredictionData{
+		GeneratedAt: "2025-01-15T06:00:00Z",
+		Source:      "blend",
+		Resorts: map[string]models.Prediction{
+			"resort-1": {Name: "One"},
+			"resort-3": {Name: "Three", BiasFactors: map[string]float64{"gsm": 0.9}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SavePredictionRun() error = %v", err)
+	}
+
+	preds, err := repo.LoadPredictionRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("LoadPredictionRun() error = %v", err)
+	}
+	if got := preds["resort-1"].BiasFactors; got != nil {
+		t.Fatalf("resort-1 bias factors = %v, want nil when the caller recorded none", got)
+	}
+	if got := preds["resort-3"].BiasFactors; len(got) != 1 || got["gsm"] != 0.9 {
+		t.Fatalf("resort-3 bias factors = %v, want the caller's", got)
+	}
+	if pred, err := repo.GetPredictionForRun(ctx, run.ID, "resort-3"); err != nil || pred.BiasFactors["gsm"] != 0.9 {
+		t.Fatalf("GetPredictionForRun() = %+v, %v, want the caller's bias factors", pred, err)
+	}
+
+	// The served prediction leaves the factors out.
+	latest, err := repo.GetPrediction(ctx, "resort-3")
+	if err != nil {
+		t.Fatalf("GetPrediction() error = %v", err)
+	}
+	if latest.BiasFactors != nil {
+		t.Fatalf("GetPrediction() bias factors = %v, want nil", latest.BiasFactors)
+	}
+	served, err := json.Marshal(latest)
+	if err != nil {
+		t.Fatalf("marshal prediction: %v", err)
+	}
+	if strings.Contains(string(served), "bias_factors") {
+		t.Fatalf("served prediction JSON %s contains bias_factors", served)
+	}
+}
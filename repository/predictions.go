@@ -6,26 +6,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/amaumene/snowfinder_common/models"
+	"github.com/google/uuid"
 )
 
 // PredictionRepository provides access to prediction-related tables.
 type PredictionRepository struct {
-	db *sql.DB
+	db *database
 }
 
 // NewPredictionRepository creates a new prediction repository.
-func NewPredictionRepository(db *sql.DB) *PredictionRepository {
+func NewPredictionRepository(db *sql.DB, opts ...Option) *PredictionRepository {
 	return &PredictionRepository{
-		db: db,
+		db: newDatabase(db, newOptions(opts)),
 	}
 }
 
 // LoadPredictionConfig loads per-resort config from prediction_config table.
 func (r *PredictionRepository) LoadPredictionConfig(ctx context.Context) (map[string]models.PredictorResortConfig, error) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	rows, err := r.db.QueryContext(ctx, "SELECT resort_id, config_data FROM prediction_config")
@@ -54,9 +56,48 @@ func (r *PredictionRepository) LoadPredictionConfig(ctx context.Context) (map[st
 	return resorts, nil
 }
 
+// SavePredictionConfig upserts per-resort config into the prediction_config table
+// in a single transaction.
+func (r *PredictionRepository) SavePredictionConfig(ctx context.Context, configs map[string]models.PredictorResortConfig) error {
+	if len(configs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin prediction_config transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	query := `INSERT INTO prediction_config (resort_id, config_data, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (resort_id) DO UPDATE
+		SET config_data = EXCLUDED.config_data,
+		    updated_at = EXCLUDED.updated_at`
+
+	for resortID, cfg := range configs {
+		configJSON, err := json.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf("marshal config for %s: %w", resortID, err)
+		}
+		if _, err := tx.ExecContext(ctx, query, resortID, configJSON); err != nil {
+			return fmt.Errorf("saving config for resort %s: %w", resortID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit prediction_config: %w", err)
+	}
+
+	return nil
+}
+
 // LoadGlobalParams loads global predictor parameters.
 func (r *PredictionRepository) LoadGlobalParams(ctx context.Context) (models.GlobalParams, error) {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	var paramsData []byte
@@ -76,46 +117,217 @@ func (r *PredictionRepository) LoadGlobalParams(ctx context.Context) (models.Glo
 	return params, nil
 }
 
-// SavePredictions upserts all predictions using INSERT ON CONFLICT.
+// SavePredictions upserts all predictions using INSERT ON CONFLICT and records
+// the run in the prediction history. See SavePredictionRun.
 func (r *PredictionRepository) SavePredictions(ctx context.Context, predictions *models.PredictionData) error {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	_, err := r.SavePredictionRun(ctx, predictions)
+	return err
+}
+
+// SavePredictionRun upserts the latest prediction per resort and stores the run
+// as a new versioned record in prediction_runs/prediction_history, all in one
+// transaction. The history keeps each prediction's BiasFactors as given, so
+// callers must set them to the factors they applied for the run to be usable
+// by calibration. Returns the recorded run, or nil if predictions has no resorts.
+func (r *PredictionRepository) SavePredictionRun(ctx context.Context, predictions *models.PredictionData) (*models.PredictionRun, error) {
+	ctx, cancel := r.db.writeContext(ctx)
 	defer cancel()
 
 	if predictions == nil {
-		return errors.New("nil prediction data")
+		return nil, errors.New("nil prediction data")
 	}
 	if len(predictions.Resorts) == 0 {
-		return nil
+		return nil, nil
 	}
-	if _, err := time.Parse(time.RFC3339, predictions.GeneratedAt); err != nil {
-		return fmt.Errorf("invalid generated_at %q: %w", predictions.GeneratedAt, err)
+	generatedAt, err := time.Parse(time.RFC3339, predictions.GeneratedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated_at %q: %w", predictions.GeneratedAt, err)
 	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("begin prediction transaction: %w", err)
+		return nil, fmt.Errorf("begin prediction transaction: %w", err)
 	}
 	defer tx.Rollback() //nolint:errcheck
 
+	run := &models.PredictionRun{
+		ID:           uuid.New().String(),
+		GeneratedAt:  generatedAt.UTC(),
+		Source:       predictions.Source,
+		ForecastDays: predictions.ForecastDays,
+		ResortCount:  len(predictions.Resorts),
+	}
+	// Both tables store generated_at as UTC RFC3339 so it compares as text.
+	generatedAtText := run.GeneratedAt.Format(time.RFC3339)
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO prediction_runs (id, generated_at, source, forecast_days) VALUES (?, ?, ?, ?)",
+		run.ID, generatedAtText, run.Source, run.ForecastDays,
+	); err != nil {
+		return nil, fmt.Errorf("save prediction run: %w", err)
+	}
+
 	query := `INSERT INTO predictions (resort_id, prediction_data, generated_at)
 		VALUES (?, ?, ?)
 		ON CONFLICT (resort_id) DO UPDATE
 		SET prediction_data = EXCLUDED.prediction_data,
 		    generated_at = EXCLUDED.generated_at`
+	historyQuery := `INSERT INTO prediction_history (run_id, resort_id, prediction_data)
+		VALUES (?, ?, ?)`
 
 	for resortID, pred := range predictions.Resorts {
 		predJSON, err := json.Marshal(pred)
 		if err != nil {
-			return fmt.Errorf("marshal prediction for %s: %w", resortID, err)
+			return nil, fmt.Errorf("marshal prediction for %s: %w", resortID, err)
+		}
+		historyJSON, err := json.Marshal(historyPrediction{Prediction: pred, BiasFactors: pred.BiasFactors})
+		if err != nil {
+			return nil, fmt.Errorf("marshal prediction history for %s: %w", resortID, err)
+		}
+		if _, err := tx.ExecContext(ctx, query, resortID, predJSON, generatedAtText); err != nil {
+			return nil, fmt.Errorf("saving prediction for resort %s: %w", resortID, err)
 		}
-		if _, err := tx.ExecContext(ctx, query, resortID, predJSON, predictions.GeneratedAt); err != nil {
-			return fmt.Errorf("saving prediction for resort %s: %w", resortID, err)
+		if _, err := tx.ExecContext(ctx, historyQuery, run.ID, resortID, historyJSON); err != nil {
+			return nil, fmt.Errorf("saving prediction history for resort %s: %w", resortID, err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit predictions: %w", err)
+		return nil, fmt.Errorf("commit predictions: %w", err)
 	}
 
-	return nil
+	return run, nil
+}
+
+// historyPrediction is a prediction as stored in prediction_history, which
+// unlike the served JSON keeps the bias factors the predictor applied.
+type historyPrediction struct {
+	models.Prediction
+	BiasFactors map[string]float64 `json:"bias_factors,omitempty"`
+}
+
+// unmarshalHistoryPrediction decodes a prediction_history row.
+func unmarshalHistoryPrediction(data []byte) (models.Prediction, error) {
+	var h historyPrediction
+	if err := json.Unmarshal(data, &h); err != nil {
+		return models.Prediction{}, err
+	}
+	h.Prediction.BiasFactors = h.BiasFactors
+	return h.Prediction, nil
+}
+
+// GetPrediction returns the stored prediction for a single resort.
+// Returns sql.ErrNoRows (wrapped) if no prediction exists for the resort.
+func (r *PredictionRepository) GetPrediction(ctx context.Context, resortID string) (*models.Prediction, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	var predData []byte
+	err := r.db.QueryRowContext(ctx,
+		"SELECT prediction_data FROM predictions WHERE resort_id = ?", resortID,
+	).Scan(&predData)
+	if err != nil {
+		return nil, fmt.Errorf("get prediction for %s: %w", resortID, err)
+	}
+
+	var pred models.Prediction
+	if err := json.Unmarshal(predData, &pred); err != nil {
+		return nil, fmt.Errorf("unmarshal prediction for %s: %w", resortID, err)
+	}
+	return &pred, nil
+}
+
+// GetPredictionsForResorts returns the stored predictions for the given resort IDs,
+// keyed by resort ID. Resorts without a stored prediction are omitted from the result.
+func (r *PredictionRepository) GetPredictionsForResorts(ctx context.Context, resortIDs []string) (map[string]models.Prediction, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	predictions := make(map[string]models.Prediction, len(resortIDs))
+	for start := 0; start < len(resortIDs); start += r.db.batchChunkSize {
+		end := start + r.db.batchChunkSize
+		if end > len(resortIDs) {
+			end = len(resortIDs)
+		}
+		chunk := resortIDs[start:end]
+
+		args := stringArgs(chunk)
+		// SAFETY: only placeholders are interpolated, values are bound
+		query := fmt.Sprintf(
+			"SELECT resort_id, prediction_data, generated_at FROM predictions WHERE resort_id IN (%s)",
+			placeholders(len(chunk)),
+		)
+		if _, err := r.queryPredictions(ctx, query, args, predictions); err != nil {
+			return nil, err
+		}
+	}
+
+	return predictions, nil
+}
+
+// LoadPredictionData rebuilds the full PredictionData from the predictions table.
+// GeneratedAt is the most recent generated_at across all rows and ForecastDays is
+// the longest daily forecast stored; Source is not persisted and is left empty.
+// Returns an empty PredictionData (with no GeneratedAt) if no predictions are stored.
+func (r *PredictionRepository) LoadPredictionData(ctx context.Context) (*models.PredictionData, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	data := &models.PredictionData{Resorts: make(map[string]models.Prediction)}
+	latest, err := r.queryPredictions(ctx,
+		"SELECT resort_id, prediction_data, generated_at FROM predictions", nil, data.Resorts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !latest.IsZero() {
+		data.GeneratedAt = latest.UTC().Format(time.RFC3339)
+	}
+	for _, pred := range data.Resorts {
+		if len(pred.Daily) > data.ForecastDays {
+			data.ForecastDays = len(pred.Daily)
+		}
+	}
+
+	return data, nil
+}
+
+// queryPredictions runs a query selecting (resort_id, prediction_data, generated_at),
+// unmarshals every row into dst, and returns the latest generated_at seen.
+func (r *PredictionRepository) queryPredictions(ctx context.Context, query string, args []any, dst map[string]models.Prediction) (time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query predictions: %w", err)
+	}
+	defer rows.Close()
+
+	var latest time.Time
+	for rows.Next() {
+		var resortID string
+		var predData []byte
+		var generatedAt time.Time
+		if err := rows.Scan(&resortID, &predData, &generatedAt); err != nil {
+			return time.Time{}, fmt.Errorf("scan prediction: %w", err)
+		}
+		var pred models.Prediction
+		if err := json.Unmarshal(predData, &pred); err != nil {
+			return time.Time{}, fmt.Errorf("unmarshal prediction for %s: %w", resortID, err)
+		}
+		dst[resortID] = pred
+		if generatedAt.After(latest) {
+			latest = generatedAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("iterate prediction rows: %w", err)
+	}
+
+	return latest, nil
+}
+
+// placeholders returns n comma-separated "?" bind placeholders.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
 }
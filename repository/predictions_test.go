@@ -3,10 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"math"
 	"testing"
 	"time"
 
+	"github.com/amaumene/snowfinder_common/migrations"
 	"github.com/amaumene/snowfinder_common/models"
 	_ "modernc.org/sqlite"
 )
@@ -17,28 +19,26 @@ func newTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatalf("open test db: %v", err)
 	}
+	// A single connection keeps every query on the same in-memory database.
+	db.SetMaxOpenConns(1)
 	t.Cleanup(func() { db.Close() })
 
-	_, err = db.Exec(`CREATE TABLE predictions (
-		resort_id TEXT PRIMARY KEY,
-		prediction_data BLOB NOT NULL,
-		generated_at DATETIME NOT NULL
-	)`)
-	if err != nil {
-		t.Fatalf("create predictions table: %v", err)
+	if err := migrations.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate test db: %v", err)
 	}
 
 	return db
 }
 
+// newTestDBWithPredictionsSchema returns a migrated database whose predictions
+// table is replaced by the given schema.
 func newTestDBWithPredictionsSchema(t *testing.T, schema string) *sql.DB {
 	t.Helper()
-	db, err := sql.Open("sqlite", ":memory:")
-	if err != nil {
-		t.Fatalf("open test db: %v", err)
-	}
-	t.Cleanup(func() { db.Close() })
+	db := newTestDB(t)
 
+	if _, err := db.Exec("DROP TABLE predictions"); err != nil {
+		t.Fatalf("drop predictions table: %v", err)
+	}
 	if _, err := db.Exec(schema); err != nil {
 		t.Fatalf("create predictions table: %v", err)
 	}
@@ -194,4 +194,169 @@ func TestPredictionRepositorySavePredictions_RollsBackOnMidTransactionFailure(t
 	if count != 0 {
 		t.Fatalf("saved %d predictions after rollback, want 0", count)
 	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM prediction_runs").Scan(&count); err != nil {
+		t.Fatalf("count prediction runs: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("saved %d prediction runs after rollback, want 0", count)
+	}
+}
+
+func TestPredictionRepositoryGetPrediction_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+
+	predictions := &models.PredictionData{
+		GeneratedAt: "2025-01-15T06:00:00Z",
+		Resorts: map[string]models.Prediction{
+			"resort-1": {Name: "One", Daily: []models.DailyForecast{{Date: "2025-01-15", SnowfallCM: 12.5}}},
+		},
+	}
+	if err := repo.SavePredictions(context.Background(), predictions); err != nil {
+		t.Fatalf("SavePredictions() error = %v", err)
+	}
+
+	got, err := repo.GetPrediction(context.Background(), "resort-1")
+	if err != nil {
+		t.Fatalf("GetPrediction() error = %v", err)
+	}
+	if got.Name != "One" || len(got.Daily) != 1 || got.Daily[0].SnowfallCM != 12.5 {
+		t.Fatalf("GetPrediction() = %+v", got)
+	}
+}
+
+func TestPredictionRepositoryGetPrediction_NotFound(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+
+	_, err := repo.GetPrediction(context.Background(), "missing")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetPrediction() error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestPredictionRepositoryGetPredictionsForResorts_OmitsMissing(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+
+	predictions := &models.PredictionData{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Resorts: map[string]models.Prediction{
+			"resort-1": {Name: "One"},
+			"resort-2": {Name: "Two"},
+			"resort-3": {Name: "Three"},
+		},
+	}
+	if err := repo.SavePredictions(context.Background(), predictions); err != nil {
+		t.Fatalf("SavePredictions() error = %v", err)
+	}
+
+	got, err := repo.GetPredictionsForResorts(context.Background(), []string{"resort-1", "resort-3", "missing"})
+	if err != nil {
+		t.Fatalf("GetPredictionsForResorts() error = %v", err)
+	}
+	if len(got) != 2 || got["resort-1"].Name != "One" || got["resort-3"].Name != "Three" {
+		t.Fatalf("GetPredictionsForResorts() = %+v", got)
+	}
+
+	empty, err := repo.GetPredictionsForResorts(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("GetPredictionsForResorts(nil) error = %v", err)
+	}
+	if len(empty) != 0 {
+		t.Fatalf("GetPredictionsForResorts(nil) = %+v, want empty", empty)
+	}
+}
+
+func TestPredictionRepositorySavePredictionRun_NormalisesGeneratedAt(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	if _, err := repo.SavePredictionRun(ctx, &models.PredictionData{
+		GeneratedAt: "2025-01-15T18:00:00+09:00",
+		Resorts:     map[string]models.Prediction{"resort-1": {Name: "One"}},
+	}); err != nil {
+		t.Fatalf("SavePredictionRun() error = %v", err)
+	}
+
+	// Both tables hold the same UTC text, so generated_at compares across them.
+	var matching int
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM predictions p
+		JOIN prediction_runs pr ON pr.generated_at = p.generated_at
+		WHERE p.generated_at = ?`, "2025-01-15T09:00:00Z",
+	).Scan(&matching); err != nil {
+		t.Fatalf("query generated_at: %v", err)
+	}
+	if matching != 1 {
+		t.Fatalf("rows with matching UTC generated_at = %d, want 1", matching)
+	}
+}
+
+func TestPredictionRepositoryLoadPredictionData_RebuildsGeneratedAt(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+
+	predictions := &models.PredictionData{
+		GeneratedAt: "2025-01-15T06:00:00Z",
+		Resorts: map[string]models.Prediction{
+			"resort-1": {Name: "One", Daily: make([]models.DailyForecast, 3)},
+			"resort-2": {Name: "Two", Daily: make([]models.DailyForecast, 7)},
+		},
+	}
+	if err := repo.SavePredictions(context.Background(), predictions); err != nil {
+		t.Fatalf("SavePredictions() error = %v", err)
+	}
+
+	got, err := repo.LoadPredictionData(context.Background())
+	if err != nil {
+		t.Fatalf("LoadPredictionData() error = %v", err)
+	}
+	if got.GeneratedAt != predictions.GeneratedAt {
+		t.Fatalf("GeneratedAt = %q, want %q", got.GeneratedAt, predictions.GeneratedAt)
+	}
+	if got.ForecastDays != 7 {
+		t.Fatalf("ForecastDays = %d, want 7", got.ForecastDays)
+	}
+	if len(got.Resorts) != 2 {
+		t.Fatalf("loaded %d resorts, want 2", len(got.Resorts))
+	}
+}
+
+func TestPredictionRepositorySavePredictionConfig_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	configs := map[string]models.PredictorResortConfig{
+		"resort-1": {Name: "One", BiasFactors: map[string]float64{"msm": 1.1}},
+	}
+	if err := repo.SavePredictionConfig(ctx, configs); err != nil {
+		t.Fatalf("SavePredictionConfig() error = %v", err)
+	}
+	configs["resort-1"] = models.PredictorResortConfig{Name: "One", BiasFactors: map[string]float64{"msm": 0.8}}
+	if err := repo.SavePredictionConfig(ctx, configs); err != nil {
+		t.Fatalf("second SavePredictionConfig() error = %v", err)
+	}
+
+	got, err := repo.LoadPredictionConfig(ctx)
+	if err != nil {
+		t.Fatalf("LoadPredictionConfig() error = %v", err)
+	}
+	if len(got) != 1 || got["resort-1"].BiasFactors["msm"] != 0.8 {
+		t.Fatalf("LoadPredictionConfig() = %+v", got)
+	}
 }
@@ -6,17 +6,18 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/amaumene/snowfinder_common/dialect"
 	"github.com/amaumene/snowfinder_common/models"
 )
 
 // ReaderRepository provides read-only database access.
 type ReaderRepository struct {
-	db *sql.DB
+	db *database
 }
 
 // NewReader creates a new read-only repository.
-func NewReader(db *sql.DB) *ReaderRepository {
-	return &ReaderRepository{db: db}
+func NewReader(db *sql.DB, opts ...Option) *ReaderRepository {
+	return &ReaderRepository{db: newDatabase(db, newOptions(opts))}
 }
 
 // doyToMMDD converts a day-of-year integer (1-366) to "MM-DD" string.
@@ -45,31 +46,45 @@ func mmddToDOY(mmdd string) (int, error) {
 // arg is the corresponding bind value.
 func (r *ReaderRepository) getResort(ctx context.Context, whereClause string, arg any) (*models.Resort, error) {
 	query := fmt.Sprintf(`
-		SELECT id, slug, name, prefecture, region,
-			   top_elevation_m, base_elevation_m, vertical_m,
-			   num_courses, longest_course_km, steepest_course_deg,
-			   last_updated
+		SELECT %s
 		FROM resorts
 		WHERE %s
-	`, whereClause)
+	`, resortColumns, whereClause)
+
+	resort, err := scanResort(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		return nil, err
+	}
+	return &resort, nil
+}
+
+// resortColumns is the column list read by scanResort.
+const resortColumns = `id, slug, name, prefecture, region,
+			   top_elevation_m, base_elevation_m, vertical_m,
+			   num_courses, longest_course_km, steepest_course_deg,
+			   latitude, longitude, last_updated`
 
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanResort scans a row selected with resortColumns.
+func scanResort(row rowScanner) (models.Resort, error) {
 	var resort models.Resort
-	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+	err := row.Scan(
 		&resort.ID, &resort.Slug, &resort.Name, &resort.Prefecture, &resort.Region,
 		&resort.TopElevationM, &resort.BaseElevationM, &resort.VerticalM,
 		&resort.NumCourses, &resort.LongestCourseKM, &resort.SteepestCourseDeg,
-		&resort.LastUpdated,
+		&resort.Latitude, &resort.Longitude, &resort.LastUpdated,
 	)
-	if err != nil {
-		return nil, err
-	}
-	return &resort, nil
+	return resort, err
 }
 
 // GetResortBySlug returns the resort with the given URL slug.
 // Returns sql.ErrNoRows (wrapped) if no matching resort exists.
 func (r *ReaderRepository) GetResortBySlug(ctx context.Context, slug string) (*models.Resort, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	// SAFETY: whereClause is hardcoded, not user-supplied
@@ -83,7 +98,7 @@ func (r *ReaderRepository) GetResortBySlug(ctx context.Context, slug string) (*m
 // GetResortByID returns the resort with the given UUID.
 // Returns sql.ErrNoRows (wrapped) if no matching resort exists.
 func (r *ReaderRepository) GetResortByID(ctx context.Context, id string) (*models.Resort, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	// SAFETY: whereClause is hardcoded, not user-supplied
@@ -95,9 +110,10 @@ func (r *ReaderRepository) GetResortByID(ctx context.Context, id string) (*model
 }
 
 // GetSnowiestResorts queries snowiest resorts for a date range with optional prefecture filter.
-// If endDate is empty, it defaults to startDate + 6 days (week mode).
+// If endDate is empty, it defaults to startDate + 6 days (week mode). Resorts
+// with the same average snowfall are ordered by ID.
 func (r *ReaderRepository) GetSnowiestResorts(ctx context.Context, startDate, endDate, prefecture string, limit int) ([]models.WeeklyResortStats, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	if limit <= 0 {
@@ -142,13 +158,15 @@ func (r *ReaderRepository) GetSnowiestResorts(ctx context.Context, startDate, en
 	}
 
 	var dateFilter string
-	groupYearExpr := "CAST(strftime('%Y', substr(date, 1, 19)) AS INTEGER)"
+	d := r.db.dialect
+	groupYearExpr := d.Year("date")
+	doyExpr := d.DayOfYear("date")
 	if startDOY <= endDOY {
-		dateFilter = "CAST(strftime('%j', substr(date, 1, 19)) AS INTEGER) >= ? AND CAST(strftime('%j', substr(date, 1, 19)) AS INTEGER) <= ?"
+		dateFilter = fmt.Sprintf("%s >= ? AND %s <= ?", doyExpr, doyExpr)
 	} else {
 		// Cross-year boundary (e.g., Dec 15 to Jan 15)
-		dateFilter = "(CAST(strftime('%j', substr(date, 1, 19)) AS INTEGER) >= ? OR CAST(strftime('%j', substr(date, 1, 19)) AS INTEGER) <= ?)"
-		groupYearExpr = fmt.Sprintf("CASE WHEN CAST(strftime('%%m', substr(date, 1, 19)) AS INTEGER) >= %d THEN CAST(strftime('%%Y', substr(date, 1, 19)) AS INTEGER) ELSE CAST(strftime('%%Y', substr(date, 1, 19)) AS INTEGER) - 1 END", startMonth)
+		dateFilter = fmt.Sprintf("(%s >= ? OR %s <= ?)", doyExpr, doyExpr)
+		groupYearExpr = seasonYearExpr(d, startMonth)
 	}
 
 	args := []any{startDOY, endDOY}
@@ -197,7 +215,7 @@ func (r *ReaderRepository) GetSnowiestResorts(ctx context.Context, startDate, en
 		JOIN resorts r ON r.id = ard.resort_id
 		WHERE ard.years_with_data >= 1
 		%s
-		ORDER BY ard.avg_snowfall DESC
+		ORDER BY ard.avg_snowfall DESC, r.id
 		LIMIT ?
 	`, groupYearExpr, dateFilter, prefectureClause)
 
@@ -210,6 +228,14 @@ func (r *ReaderRepository) GetSnowiestResorts(ctx context.Context, startDate, en
 	return scanWeeklyResortStats(rows)
 }
 
+// seasonYearExpr returns a SQL expression grouping daily_snowfall.date into
+// seasons that start in startMonth: dates in or after startMonth belong to that
+// year's season, earlier dates to the previous year's.
+func seasonYearExpr(d dialect.Dialect, startMonth int) string {
+	year := d.Year("date")
+	return fmt.Sprintf("CASE WHEN %s >= %d THEN %s ELSE %s - 1 END", d.Month("date"), startMonth, year, year)
+}
+
 // scanWeeklyResortStats scans sql rows into a slice of WeeklyResortStats.
 func scanWeeklyResortStats(rows *sql.Rows) ([]models.WeeklyResortStats, error) {
 	results := []models.WeeklyResortStats{}
@@ -227,11 +253,94 @@ func scanWeeklyResortStats(rows *sql.Rows) ([]models.WeeklyResortStats, error) {
 	return results, nil
 }
 
+// GetAllResorts returns every resort ordered by prefecture and name.
+func (r *ReaderRepository) GetAllResorts(ctx context.Context) ([]models.Resort, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + resortColumns + `
+		FROM resorts
+		ORDER BY prefecture, name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query resorts: %w", err)
+	}
+	defer rows.Close()
+
+	resorts := []models.Resort{}
+	for rows.Next() {
+		resort, err := scanResort(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan resort: %w", err)
+		}
+		resorts = append(resorts, resort)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return resorts, nil
+}
+
+// GetObservedSnowfall returns observed daily snowfall between the from and to
+// dates (inclusive, "YYYY-MM-DD"), keyed by resort ID and then by date.
+func (r *ReaderRepository) GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	return queryObservedSnowfall(ctx, r.db, from, to)
+}
+
+// queryObservedSnowfall is the shared implementation behind GetObservedSnowfall.
+func queryObservedSnowfall(ctx context.Context, db *database, from, to string) (map[string]map[string]int, error) {
+	if _, err := time.Parse("2006-01-02", from); err != nil {
+		return nil, fmt.Errorf("parse from date: %w", err)
+	}
+	if _, err := time.Parse("2006-01-02", to); err != nil {
+		return nil, fmt.Errorf("parse to date: %w", err)
+	}
+
+	// SAFETY: the date expression is hardcoded, not user-supplied
+	dateExpr := db.dialect.DateString("date")
+	query := fmt.Sprintf(`
+		SELECT resort_id, %s, snowfall_cm
+		FROM daily_snowfall
+		WHERE %s >= ? AND %s <= ?
+	`, dateExpr, dateExpr, dateExpr)
+
+	rows, err := db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query observed snowfall: %w", err)
+	}
+	defer rows.Close()
+
+	observed := make(map[string]map[string]int)
+	for rows.Next() {
+		var resortID, date string
+		var snowfallCM int
+		if err := rows.Scan(&resortID, &date, &snowfallCM); err != nil {
+			return nil, fmt.Errorf("scan observed snowfall: %w", err)
+		}
+		if observed[resortID] == nil {
+			observed[resortID] = make(map[string]int)
+		}
+		observed[resortID][date] = snowfallCM
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate observed snowfall rows: %w", err)
+	}
+
+	return observed, nil
+}
+
 // GetAllResortsWithPeaks returns all resorts that have at least one peak period,
 // with their associated peak periods pre-loaded. Results are ordered by prefecture,
 // resort name, and peak rank.
 func (r *ReaderRepository) GetAllResortsWithPeaks(ctx context.Context) ([]models.ResortWithPeaks, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	// Single JOIN query to fetch resorts and their peaks together
@@ -239,7 +348,7 @@ func (r *ReaderRepository) GetAllResortsWithPeaks(ctx context.Context) ([]models
 		SELECT r.id, r.slug, r.name, r.prefecture, r.region,
 			   r.top_elevation_m, r.base_elevation_m, r.vertical_m,
 			   r.num_courses, r.longest_course_km, r.steepest_course_deg,
-			   r.last_updated,
+			   r.latitude, r.longitude, r.last_updated,
 			   p.id, p.peak_rank, p.start_doy, p.end_doy, p.center_doy,
 			   p.avg_daily_snowfall, p.total_period_snowfall, p.prominence_score,
 			   p.years_of_data, p.confidence_level, p.reliability_score,
@@ -267,7 +376,7 @@ func (r *ReaderRepository) GetAllResortsWithPeaks(ctx context.Context) ([]models
 			&resort.ID, &resort.Slug, &resort.Name, &resort.Prefecture, &resort.Region,
 			&resort.TopElevationM, &resort.BaseElevationM, &resort.VerticalM,
 			&resort.NumCourses, &resort.LongestCourseKM, &resort.SteepestCourseDeg,
-			&resort.LastUpdated,
+			&resort.Latitude, &resort.Longitude, &resort.LastUpdated,
 			&peak.ID, &peak.PeakRank, &startDOY, &endDOY, &centerDOY,
 			&peak.AvgDailySnowfall, &peak.TotalPeriodSnowfall, &peak.ProminenceScore,
 			&peak.YearsOfData, &peak.ConfidenceLevel, &peak.ReliabilityScore,
@@ -313,20 +422,65 @@ func (r *ReaderRepository) GetAllResortsWithPeaks(ctx context.Context) ([]models
 	return results, nil
 }
 
-// GetPendingFailedScrapeAttempts returns all failed scrape attempts that have not
-// yet been retried, ordered by failure time ascending.
+// GetPendingFailedScrapeAttempts returns the failed scrape attempts that are
+// due for a retry: not yet retried, not dead-lettered and with a next retry
+// time that has passed. They are ordered by next retry time ascending.
 func (r *ReaderRepository) GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + failedScrapeAttemptColumns + `
+		FROM failed_scrape_attempts
+		WHERE retried = FALSE AND dead_lettered = FALSE AND next_retry_at <= ?
+		ORDER BY next_retry_at ASC, failed_at ASC
+	`
+	return r.queryFailedScrapeAttempts(ctx, query, time.Now().UTC().Format(time.RFC3339))
+}
+
+// GetOpenFailedScrapeAttempt returns the open failed scrape attempt for
+// resortURL: the one neither retried nor dead-lettered, whether or not its
+// retry is due yet. A URL has at most one open attempt.
+// Returns sql.ErrNoRows (wrapped) if the URL has no open attempt.
+func (r *ReaderRepository) GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error) {
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	query := `
-		SELECT id, resort_url, error_message, failed_at, retried, retried_at
+		SELECT ` + failedScrapeAttemptColumns + `
 		FROM failed_scrape_attempts
-		WHERE retried = FALSE
+		WHERE resort_url = ? AND retried = FALSE AND dead_lettered = FALSE
+	`
+	attempts, err := r.queryFailedScrapeAttempts(ctx, query, resortURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return nil, fmt.Errorf("get open failed scrape attempt for %s: %w", resortURL, sql.ErrNoRows)
+	}
+	return &attempts[0], nil
+}
+
+// GetDeadLetteredScrapeAttempts returns the failed scrape attempts whose
+// retries were given up on, ordered by failure time ascending.
+func (r *ReaderRepository) GetDeadLetteredScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + failedScrapeAttemptColumns + `
+		FROM failed_scrape_attempts
+		WHERE dead_lettered = TRUE
 		ORDER BY failed_at ASC
 	`
+	return r.queryFailedScrapeAttempts(ctx, query)
+}
 
-	rows, err := r.db.QueryContext(ctx, query)
+const failedScrapeAttemptColumns = `id, resort_url, error_message, error_class, attempts, failed_at,
+	next_retry_at, dead_lettered, retried, retried_at`
+
+func (r *ReaderRepository) queryFailedScrapeAttempts(ctx context.Context, query string, args ...any) ([]models.FailedScrapeAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed scrape attempts: %w", err)
 	}
@@ -335,7 +489,8 @@ func (r *ReaderRepository) GetPendingFailedScrapeAttempts(ctx context.Context) (
 	var attempts []models.FailedScrapeAttempt
 	for rows.Next() {
 		var a models.FailedScrapeAttempt
-		if err := rows.Scan(&a.ID, &a.ResortURL, &a.ErrorMessage, &a.FailedAt, &a.Retried, &a.RetriedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.ResortURL, &a.ErrorMessage, &a.ErrorClass, &a.Attempts, &a.FailedAt,
+			&a.NextRetryAt, &a.DeadLettered, &a.Retried, &a.RetriedAt); err != nil {
 			return nil, fmt.Errorf("scan failed scrape attempt: %w", err)
 		}
 		attempts = append(attempts, a)
@@ -350,7 +505,7 @@ func (r *ReaderRepository) GetPendingFailedScrapeAttempts(ctx context.Context) (
 // GetPeakPeriodsForResort returns all peak periods for the given resort,
 // ordered by peak rank ascending.
 func (r *ReaderRepository) GetPeakPeriodsForResort(ctx context.Context, resortID string) ([]models.PeakPeriod, error) {
-	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	ctx, cancel := r.db.readContext(ctx)
 	defer cancel()
 
 	query := `
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/internal/regions"
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// RegionGrouping selects how region summaries and forecasts group resorts.
+type RegionGrouping int
+
+const (
+	// GroupByPrefecture groups resorts by prefecture.
+	GroupByPrefecture RegionGrouping = iota
+	// GroupByRegion groups resorts by region within each prefecture.
+	GroupByRegion
+)
+
+func (g RegionGrouping) validate() error {
+	if g != GroupByPrefecture && g != GroupByRegion {
+		return fmt.Errorf("unknown region grouping: %d", g)
+	}
+	return nil
+}
+
+// key returns the group of a resort in prefecture and region.
+func (g RegionGrouping) key(prefecture, region string) regions.Key {
+	return regions.NewKey(prefecture, region, g == GroupByRegion)
+}
+
+// GetRegionSummaries returns the snowfall recorded between from and to
+// (inclusive, compared by calendar date) per prefecture or region, ordered by
+// prefecture and region. Every resort is counted, so regions without any data
+// in the range are still returned.
+func (r *ReaderRepository) GetRegionSummaries(ctx context.Context, from, to time.Time, groupBy RegionGrouping) ([]models.RegionSummary, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	if err := groupBy.validate(); err != nil {
+		return nil, err
+	}
+
+	// SAFETY: the date expression is hardcoded, not user-supplied
+	query := fmt.Sprintf(`
+		SELECT r.id, r.name, r.prefecture, r.region, t.total
+		FROM resorts r
+		LEFT JOIN (
+			SELECT resort_id, SUM(snowfall_cm) AS total
+			FROM daily_snowfall
+			WHERE %[1]s >= ? AND %[1]s <= ?
+			GROUP BY resort_id
+		) t ON t.resort_id = r.id
+	`, r.db.dialect.DateString("date"))
+
+	rows, err := r.db.QueryContext(ctx, query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("query region snowfall: %w", err)
+	}
+	defer rows.Close()
+
+	var resorts []models.Resort
+	totals := make(map[string]int)
+	for rows.Next() {
+		var resort models.Resort
+		var total sql.NullInt64
+		if err := rows.Scan(&resort.ID, &resort.Name, &resort.Prefecture, &resort.Region, &total); err != nil {
+			return nil, fmt.Errorf("scan region snowfall: %w", err)
+		}
+		resorts = append(resorts, resort)
+		if total.Valid {
+			totals[resort.ID] = int(total.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return regions.Summarize(resorts, totals, groupBy == GroupByRegion), nil
+}
+
+// GetRegionForecasts rolls up the stored predictions per prefecture or region
+// over the days calendar days starting at from, ordered by prefecture and
+// region. Resorts are placed by their resorts row, falling back to the
+// prefecture recorded in the prediction. Regions without any forecast in the
+// window are omitted.
+func (r *PredictionRepository) GetRegionForecasts(ctx context.Context, from time.Time, days int, groupBy RegionGrouping) ([]models.RegionForecast, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if days <= 0 {
+		return nil, fmt.Errorf("days must be positive: %d", days)
+	}
+	if err := groupBy.validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT p.resort_id, p.prediction_data, r.name, r.prefecture, r.region
+		FROM predictions p
+		LEFT JOIN resorts r ON r.id = p.resort_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query predictions: %w", err)
+	}
+	defer rows.Close()
+
+	first := from.Format("2006-01-02")
+	last := from.AddDate(0, 0, days-1).Format("2006-01-02")
+
+	groups := make(map[regions.Key]*regionForecast)
+	for rows.Next() {
+		var resortID string
+		var predData []byte
+		var name, prefecture, region sql.NullString
+		if err := rows.Scan(&resortID, &predData, &name, &prefecture, &region); err != nil {
+			return nil, fmt.Errorf("scan prediction: %w", err)
+		}
+		var pred models.Prediction
+		if err := json.Unmarshal(predData, &pred); err != nil {
+			return nil, fmt.Errorf("unmarshal prediction for %s: %w", resortID, err)
+		}
+		if !name.Valid {
+			name.String = pred.Name
+		}
+		if !prefecture.Valid {
+			prefecture.String = pred.Prefecture
+		}
+
+		key := groupBy.key(prefecture.String, region.String)
+		group := groups[key]
+		if group == nil {
+			group = &regionForecast{
+				RegionForecast: models.RegionForecast{Prefecture: key.Prefecture, Region: key.Region},
+				days:           make(map[string]*models.RegionForecastDay),
+			}
+		}
+		if group.add(resortID, name.String, pred.Daily, first, last) {
+			groups[key] = group
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prediction rows: %w", err)
+	}
+
+	forecasts := []models.RegionForecast{}
+	for _, key := range regions.SortedKeys(groups) {
+		forecasts = append(forecasts, groups[key].finish())
+	}
+	return forecasts, nil
+}
+
+// regionForecast accumulates a RegionForecast, keyed by date until finish.
+type regionForecast struct {
+	models.RegionForecast
+	days map[string]*models.RegionForecastDay
+}
+
+// add accumulates a resort's forecast days between first and last
+// ("YYYY-MM-DD", inclusive) and reports whether any fell in that window.
+func (f *regionForecast) add(resortID, name string, daily []models.DailyForecast, first, last string) bool {
+	var total float64
+	found := false
+	for _, d := range daily {
+		if d.Date < first || d.Date > last {
+			continue
+		}
+		found = true
+		total += d.SnowfallCM
+
+		day := f.days[d.Date]
+		if day == nil {
+			day = &models.RegionForecastDay{Date: d.Date, MaxSnowfallCM: d.SnowfallCM}
+			f.days[d.Date] = day
+		}
+		day.Resorts++
+		day.TotalSnowfallCM += d.SnowfallCM
+		day.MaxSnowfallCM = max(day.MaxSnowfallCM, d.SnowfallCM)
+	}
+	if !found {
+		return false
+	}
+
+	f.ResortCount++
+	f.TotalSnowfallCM += total
+	if regions.BetterTopResort(f.TopResort, resortID, name, total) {
+		f.TopResort = &models.RegionTopResort{ResortID: resortID, Name: name, SnowfallCM: total}
+	}
+	return true
+}
+
+// finish computes the averages and returns the forecast with days in date order.
+func (f *regionForecast) finish() models.RegionForecast {
+	forecast := f.RegionForecast
+	forecast.AvgSnowfallCM = forecast.TotalSnowfallCM / float64(forecast.ResortCount)
+	forecast.Daily = make([]models.RegionForecastDay, 0, len(f.days))
+	for _, day := range f.days {
+		day.AvgSnowfallCM = day.TotalSnowfallCM / float64(day.Resorts)
+		forecast.Daily = append(forecast.Daily, *day)
+	}
+	sort.Slice(forecast.Daily, func(i, j int) bool { return forecast.Daily[i].Date < forecast.Daily[j].Date })
+	return forecast
+}
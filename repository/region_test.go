@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func TestPredictionRepositoryGetRegionForecasts_RollsUpWindow(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	writer := NewWriter(db)
+	repo := NewPredictionRepository(db)
+	ctx := context.Background()
+
+	resorts := map[string]*models.Resort{
+		"happo":  {Slug: "happo", Name: "Happo", Prefecture: "nagano", Region: "hakuba"},
+		"goryu":  {Slug: "goryu", Name: "Goryu", Prefecture: "nagano", Region: "hakuba"},
+		"niseko": {Slug: "niseko", Name: "Niseko", Prefecture: "hokkaido", Region: "niseko"},
+	}
+	for _, resort := range resorts {
+		if err := writer.SaveResort(ctx, resort); err != nil {
+			t.Fatalf("SaveResort() error = %v", err)
+		}
+	}
+
+	daily := func(days map[string]float64) []models.DailyForecast {
+		var out []models.DailyForecast
+		for date, cm := range days {
+			out = append(out, models.DailyForecast{Date: date, SnowfallCM: cm})
+		}
+		return out
+	}
+	data := &models.PredictionData{
+		GeneratedAt: "2025-01-10T06:00:00Z",
+		Resorts: map[string]models.Prediction{
+			resorts["happo"].ID: {Name: "Happo", Daily: daily(map[string]float64{
+				"2025-01-09": 50, "2025-01-10": 10, "2025-01-11": 20, "2025-01-13": 99,
+			})},
+			resorts["goryu"].ID: {Name: "Goryu", Daily: daily(map[string]float64{"2025-01-10": 30})},
+			// Only forecasts outside the window, so hokkaido is omitted.
+			resorts["niseko"].ID: {Name: "Niseko", Daily: daily(map[string]float64{"2025-01-20": 40})},
+			// Not in the resorts table: placed by its own prefecture.
+			"orphan": {Name: "Orphan", Prefecture: "niigata", Daily: daily(map[string]float64{"2025-01-12": 5})},
+		},
+	}
+	if err := repo.SavePredictions(ctx, data); err != nil {
+		t.Fatalf("SavePredictions() error = %v", err)
+	}
+
+	format := func(forecasts []models.RegionForecast) string {
+		parts := make([]string, len(forecasts))
+		for i, f := range forecasts {
+			days := make([]string, len(f.Daily))
+			for j, d := range f.Daily {
+				days[j] = fmt.Sprintf("%s:%d/%g/%g/%g", d.Date, d.Resorts, d.TotalSnowfallCM, d.AvgSnowfallCM, d.MaxSnowfallCM)
+			}
+			parts[i] = fmt.Sprintf("%s/%s=%d:%g/%g:%s:%g[%s]", f.Prefecture, f.Region, f.ResortCount,
+				f.TotalSnowfallCM, f.AvgSnowfallCM, f.TopResort.Name, f.TopResort.SnowfallCM, strings.Join(days, " "))
+		}
+		return strings.Join(parts, " ")
+	}
+
+	from := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	byPrefecture, err := repo.GetRegionForecasts(ctx, from, 3, GroupByPrefecture)
+	if err != nil {
+		t.Fatalf("GetRegionForecasts() error = %v", err)
+	}
+	// Happo and Goryu tie on 30 cm, so the top resort is chosen by name.
+	want := "nagano/=2:60/30:Goryu:30[2025-01-10:2/40/20/30 2025-01-11:1/20/20/20] " +
+		"niigata/=1:5/5:Orphan:5[2025-01-12:1/5/5/5]"
+	if got := format(byPrefecture); got != want {
+		t.Fatalf("GetRegionForecasts(prefecture) = %s, want %s", got, want)
+	}
+
+	byRegion, err := repo.GetRegionForecasts(ctx, from, 3, GroupByRegion)
+	if err != nil {
+		t.Fatalf("GetRegionForecasts() error = %v", err)
+	}
+	want = "nagano/hakuba=2:60/30:Goryu:30[2025-01-10:2/40/20/30 2025-01-11:1/20/20/20] " +
+		"niigata/=1:5/5:Orphan:5[2025-01-12:1/5/5/5]"
+	if got := format(byRegion); got != want {
+		t.Fatalf("GetRegionForecasts(region) = %s, want %s", got, want)
+	}
+
+	if _, err := repo.GetRegionForecasts(ctx, from, 0, GroupByPrefecture); err == nil {
+		t.Fatal("GetRegionForecasts(0 days) expected error")
+	}
+	if _, err := repo.GetRegionForecasts(ctx, from, 3, RegionGrouping(99)); err == nil {
+		t.Fatal("GetRegionForecasts() with unknown grouping expected error")
+	}
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/amaumene/snowfinder_common/internal/resortsearch"
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// ErrInvalidResortQuery is returned (wrapped) by SearchResorts for malformed
+// queries, including cursors that do not belong to the query's sort order.
+var ErrInvalidResortQuery = resortsearch.ErrInvalidQuery
+
+// Page sizes for SearchResorts.
+const (
+	DefaultResortPageSize = resortsearch.DefaultPageSize
+	MaxResortPageSize     = resortsearch.MaxPageSize
+)
+
+// ResortSort selects the order of SearchResorts results. Resorts with an
+// unknown value for the sort column are always listed last.
+type ResortSort = resortsearch.Sort
+
+const (
+	SortByName           = resortsearch.SortByName
+	SortByTopElevation   = resortsearch.SortByTopElevation
+	SortByBaseElevation  = resortsearch.SortByBaseElevation
+	SortByVertical       = resortsearch.SortByVertical
+	SortByNumCourses     = resortsearch.SortByNumCourses
+	SortByLongestCourse  = resortsearch.SortByLongestCourse
+	SortBySteepestCourse = resortsearch.SortBySteepestCourse
+)
+
+// ParseResortSort returns the ResortSort named name. The empty string selects
+// SortByName.
+func ParseResortSort(name string) (ResortSort, error) {
+	return resortsearch.ParseSort(name)
+}
+
+// IntRange is an inclusive range; a zero bound is unset.
+type IntRange = resortsearch.IntRange
+
+// FloatRange is an inclusive range; a zero bound is unset.
+type FloatRange = resortsearch.FloatRange
+
+// ResortQuery filters, orders and pages SearchResorts. Zero fields do not
+// filter. Range filters exclude resorts whose value is unknown.
+type ResortQuery = resortsearch.Query
+
+// ResortPage is one page of SearchResorts results.
+type ResortPage = resortsearch.Page
+
+// ResortNameMatches reports whether name contains query, ignoring case,
+// full/half-width forms, spacing and punctuation, and the difference between
+// kana and romaji spellings. For example "Hakuba Happo-One" matches "happou",
+// "ハッポウ" and "はっぽー". Kanji are matched literally.
+func ResortNameMatches(name, query string) bool {
+	return resortsearch.NameMatches(name, query)
+}
+
+// SearchResorts returns one page of resorts matching q. Structured filters,
+// ordering and the cursor are evaluated in SQL; the kana/romaji-insensitive
+// name match is applied to the ordered rows in Go.
+// Returns an error wrapping ErrInvalidResortQuery for malformed queries.
+func (r *ReaderRepository) SearchResorts(ctx context.Context, q ResortQuery) (ResortPage, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	q, key, cursor, err := q.Prepare()
+	if err != nil {
+		return ResortPage{}, fmt.Errorf("search resorts: %w", err)
+	}
+
+	var where []string
+	var args []any
+	if q.Prefecture != "" {
+		where = append(where, "prefecture = ?")
+		args = append(args, q.Prefecture)
+	}
+	if q.Region != "" {
+		where = append(where, "region = ?")
+		args = append(args, q.Region)
+	}
+	for _, f := range []struct {
+		column string
+		rng    IntRange
+	}{
+		{"top_elevation_m", q.TopElevationM},
+		{"base_elevation_m", q.BaseElevationM},
+		{"vertical_m", q.VerticalM},
+		{"num_courses", q.NumCourses},
+	} {
+		if f.rng.Min != 0 {
+			where = append(where, f.column+" >= ?")
+			args = append(args, f.rng.Min)
+		}
+		if f.rng.Max != 0 {
+			where = append(where, f.column+" <= ?")
+			args = append(args, f.rng.Max)
+		}
+	}
+	if q.SteepestCourseDeg.Min != 0 {
+		where = append(where, "steepest_course_deg >= ?")
+		args = append(args, q.SteepestCourseDeg.Min)
+	}
+	if q.SteepestCourseDeg.Max != 0 {
+		where = append(where, "steepest_course_deg <= ?")
+		args = append(args, q.SteepestCourseDeg.Max)
+	}
+
+	direction, after := "ASC", ">"
+	if q.Descending {
+		direction, after = "DESC", "<"
+	}
+	// SAFETY: column names and the null sentinel come from the resortsearch
+	// sort keys and constants, never from the caller.
+	sortExpr := "name"
+	orderBy := "name " + direction + ", id"
+	if key.Value != nil {
+		null := resortsearch.NullSortValue
+		if q.Descending {
+			null = -resortsearch.NullSortValue
+		}
+		sortExpr = fmt.Sprintf("COALESCE(%s, %d)", key.Column, null)
+		orderBy = sortExpr + " " + direction + ", name, id"
+	}
+	if cursor != nil {
+		if key.Value == nil {
+			where = append(where, fmt.Sprintf("(name %s ? OR (name = ? AND id > ?))", after))
+			args = append(args, cursor.Name, cursor.Name, cursor.ID)
+		} else {
+			var k any = cursor.Key
+			if key.Integer {
+				k = int64(cursor.Key)
+			}
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND (name > ? OR (name = ? AND id > ?))))", sortExpr, after))
+			args = append(args, k, k, cursor.Name, cursor.Name, cursor.ID)
+		}
+	}
+
+	query := `
+		SELECT ` + resortColumns + `
+		FROM resorts`
+	if len(where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	query += "\n\t\tORDER BY " + orderBy
+	// Without a name filter every row is a match, so SQL can stop at the
+	// first row of the next page.
+	if strings.TrimSpace(q.Name) == "" {
+		query += "\n\t\tLIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ResortPage{}, fmt.Errorf("search resorts: %w", err)
+	}
+	defer rows.Close()
+
+	var resorts []models.Resort
+	for len(resorts) <= q.Limit && rows.Next() {
+		resort, err := scanResort(rows)
+		if err != nil {
+			return ResortPage{}, fmt.Errorf("scan resort: %w", err)
+		}
+		if ResortNameMatches(resort.Name, q.Name) {
+			resorts = append(resorts, resort)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ResortPage{}, fmt.Errorf("iterate rows: %w", err)
+	}
+	return q.Page(key, resorts), nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// seasonStartMonth is the month a snow season starts in; a season runs from
+// July 1 to June 30 so that every winter falls inside a single season.
+const seasonStartMonth = time.July
+
+// seasonStart returns the start of the season containing t.
+func seasonStart(t time.Time) time.Time {
+	year := t.Year()
+	if t.Month() < seasonStartMonth {
+		year--
+	}
+	return time.Date(year, seasonStartMonth, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// GetSnowDepthHistory returns a resort's snow depth readings between from and to
+// (inclusive, compared by calendar date), ordered by date ascending.
+func (r *ReaderRepository) GetSnowDepthHistory(ctx context.Context, resortID string, from, to time.Time) ([]models.SnowDepthReading, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	// SAFETY: the date expression is hardcoded, not user-supplied
+	query := fmt.Sprintf(`
+		SELECT resort_id, date, depth_cm
+		FROM snow_depth_readings
+		WHERE resort_id = ?
+		  AND %[1]s >= ? AND %[1]s <= ?
+		ORDER BY date
+	`, r.db.dialect.DateString("date"))
+
+	rows, err := r.db.QueryContext(ctx, query, resortID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("query snow depth history: %w", err)
+	}
+	defer rows.Close()
+
+	return scanSnowDepthReadings(rows)
+}
+
+// GetLatestSnowDepth returns the most recent snow depth reading for each of the
+// given resorts, keyed by resort ID. Resorts without readings are omitted.
+func (r *ReaderRepository) GetLatestSnowDepth(ctx context.Context, resortIDs []string) (map[string]models.SnowDepthReading, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	latest := make(map[string]models.SnowDepthReading, len(resortIDs))
+	for start := 0; start < len(resortIDs); start += r.db.batchChunkSize {
+		end := min(start+r.db.batchChunkSize, len(resortIDs))
+		args := stringArgs(resortIDs[start:end])
+
+		// SAFETY: only placeholders are interpolated, values are bound
+		query := fmt.Sprintf(`
+			SELECT s.resort_id, s.date, s.depth_cm
+			FROM snow_depth_readings s
+			WHERE s.resort_id IN (%s)
+			  AND s.date = (
+				SELECT MAX(date) FROM snow_depth_readings WHERE resort_id = s.resort_id
+			  )
+		`, placeholders(len(args)))
+
+		if err := r.queryReadingsByResort(ctx, query, args, latest); err != nil {
+			return nil, fmt.Errorf("query latest snow depth: %w", err)
+		}
+	}
+
+	return latest, nil
+}
+
+// GetSeasonMaxSnowDepth returns, for each of the given resorts, the deepest
+// reading from the start of the season containing asOf up to asOf (inclusive),
+// keyed by resort ID. Ties go to the most recent reading. Resorts without
+// readings in the season are omitted.
+func (r *ReaderRepository) GetSeasonMaxSnowDepth(ctx context.Context, resortIDs []string, asOf time.Time) (map[string]models.SnowDepthReading, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	from := seasonStart(asOf).Format("2006-01-02")
+	to := asOf.Format("2006-01-02")
+
+	maxDepths := make(map[string]models.SnowDepthReading, len(resortIDs))
+	for start := 0; start < len(resortIDs); start += r.db.batchChunkSize {
+		end := min(start+r.db.batchChunkSize, len(resortIDs))
+		args := stringArgs(resortIDs[start:end])
+
+		// SAFETY: only placeholders and the hardcoded date expression are
+		// interpolated, values are bound
+		query := fmt.Sprintf(`
+			SELECT resort_id, date, depth_cm
+			FROM (
+				SELECT resort_id, date, depth_cm,
+					   ROW_NUMBER() OVER (PARTITION BY resort_id ORDER BY depth_cm DESC, date DESC) AS rn
+				FROM snow_depth_readings
+				WHERE resort_id IN (%[1]s)
+				  AND %[2]s >= ? AND %[2]s <= ?
+			) ranked
+			WHERE rn = 1
+		`, placeholders(len(args)), r.db.dialect.DateString("date"))
+		args = append(args, from, to)
+
+		if err := r.queryReadingsByResort(ctx, query, args, maxDepths); err != nil {
+			return nil, fmt.Errorf("query season max snow depth: %w", err)
+		}
+	}
+
+	return maxDepths, nil
+}
+
+// queryReadingsByResort runs a query selecting (resort_id, date, depth_cm) with at
+// most one row per resort and stores each row in dst keyed by resort ID.
+func (r *ReaderRepository) queryReadingsByResort(ctx context.Context, query string, args []any, dst map[string]models.SnowDepthReading) error {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	readings, err := scanSnowDepthReadings(rows)
+	if err != nil {
+		return err
+	}
+	for _, reading := range readings {
+		dst[reading.ResortID] = reading
+	}
+	return nil
+}
+
+// scanSnowDepthReadings scans sql rows into a slice of SnowDepthReading.
+func scanSnowDepthReadings(rows *sql.Rows) ([]models.SnowDepthReading, error) {
+	readings := []models.SnowDepthReading{}
+	for rows.Next() {
+		var reading models.SnowDepthReading
+		if err := rows.Scan(&reading.ResortID, &reading.Date, &reading.DepthCM); err != nil {
+			return nil, fmt.Errorf("scan snow depth reading: %w", err)
+		}
+		readings = append(readings, reading)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return readings, nil
+}
+
+// stringArgs converts string values to bind arguments.
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func seedSnowDepth(t *testing.T, writer *WriterRepository) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	a := &models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"}
+	b := &models.Resort{Slug: "b", Name: "B", Prefecture: "nagano"}
+	for _, r := range []*models.Resort{a, b} {
+		if err := writer.SaveResort(ctx, r); err != nil {
+			t.Fatalf("SaveResort() error = %v", err)
+		}
+	}
+
+	readings := []models.SnowDepthReading{
+		{ResortID: a.ID, Date: day(2024, time.March, 1), DepthCM: 300},
+		{ResortID: a.ID, Date: day(2024, time.December, 20), DepthCM: 80},
+		{ResortID: a.ID, Date: day(2025, time.January, 10), DepthCM: 150},
+		{ResortID: a.ID, Date: day(2025, time.January, 20), DepthCM: 150},
+		{ResortID: a.ID, Date: day(2025, time.February, 1), DepthCM: 120},
+		{ResortID: b.ID, Date: day(2025, time.January, 5), DepthCM: 60},
+	}
+	if err := writer.SaveSnowDepthReadings(ctx, readings); err != nil {
+		t.Fatalf("SaveSnowDepthReadings() error = %v", err)
+	}
+	return a.ID, b.ID
+}
+
+func TestReaderRepositoryGetSnowDepthHistory_FiltersAndOrders(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	a, _ := seedSnowDepth(t, writer)
+
+	got, err := writer.GetSnowDepthHistory(context.Background(), a, day(2024, time.December, 1), day(2025, time.January, 20))
+	if err != nil {
+		t.Fatalf("GetSnowDepthHistory() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("GetSnowDepthHistory() returned %d readings, want 3: %+v", len(got), got)
+	}
+	if !got[0].Date.Equal(day(2024, time.December, 20)) || !got[2].Date.Equal(day(2025, time.January, 20)) {
+		t.Fatalf("GetSnowDepthHistory() dates = %s..%s", got[0].Date, got[2].Date)
+	}
+
+	if _, err := writer.GetSnowDepthHistory(context.Background(), a, day(2025, 1, 2), day(2025, 1, 1)); err == nil {
+		t.Fatal("expected error when to is before from")
+	}
+}
+
+func TestReaderRepositoryGetLatestSnowDepth(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	a, b := seedSnowDepth(t, writer)
+
+	got, err := writer.GetLatestSnowDepth(context.Background(), []string{a, b, "missing"})
+	if err != nil {
+		t.Fatalf("GetLatestSnowDepth() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("GetLatestSnowDepth() returned %d resorts, want 2", len(got))
+	}
+	if got[a].DepthCM != 120 || !got[a].Date.Equal(day(2025, time.February, 1)) {
+		t.Fatalf("latest for a = %+v", got[a])
+	}
+	if got[b].DepthCM != 60 {
+		t.Fatalf("latest for b = %+v", got[b])
+	}
+}
+
+func TestReaderRepositoryGetSeasonMaxSnowDepth_ScopesToSeason(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	a, b := seedSnowDepth(t, writer)
+
+	got, err := writer.GetSeasonMaxSnowDepth(context.Background(), []string{a, b}, day(2025, time.January, 15))
+	if err != nil {
+		t.Fatalf("GetSeasonMaxSnowDepth() error = %v", err)
+	}
+	// The 300 cm reading belongs to the previous season; the Jan 20 tie is after asOf.
+	if got[a].DepthCM != 150 || !got[a].Date.Equal(day(2025, time.January, 10)) {
+		t.Fatalf("season max for a = %+v", got[a])
+	}
+	if got[b].DepthCM != 60 {
+		t.Fatalf("season max for b = %+v", got[b])
+	}
+
+	got, err = writer.GetSeasonMaxSnowDepth(context.Background(), []string{a}, day(2025, time.March, 1))
+	if err != nil {
+		t.Fatalf("GetSeasonMaxSnowDepth() error = %v", err)
+	}
+	if !got[a].Date.Equal(day(2025, time.January, 20)) {
+		t.Fatalf("tie should go to most recent reading, got %+v", got[a])
+	}
+}
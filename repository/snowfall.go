@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// GetDailySnowfall returns a resort's daily snowfall between from and to
+// (inclusive, compared by calendar date), ordered by date ascending.
+func (r *ReaderRepository) GetDailySnowfall(ctx context.Context, resortID string, from, to time.Time) ([]models.DailySnowfall, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	// SAFETY: the date expression is hardcoded, not user-supplied
+	query := fmt.Sprintf(`
+		SELECT resort_id, date, snowfall_cm
+		FROM daily_snowfall
+		WHERE resort_id = ?
+		  AND %[1]s >= ? AND %[1]s <= ?
+		ORDER BY date
+	`, r.db.dialect.DateString("date"))
+
+	rows, err := r.db.QueryContext(ctx, query, resortID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("query daily snowfall: %w", err)
+	}
+	defer rows.Close()
+
+	snowfalls := []models.DailySnowfall{}
+	for rows.Next() {
+		var sf models.DailySnowfall
+		if err := rows.Scan(&sf.ResortID, &sf.Date, &sf.SnowfallCM); err != nil {
+			return nil, fmt.Errorf("scan daily snowfall: %w", err)
+		}
+		snowfalls = append(snowfalls, sf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return snowfalls, nil
+}
+
+// GetSeasonSnowfallTotals returns a resort's total snowfall for its most recent
+// seasons (newest first, at most seasons entries), ranked against each other.
+// Seasons run July to June and are labelled by winter, e.g. "2024-25".
+//
+// If throughMMDD is non-empty ("MM-DD"), each season only counts days from its
+// start up to and including that calendar day, so the current season can be
+// compared to the same point in previous seasons.
+func (r *ReaderRepository) GetSeasonSnowfallTotals(ctx context.Context, resortID string, seasons int, throughMMDD string) ([]models.SeasonSnowfallTotal, error) {
+	ctx, cancel := r.db.readContext(ctx)
+	defer cancel()
+
+	if seasons <= 0 {
+		return nil, fmt.Errorf("seasons must be positive: %d", seasons)
+	}
+
+	args := []any{resortID}
+	throughClause := ""
+	if throughMMDD != "" {
+		through, err := time.Parse("01-02", throughMMDD)
+		if err != nil {
+			return nil, fmt.Errorf("parse through date: %w", err)
+		}
+		// "MM-DD" strings order correctly within a calendar year. A cutoff from
+		// July onwards keeps days between July and the cutoff; an earlier cutoff
+		// keeps every day from July onwards plus days up to the cutoff in the
+		// following calendar year.
+		month, monthDay := r.db.dialect.Month("date"), r.db.dialect.MonthDay("date")
+		if through.Month() >= seasonStartMonth {
+			throughClause = fmt.Sprintf("AND %s >= ? AND %s <= ?", month, monthDay)
+		} else {
+			throughClause = fmt.Sprintf("AND (%s >= ? OR %s <= ?)", month, monthDay)
+		}
+		args = append(args, int(seasonStartMonth), throughMMDD)
+	}
+	args = append(args, seasons)
+
+	// SAFETY: the season expression and throughClause are hardcoded, not user-supplied
+	query := fmt.Sprintf(`
+		WITH season_totals AS (
+			SELECT
+				resort_id,
+				%s AS season_year,
+				SUM(snowfall_cm) AS total_snowfall,
+				COUNT(*) AS days_with_data
+			FROM daily_snowfall
+			WHERE resort_id = ?
+			%s
+			GROUP BY resort_id, season_year
+		),
+		recent AS (
+			SELECT * FROM season_totals
+			ORDER BY season_year DESC
+			LIMIT ?
+		)
+		SELECT resort_id, season_year, total_snowfall, days_with_data,
+			   RANK() OVER (ORDER BY total_snowfall DESC)
+		FROM recent
+		ORDER BY season_year DESC
+	`, seasonYearExpr(r.db.dialect, int(seasonStartMonth)), throughClause)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query season snowfall totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []models.SeasonSnowfallTotal{}
+	for rows.Next() {
+		var t models.SeasonSnowfallTotal
+		if err := rows.Scan(&t.ResortID, &t.StartYear, &t.TotalSnowfallCM, &t.DaysWithData, &t.Rank); err != nil {
+			return nil, fmt.Errorf("scan season snowfall total: %w", err)
+		}
+		t.Season = seasonLabel(t.StartYear)
+		totals = append(totals, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	return totals, nil
+}
+
+// seasonLabel formats the season starting in startYear as "YYYY-YY".
+func seasonLabel(startYear int) string {
+	return fmt.Sprintf("%d-%02d", startYear, (startYear+1)%100)
+}
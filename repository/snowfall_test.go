@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func seedSnowfall(t *testing.T, writer *WriterRepository) string {
+	t.Helper()
+	ctx := context.Background()
+
+	resort := &models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"}
+	if err := writer.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+
+	snowfalls := []models.DailySnowfall{
+		// 2022-23: 100 cm, all in December
+		{ResortID: resort.ID, Date: day(2022, time.December, 10), SnowfallCM: 100},
+		// 2023-24: 80 cm, mostly after January
+		{ResortID: resort.ID, Date: day(2023, time.December, 15), SnowfallCM: 20},
+		{ResortID: resort.ID, Date: day(2024, time.February, 1), SnowfallCM: 60},
+		// 2024-25: 50 cm so far
+		{ResortID: resort.ID, Date: day(2024, time.December, 20), SnowfallCM: 30},
+		{ResortID: resort.ID, Date: day(2025, time.January, 5), SnowfallCM: 20},
+	}
+	if err := writer.SaveDailySnowfall(ctx, snowfalls); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+	return resort.ID
+}
+
+func TestReaderRepositoryGetDailySnowfall_FiltersAndOrders(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	id := seedSnowfall(t, writer)
+
+	got, err := writer.GetDailySnowfall(context.Background(), id, day(2023, time.December, 1), day(2024, time.December, 31))
+	if err != nil {
+		t.Fatalf("GetDailySnowfall() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("GetDailySnowfall() returned %d rows, want 3: %+v", len(got), got)
+	}
+	if !got[0].Date.Equal(day(2023, time.December, 15)) || got[2].SnowfallCM != 30 {
+		t.Fatalf("GetDailySnowfall() = %+v", got)
+	}
+}
+
+func TestReaderRepositoryGetSeasonSnowfallTotals_GroupsAndRanks(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	id := seedSnowfall(t, writer)
+
+	got, err := writer.GetSeasonSnowfallTotals(context.Background(), id, 10, "")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals() error = %v", err)
+	}
+	want := []models.SeasonSnowfallTotal{
+		{ResortID: id, Season: "2024-25", StartYear: 2024, TotalSnowfallCM: 50, DaysWithData: 2, Rank: 3},
+		{ResortID: id, Season: "2023-24", StartYear: 2023, TotalSnowfallCM: 80, DaysWithData: 2, Rank: 2},
+		{ResortID: id, Season: "2022-23", StartYear: 2022, TotalSnowfallCM: 100, DaysWithData: 1, Rank: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetSeasonSnowfallTotals() returned %d seasons, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("season %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	limited, err := writer.GetSeasonSnowfallTotals(context.Background(), id, 2, "")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals() error = %v", err)
+	}
+	if len(limited) != 2 || limited[0].Rank != 2 || limited[1].Rank != 1 {
+		t.Fatalf("limited totals = %+v", limited)
+	}
+}
+
+func TestReaderRepositoryGetSeasonSnowfallTotals_SeasonToDate(t *testing.T) {
+	t.Parallel()
+
+	writer := NewWriter(newTestDB(t))
+	id := seedSnowfall(t, writer)
+
+	got, err := writer.GetSeasonSnowfallTotals(context.Background(), id, 10, "01-10")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals() error = %v", err)
+	}
+	totals := map[string]int{}
+	for _, s := range got {
+		totals[s.Season] = s.TotalSnowfallCM
+	}
+	if totals["2024-25"] != 50 || totals["2023-24"] != 20 || totals["2022-23"] != 100 {
+		t.Fatalf("season-to-date totals = %v", totals)
+	}
+
+	got, err = writer.GetSeasonSnowfallTotals(context.Background(), id, 10, "12-12")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Season != "2022-23" {
+		t.Fatalf("totals through 12-12 = %+v", got)
+	}
+
+	if _, err := writer.GetSeasonSnowfallTotals(context.Background(), id, 0, ""); err == nil {
+		t.Fatal("expected error for non-positive seasons")
+	}
+}
@@ -7,25 +7,27 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/amaumene/snowfinder_common/internal/geo"
+	"github.com/amaumene/snowfinder_common/internal/resortidentity"
 	"github.com/amaumene/snowfinder_common/models"
 	"github.com/google/uuid"
 )
 
-const batchChunkSize = 500
-
 // WriterRepository provides full read-write database access.
 type WriterRepository struct {
 	*ReaderRepository
 }
 
 // NewWriter creates a new read-write repository.
-func NewWriter(db *sql.DB) *WriterRepository {
+func NewWriter(db *sql.DB, opts ...Option) *WriterRepository {
 	return &WriterRepository{
-		ReaderRepository: NewReader(db),
+		ReaderRepository: NewReader(db, opts...),
 	}
 }
 
-// SaveResort upserts a resort record into the database.
+// SaveResort upserts a resort record into the database. A resort saved without
+// coordinates keeps the ones already stored for it, so stored coordinates can
+// be corrected but never removed.
 // It mutates the caller's *models.Resort as a side effect: if the resort already
 // exists under a different slug (due to scoping), both ID and Slug fields are
 // updated to reflect the persisted values.
@@ -33,8 +35,11 @@ func (r *WriterRepository) SaveResort(ctx context.Context, resort *models.Resort
 	if resort == nil {
 		return errors.New("nil resort")
 	}
+	if err := geo.ValidateResort(resort); err != nil {
+		return fmt.Errorf("save resort: %w", err)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := r.db.writeContext(ctx)
 	defer cancel()
 
 	resolvedID := resort.ID
@@ -56,8 +61,9 @@ func (r *WriterRepository) SaveResort(ctx context.Context, resort *models.Resort
 		INSERT INTO resorts (
 			id, slug, name, prefecture, region,
 			top_elevation_m, base_elevation_m, vertical_m,
-			num_courses, longest_course_km, steepest_course_deg
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			num_courses, longest_course_km, steepest_course_deg,
+			latitude, longitude, geo_cell
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT (slug) DO UPDATE SET
 			name = EXCLUDED.name,
 			prefecture = EXCLUDED.prefecture,
@@ -68,13 +74,17 @@ func (r *WriterRepository) SaveResort(ctx context.Context, resort *models.Resort
 			num_courses = EXCLUDED.num_courses,
 			longest_course_km = EXCLUDED.longest_course_km,
 			steepest_course_deg = EXCLUDED.steepest_course_deg,
-			last_updated = datetime('now')
+			latitude = COALESCE(EXCLUDED.latitude, resorts.latitude),
+			longitude = COALESCE(EXCLUDED.longitude, resorts.longitude),
+			geo_cell = COALESCE(EXCLUDED.geo_cell, resorts.geo_cell),
+			last_updated = CURRENT_TIMESTAMP
 	`
 
 	_, err = r.ReaderRepository.db.ExecContext(ctx, query,
 		resolvedID, resolvedSlug, resort.Name, resort.Prefecture, resort.Region,
 		resort.TopElevationM, resort.BaseElevationM, resort.VerticalM,
 		resort.NumCourses, resort.LongestCourseKM, resort.SteepestCourseDeg,
+		resort.Latitude, resort.Longitude, geoCell(resort.Latitude, resort.Longitude),
 	)
 
 	if err != nil {
@@ -87,14 +97,14 @@ func (r *WriterRepository) SaveResort(ctx context.Context, resort *models.Resort
 	return nil
 }
 
-func (r *WriterRepository) resolveResortRecord(ctx context.Context, resort *models.Resort) (*resortIdentityRecord, error) {
+func (r *WriterRepository) resolveResortRecord(ctx context.Context, resort *models.Resort) (*resortidentity.Record, error) {
 	existingBySlug, err := r.getResortIdentityRecordBySlug(ctx, resort.Slug)
 	if err != nil {
 		return nil, err
 	}
 
-	scopedSlug := scopedResortSlug(resort.Slug, resort.Prefecture, resort.Region)
-	var existingByScopedSlug *resortIdentityRecord
+	scopedSlug := resortidentity.ScopedSlug(resort.Slug, resort.Prefecture, resort.Region)
+	var existingByScopedSlug *resortidentity.Record
 	if scopedSlug != resort.Slug {
 		existingByScopedSlug, err = r.getResortIdentityRecordBySlug(ctx, scopedSlug)
 		if err != nil {
@@ -102,17 +112,17 @@ func (r *WriterRepository) resolveResortRecord(ctx context.Context, resort *mode
 		}
 	}
 
-	return resolvePersistedResortRecordOrError(resort, existingBySlug, existingByScopedSlug)
+	return resortidentity.Resolve(resort, existingBySlug, existingByScopedSlug)
 }
 
-func (r *WriterRepository) getResortIdentityRecordBySlug(ctx context.Context, slug string) (*resortIdentityRecord, error) {
+func (r *WriterRepository) getResortIdentityRecordBySlug(ctx context.Context, slug string) (*resortidentity.Record, error) {
 	query := `
 		SELECT id, slug, name, prefecture, region
 		FROM resorts
 		WHERE slug = ?
 	`
 
-	var record resortIdentityRecord
+	var record resortidentity.Record
 	err := r.ReaderRepository.db.QueryRowContext(ctx, query, slug).Scan(
 		&record.ID,
 		&record.Slug,
@@ -132,15 +142,13 @@ func (r *WriterRepository) getResortIdentityRecordBySlug(ctx context.Context, sl
 }
 
 // SaveSnowDepthReadings upserts a batch of snow depth readings.
-// Readings are written in chunks of batchChunkSize, each in its own transaction.
+// Readings are written in chunks of the configured batch chunk size, each in its own
+// transaction bounded by the write timeout.
 func (r *WriterRepository) SaveSnowDepthReadings(ctx context.Context, readings []models.SnowDepthReading) error {
 	if len(readings) == 0 {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
 	query := `
 		INSERT INTO snow_depth_readings (resort_id, date, depth_cm)
 		VALUES (?, ?, ?)
@@ -148,8 +156,8 @@ func (r *WriterRepository) SaveSnowDepthReadings(ctx context.Context, readings [
 			depth_cm = EXCLUDED.depth_cm
 	`
 
-	for start := 0; start < len(readings); start += batchChunkSize {
-		end := start + batchChunkSize
+	for start := 0; start < len(readings); start += r.db.batchChunkSize {
+		end := start + r.db.batchChunkSize
 		if end > len(readings) {
 			end = len(readings)
 		}
@@ -164,6 +172,9 @@ func (r *WriterRepository) SaveSnowDepthReadings(ctx context.Context, readings [
 // saveSnowDepthChunk writes a single chunk of snow depth readings in one transaction.
 // defer tx.Rollback() is scoped to this function, not the enclosing loop.
 func (r *WriterRepository) saveSnowDepthChunk(ctx context.Context, query string, chunk []models.SnowDepthReading) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
 	tx, err := r.ReaderRepository.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
@@ -181,36 +192,107 @@ func (r *WriterRepository) saveSnowDepthChunk(ctx context.Context, query string,
 	return nil
 }
 
-// SaveFailedScrapeAttempt records a new failed scrape attempt for the given URL.
+// SaveFailedScrapeAttempt records a failed scrape attempt for the given URL.
+// A repeated failure of a URL with an open attempt increments that attempt's
+// count; otherwise a new attempt is recorded. Either way the attempt is
+// classified as transient and is due for a retry immediately; use
+// SaveScrapeFailure to schedule retries.
 func (r *WriterRepository) SaveFailedScrapeAttempt(ctx context.Context, resortURL, errorMessage string) error {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := r.db.writeContext(ctx)
 	defer cancel()
 
+	now := time.Now().UTC().Format(time.RFC3339)
 	query := `
-		INSERT INTO failed_scrape_attempts (id, resort_url, error_message, failed_at, retried)
-		VALUES (?, ?, ?, datetime('now'), FALSE)
+		INSERT INTO failed_scrape_attempts (
+			id, resort_url, error_message, error_class, attempts, failed_at,
+			next_retry_at, dead_lettered, retried
+		) VALUES (?, ?, ?, ?, 1, ?, ?, FALSE, FALSE)
+		ON CONFLICT (resort_url) WHERE retried = FALSE AND dead_lettered = FALSE DO UPDATE SET
+			error_message = EXCLUDED.error_message,
+			error_class = EXCLUDED.error_class,
+			attempts = failed_scrape_attempts.attempts + 1,
+			failed_at = EXCLUDED.failed_at,
+			next_retry_at = EXCLUDED.next_retry_at
 	`
-
-	if _, err := r.ReaderRepository.db.ExecContext(ctx, query, uuid.New().String(), resortURL, errorMessage); err != nil {
+	if _, err := r.ReaderRepository.db.ExecContext(ctx, query,
+		uuid.New().String(), resortURL, errorMessage, models.ScrapeErrorTransient, now, now,
+	); err != nil {
 		return fmt.Errorf("save failed scrape attempt: %w", err)
 	}
+	return nil
+}
+
+// SaveScrapeFailure records a failed scrape with its retry schedule. An
+// attempt without an ID is inserted and given one, which fails if its URL
+// already has an open attempt; otherwise the pending attempt with that ID is
+// updated with the error, attempt count, failure time, next retry time and
+// dead-letter state. Returns an error if the attempt does not exist or was
+// already retried.
+func (r *WriterRepository) SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	var nextRetryAt *string
+	if attempt.NextRetryAt != nil {
+		next := attempt.NextRetryAt.UTC().Format(time.RFC3339)
+		nextRetryAt = &next
+	}
+	failedAt := attempt.FailedAt.UTC().Format(time.RFC3339)
+
+	if attempt.ID == "" {
+		id := uuid.New().String()
+		query := `
+			INSERT INTO failed_scrape_attempts (
+				id, resort_url, error_message, error_class, attempts, failed_at,
+				next_retry_at, dead_lettered, retried
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, FALSE)
+		`
+		if _, err := r.ReaderRepository.db.ExecContext(ctx, query,
+			id, attempt.ResortURL, attempt.ErrorMessage, attempt.ErrorClass, attempt.Attempts, failedAt,
+			nextRetryAt, attempt.DeadLettered,
+		); err != nil {
+			return fmt.Errorf("save failed scrape attempt: %w", err)
+		}
+		attempt.ID = id
+		return nil
+	}
 
+	query := `
+		UPDATE failed_scrape_attempts
+		SET error_message = ?, error_class = ?, attempts = ?, failed_at = ?,
+			next_retry_at = ?, dead_lettered = ?
+		WHERE id = ? AND retried = FALSE
+	`
+	result, err := r.ReaderRepository.db.ExecContext(ctx, query,
+		attempt.ErrorMessage, attempt.ErrorClass, attempt.Attempts, failedAt,
+		nextRetryAt, attempt.DeadLettered, attempt.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update failed scrape attempt %s: %w", attempt.ID, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update failed scrape attempt %s: rows affected: %w", attempt.ID, err)
+	}
+	if rowsAffected != 1 {
+		return fmt.Errorf("update failed scrape attempt %s: affected %d rows, want 1", attempt.ID, rowsAffected)
+	}
 	return nil
 }
 
 // MarkFailedAttemptRetried marks the failed scrape attempt with the given ID as retried.
 // Returns an error if no row was updated.
 func (r *WriterRepository) MarkFailedAttemptRetried(ctx context.Context, id string) error {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := r.db.writeContext(ctx)
 	defer cancel()
 
 	query := `
 		UPDATE failed_scrape_attempts
-		SET retried = TRUE, retried_at = datetime('now')
+		SET retried = TRUE, retried_at = ?, next_retry_at = NULL
 		WHERE id = ?
 	`
 
-	result, err := r.ReaderRepository.db.ExecContext(ctx, query, id)
+	result, err := r.ReaderRepository.db.ExecContext(ctx, query, time.Now().UTC().Format(time.RFC3339), id)
 	if err != nil {
 		return fmt.Errorf("mark failed attempt retried: %w", err)
 	}
@@ -226,15 +308,13 @@ func (r *WriterRepository) MarkFailedAttemptRetried(ctx context.Context, id stri
 }
 
 // SaveDailySnowfall upserts a batch of daily snowfall records.
-// Records are written in chunks of batchChunkSize, each in its own transaction.
+// Records are written in chunks of the configured batch chunk size, each in its own
+// transaction bounded by the write timeout.
 func (r *WriterRepository) SaveDailySnowfall(ctx context.Context, snowfalls []models.DailySnowfall) error {
 	if len(snowfalls) == 0 {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
 	query := `
 		INSERT INTO daily_snowfall (resort_id, date, snowfall_cm)
 		VALUES (?, ?, ?)
@@ -242,8 +322,8 @@ func (r *WriterRepository) SaveDailySnowfall(ctx context.Context, snowfalls []mo
 			snowfall_cm = EXCLUDED.snowfall_cm
 	`
 
-	for start := 0; start < len(snowfalls); start += batchChunkSize {
-		end := start + batchChunkSize
+	for start := 0; start < len(snowfalls); start += r.db.batchChunkSize {
+		end := start + r.db.batchChunkSize
 		if end > len(snowfalls) {
 			end = len(snowfalls)
 		}
@@ -258,6 +338,9 @@ func (r *WriterRepository) SaveDailySnowfall(ctx context.Context, snowfalls []mo
 // saveDailySnowfallChunk writes a single chunk of daily snowfall records in one transaction.
 // defer tx.Rollback() is scoped to this function, not the enclosing loop.
 func (r *WriterRepository) saveDailySnowfallChunk(ctx context.Context, query string, chunk []models.DailySnowfall) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
 	tx, err := r.ReaderRepository.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
@@ -274,3 +357,70 @@ func (r *WriterRepository) saveDailySnowfallChunk(ctx context.Context, query str
 	}
 	return nil
 }
+
+// ReplacePeakPeriods atomically replaces all peak periods for a resort.
+// Peaks with an empty ID are assigned a new UUID; StartDate, EndDate and
+// CenterDate must be "MM-DD". An empty slice clears the resort's peaks.
+func (r *WriterRepository) ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error {
+	ctx, cancel := r.db.writeContext(ctx)
+	defer cancel()
+
+	tx, err := r.ReaderRepository.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM resort_peak_periods WHERE resort_id = ?", resortID); err != nil {
+		return fmt.Errorf("delete peak periods: %w", err)
+	}
+
+	query := `
+		INSERT INTO resort_peak_periods (
+			id, resort_id, peak_rank, start_doy, end_doy, center_doy,
+			avg_daily_snowfall, total_period_snowfall, prominence_score,
+			years_of_data, confidence_level, reliability_score,
+			winters_present, total_winters, regional_consistency,
+			calculated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, peak := range peaks {
+		startDOY, err := mmddToDOY(peak.StartDate)
+		if err != nil {
+			return fmt.Errorf("convert start date: %w", err)
+		}
+		endDOY, err := mmddToDOY(peak.EndDate)
+		if err != nil {
+			return fmt.Errorf("convert end date: %w", err)
+		}
+		centerDOY, err := mmddToDOY(peak.CenterDate)
+		if err != nil {
+			return fmt.Errorf("convert center date: %w", err)
+		}
+
+		id := peak.ID
+		if id == "" {
+			id = uuid.New().String()
+		}
+		calculatedAt := peak.CalculatedAt
+		if calculatedAt.IsZero() {
+			calculatedAt = time.Now()
+		}
+
+		if _, err := tx.ExecContext(ctx, query,
+			id, resortID, peak.PeakRank, startDOY, endDOY, centerDOY,
+			peak.AvgDailySnowfall, peak.TotalPeriodSnowfall, peak.ProminenceScore,
+			peak.YearsOfData, peak.ConfidenceLevel, peak.ReliabilityScore,
+			peak.WintersPresent, peak.TotalWinters, peak.RegionalConsistency,
+			calculatedAt.UTC().Format(time.RFC3339),
+		); err != nil {
+			return fmt.Errorf("save peak period rank %d: %w", peak.PeakRank, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit peak periods: %w", err)
+	}
+	return nil
+}
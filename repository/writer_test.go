@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+func TestWriterRepositoryReplacePeakPeriods_ReplacesAtomically(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	writer := NewWriter(db)
+	ctx := context.Background()
+
+	resort := &models.Resort{Slug: "mount-foo", Name: "Mount Foo", Prefecture: "nagano", Region: "north"}
+	if err := writer.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+
+	first := []models.PeakPeriod{
+		{PeakRank: 1, StartDate: "01-10", EndDate: "01-24", CenterDate: "01-17", ConfidenceLevel: "high"},
+		{PeakRank: 2, StartDate: "02-20", EndDate: "03-06", CenterDate: "02-27", ConfidenceLevel: "low"},
+	}
+	if err := writer.ReplacePeakPeriods(ctx, resort.ID, first); err != nil {
+		t.Fatalf("ReplacePeakPeriods() error = %v", err)
+	}
+
+	second := []models.PeakPeriod{
+		{PeakRank: 1, StartDate: "12-25", EndDate: "01-08", CenterDate: "01-01", ConfidenceLevel: "medium",
+			CalculatedAt: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	if err := writer.ReplacePeakPeriods(ctx, resort.ID, second); err != nil {
+		t.Fatalf("second ReplacePeakPeriods() error = %v", err)
+	}
+
+	got, err := writer.GetPeakPeriodsForResort(ctx, resort.ID)
+	if err != nil {
+		t.Fatalf("GetPeakPeriodsForResort() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("GetPeakPeriodsForResort() returned %d peaks, want 1", len(got))
+	}
+	if got[0].StartDate != "12-25" || got[0].CenterDate != "01-01" || got[0].ConfidenceLevel != "medium" {
+		t.Fatalf("GetPeakPeriodsForResort() = %+v", got[0])
+	}
+	if !got[0].CalculatedAt.Equal(second[0].CalculatedAt) {
+		t.Fatalf("CalculatedAt = %s, want %s", got[0].CalculatedAt, second[0].CalculatedAt)
+	}
+
+	bad := []models.PeakPeriod{{PeakRank: 1, StartDate: "bad", EndDate: "01-08", CenterDate: "01-01"}}
+	if err := writer.ReplacePeakPeriods(ctx, resort.ID, bad); err == nil {
+		t.Fatal("expected error for invalid start date")
+	}
+	got, err = writer.GetPeakPeriodsForResort(ctx, resort.ID)
+	if err != nil {
+		t.Fatalf("GetPeakPeriodsForResort() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("failed replace left %d peaks, want 1", len(got))
+	}
+}
+
+func TestReaderRepositoryGetAllResorts_OrdersByPrefectureAndName(t *testing.T) {
+	t.Parallel()
+
+	db := newTestDB(t)
+	writer := NewWriter(db)
+	ctx := context.Background()
+
+	for _, r := range []models.Resort{
+		{Slug: "b", Name: "Bravo", Prefecture: "nagano"},
+		{Slug: "a", Name: "Alpha", Prefecture: "nagano"},
+		{Slug: "c", Name: "Charlie", Prefecture: "hokkaido"},
+	} {
+		resort := r
+		if err := writer.SaveResort(ctx, &resort); err != nil {
+			t.Fatalf("SaveResort() error = %v", err)
+		}
+	}
+
+	got, err := writer.GetAllResorts(ctx)
+	if err != nil {
+		t.Fatalf("GetAllResorts() error = %v", err)
+	}
+	if len(got) != 3 || got[0].Name != "Charlie" || got[1].Name != "Alpha" || got[2].Name != "Bravo" {
+		t.Fatalf("GetAllResorts() = %+v", got)
+	}
+}
@@ -0,0 +1,796 @@
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+)
+
+// TestWriter runs the repository.Writer conformance suite. newWriter must
+// return an empty repository each time it is called; every check runs as a
+// parallel subtest with its own repository.
+//
+// The suite pins down behaviour that callers rely on, so the in-memory
+// Repository and the SQL implementations are held to the same contract.
+func TestWriter(t *testing.T, newWriter func(t *testing.T) repository.Writer) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, w repository.Writer)
+	}{
+		{"ResortUpsert", testResortUpsert},
+		{"ResortNotFound", testResortNotFound},
+		{"ResortSlugScoping", testResortSlugScoping},
+		{"ResortOrdering", testResortOrdering},
+		{"ResortSearch", testResortSearch},
+		{"ResortGeo", testResortGeo},
+		{"DailySnowfall", testDailySnowfall},
+		{"SeasonSnowfallTotals", testSeasonSnowfallTotals},
+		{"SnowiestResorts", testSnowiestResorts},
+		{"RegionSummaries", testRegionSummaries},
+		{"SnowDepth", testSnowDepth},
+		{"PeakPeriods", testPeakPeriods},
+		{"FailedScrapeAttempts", testFailedScrapeAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.fn(t, newWriter(t))
+		})
+	}
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func intPtr(v int) *int { return &v }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func saveResort(t *testing.T, w repository.Writer, resort models.Resort) *models.Resort {
+	t.Helper()
+	if err := w.SaveResort(context.Background(), &resort); err != nil {
+		t.Fatalf("SaveResort(%q) error = %v", resort.Slug, err)
+	}
+	return &resort
+}
+
+func testResortUpsert(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	resort := saveResort(t, w, models.Resort{
+		Slug: "hakuba", Name: "Hakuba", Prefecture: "nagano", Region: "north", VerticalM: intPtr(900),
+		Latitude: floatPtr(36.7), Longitude: floatPtr(137.85),
+	})
+	if resort.ID == "" || resort.Slug != "hakuba" {
+		t.Fatalf("SaveResort() set ID %q slug %q", resort.ID, resort.Slug)
+	}
+
+	// Same identity: updates in place and keeps the ID even if the caller's differs.
+	update := models.Resort{ID: "ignored", Slug: "hakuba", Name: "Hakuba 47", Prefecture: "Nagano", Region: "North"}
+	if err := w.SaveResort(ctx, &update); err != nil {
+		t.Fatalf("SaveResort() update error = %v", err)
+	}
+	if update.ID != resort.ID {
+		t.Fatalf("SaveResort() update ID = %q, want %q", update.ID, resort.ID)
+	}
+
+	got, err := w.GetResortByID(ctx, resort.ID)
+	if err != nil {
+		t.Fatalf("GetResortByID() error = %v", err)
+	}
+	if got.Name != "Hakuba 47" || got.VerticalM != nil || got.LastUpdated.IsZero() {
+		t.Fatalf("GetResortByID() = %+v", got)
+	}
+	// An update without coordinates keeps the stored ones, and the resort
+	// stays findable by location.
+	if got.Latitude == nil || *got.Latitude != 36.7 || got.Longitude == nil || *got.Longitude != 137.85 {
+		t.Fatalf("GetResortByID() coordinates = %v, %v, want kept", got.Latitude, got.Longitude)
+	}
+	near, err := w.GetResortsNear(ctx, 36.7, 137.85, 1, 10)
+	if err != nil {
+		t.Fatalf("GetResortsNear() error = %v", err)
+	}
+	if len(near) != 1 || near[0].Resort.ID != resort.ID {
+		t.Fatalf("GetResortsNear() = %+v, want the updated resort", near)
+	}
+
+	if err := w.SaveResort(ctx, nil); err == nil {
+		t.Fatal("SaveResort(nil) expected error")
+	}
+}
+
+func testResortNotFound(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	if _, err := w.GetResortBySlug(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetResortBySlug(missing) error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := w.GetResortByID(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetResortByID(missing) error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testResortSlugScoping(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	first := saveResort(t, w, models.Resort{Slug: "kokusai", Name: "Kokusai", Prefecture: "nagano"})
+	second := saveResort(t, w, models.Resort{Slug: "kokusai", Name: "Kokusai", Prefecture: "Niigata", Region: "Uonuma"})
+	if second.ID == first.ID || second.Slug != "kokusai--niigata--uonuma" {
+		t.Fatalf("colliding SaveResort() = ID %q slug %q", second.ID, second.Slug)
+	}
+
+	// Saving the scoped resort again under the plain slug finds the scoped row.
+	again := saveResort(t, w, models.Resort{Slug: "kokusai", Name: "Kokusai", Prefecture: "niigata", Region: "uonuma"})
+	if again.ID != second.ID || again.Slug != second.Slug {
+		t.Fatalf("repeat SaveResort() = ID %q slug %q, want %q %q", again.ID, again.Slug, second.ID, second.Slug)
+	}
+
+	got, err := w.GetResortBySlug(ctx, "kokusai--niigata--uonuma")
+	if err != nil {
+		t.Fatalf("GetResortBySlug(scoped) error = %v", err)
+	}
+	if got.ID != second.ID {
+		t.Fatalf("GetResortBySlug(scoped) ID = %q, want %q", got.ID, second.ID)
+	}
+
+	// A third resort whose scoped slug is already taken by an unrelated one cannot be saved.
+	saveResort(t, w, models.Resort{Slug: "zao--miyagi", Name: "Zao Other", Prefecture: "yamagata"})
+	saveResort(t, w, models.Resort{Slug: "zao", Name: "Zao", Prefecture: "yamagata"})
+	collision := models.Resort{Slug: "zao", Name: "Zao Miyagi", Prefecture: "miyagi"}
+	if err := w.SaveResort(ctx, &collision); err == nil {
+		t.Fatal("SaveResort() with taken scoped slug expected error")
+	}
+}
+
+func testResortOrdering(t *testing.T, w repository.Writer) {
+	for _, r := range []models.Resort{
+		{Slug: "b", Name: "Bravo", Prefecture: "nagano"},
+		{Slug: "a", Name: "Alpha", Prefecture: "nagano"},
+		{Slug: "c", Name: "Charlie", Prefecture: "hokkaido"},
+	} {
+		saveResort(t, w, r)
+	}
+
+	got, err := w.GetAllResorts(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllResorts() error = %v", err)
+	}
+	var names []string
+	for _, r := range got {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, ",") != "Charlie,Alpha,Bravo" {
+		t.Fatalf("GetAllResorts() order = %v", names)
+	}
+}
+
+// seedSnowfall stores three seasons of snowfall for one resort.
+func seedSnowfall(t *testing.T, w repository.Writer) string {
+	t.Helper()
+
+	resort := saveResort(t, w, models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"})
+	snowfalls := []models.DailySnowfall{
+		// 2022-23: 100 cm, all in December
+		{ResortID: resort.ID, Date: day(2022, time.December, 10), SnowfallCM: 100},
+		// 2023-24: 80 cm, mostly after January
+		{ResortID: resort.ID, Date: day(2023, time.December, 15), SnowfallCM: 20},
+		{ResortID: resort.ID, Date: day(2024, time.February, 1), SnowfallCM: 60},
+		// 2024-25: 50 cm so far
+		{ResortID: resort.ID, Date: day(2024, time.December, 20), SnowfallCM: 30},
+		{ResortID: resort.ID, Date: day(2025, time.January, 5), SnowfallCM: 20},
+	}
+	if err := w.SaveDailySnowfall(context.Background(), snowfalls); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+	return resort.ID
+}
+
+func testDailySnowfall(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+	id := seedSnowfall(t, w)
+
+	// Upsert replaces the value for an existing day.
+	if err := w.SaveDailySnowfall(ctx, []models.DailySnowfall{{ResortID: id, Date: day(2025, time.January, 5), SnowfallCM: 25}}); err != nil {
+		t.Fatalf("SaveDailySnowfall() upsert error = %v", err)
+	}
+
+	got, err := w.GetDailySnowfall(ctx, id, day(2024, time.December, 1), day(2025, time.January, 31))
+	if err != nil {
+		t.Fatalf("GetDailySnowfall() error = %v", err)
+	}
+	if len(got) != 2 || !got[0].Date.Equal(day(2024, time.December, 20)) || got[1].SnowfallCM != 25 {
+		t.Fatalf("GetDailySnowfall() = %+v", got)
+	}
+
+	empty, err := w.GetDailySnowfall(ctx, "missing", day(2024, time.December, 1), day(2025, time.January, 31))
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Fatalf("GetDailySnowfall(missing) = %v, %v; want empty slice", empty, err)
+	}
+	if _, err := w.GetDailySnowfall(ctx, id, day(2025, time.January, 31), day(2024, time.December, 1)); err == nil {
+		t.Fatal("GetDailySnowfall() with to before from expected error")
+	}
+}
+
+func testSeasonSnowfallTotals(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+	id := seedSnowfall(t, w)
+
+	format := func(totals []models.SeasonSnowfallTotal) string {
+		parts := make([]string, len(totals))
+		for i, total := range totals {
+			parts[i] = fmt.Sprintf("%s=%d/%d#%d", total.Season, total.TotalSnowfallCM, total.DaysWithData, total.Rank)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	full, err := w.GetSeasonSnowfallTotals(ctx, id, 2, "")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals() error = %v", err)
+	}
+	if got, want := format(full), "2024-25=50/2#2 2023-24=80/2#1"; got != want {
+		t.Fatalf("GetSeasonSnowfallTotals() = %s, want %s", got, want)
+	}
+
+	toDate, err := w.GetSeasonSnowfallTotals(ctx, id, 3, "01-31")
+	if err != nil {
+		t.Fatalf("GetSeasonSnowfallTotals(through) error = %v", err)
+	}
+	if got, want := format(toDate), "2024-25=50/2#2 2023-24=20/1#3 2022-23=100/1#1"; got != want {
+		t.Fatalf("GetSeasonSnowfallTotals(through) = %s, want %s", got, want)
+	}
+
+	if _, err := w.GetSeasonSnowfallTotals(ctx, id, 0, ""); err == nil {
+		t.Fatal("GetSeasonSnowfallTotals(0 seasons) expected error")
+	}
+}
+
+func testSnowiestResorts(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+	id := seedSnowfall(t, w)
+	other := saveResort(t, w, models.Resort{Slug: "b", Name: "B", Prefecture: "hokkaido"})
+	if err := w.SaveDailySnowfall(ctx, []models.DailySnowfall{
+		{ResortID: other.ID, Date: day(2024, time.December, 22), SnowfallCM: 200},
+	}); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+
+	// Dec 1 to Jan 31 crosses the new year, so winters are grouped by season.
+	stats, err := w.GetSnowiestResorts(ctx, "12-01", "01-31", "", 10)
+	if err != nil {
+		t.Fatalf("GetSnowiestResorts() error = %v", err)
+	}
+	if len(stats) != 2 || stats[0].ResortID != other.ID || stats[1].ResortID != id ||
+		*stats[1].TotalSnowfall != 57 || *stats[1].YearsWithData != 3 {
+		t.Fatalf("GetSnowiestResorts() = %+v", stats)
+	}
+
+	// Week mode within one calendar year, filtered by prefecture. Days are
+	// matched by day of year in a leap year (Dec 8-14 is days 343-349), so in
+	// common years the window ends on Dec 15.
+	week, err := w.GetSnowiestResorts(ctx, "2025-12-08", "", "nagano", 10)
+	if err != nil {
+		t.Fatalf("GetSnowiestResorts(week) error = %v", err)
+	}
+	if len(week) != 1 || week[0].ResortID != id || *week[0].TotalSnowfall != 60 || *week[0].YearsWithData != 2 {
+		t.Fatalf("GetSnowiestResorts(week) = %+v", week)
+	}
+
+	if _, err := w.GetSnowiestResorts(ctx, "12-01", "01-31", "", 0); err == nil {
+		t.Fatal("GetSnowiestResorts(limit 0) expected error")
+	}
+
+	// Resorts with the same average are ordered by ID, so the limit cuts
+	// between them deterministically.
+	c := saveResort(t, w, models.Resort{Slug: "c", Name: "C", Prefecture: "hokkaido"})
+	d := saveResort(t, w, models.Resort{Slug: "d", Name: "D", Prefecture: "hokkaido"})
+	if err := w.SaveDailySnowfall(ctx, []models.DailySnowfall{
+		{ResortID: c.ID, Date: day(2024, time.December, 22), SnowfallCM: 30},
+		{ResortID: d.ID, Date: day(2024, time.December, 22), SnowfallCM: 30},
+	}); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+	first, second := c.ID, d.ID
+	if second < first {
+		first, second = second, first
+	}
+	tied, err := w.GetSnowiestResorts(ctx, "12-01", "01-31", "", 3)
+	if err != nil {
+		t.Fatalf("GetSnowiestResorts(tie) error = %v", err)
+	}
+	if len(tied) != 3 || tied[2].ResortID != first {
+		t.Fatalf("GetSnowiestResorts(tie, limit 3) = %+v, want %s third", tied, first)
+	}
+	tied, err = w.GetSnowiestResorts(ctx, "12-01", "01-31", "", 4)
+	if err != nil || len(tied) != 4 || tied[2].ResortID != first || tied[3].ResortID != second {
+		t.Fatalf("GetSnowiestResorts(tie, limit 4) = %+v, %v, want %s then %s", tied, err, first, second)
+	}
+}
+
+func testRegionSummaries(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+	seedSnowfall(t, w) // "A" in nagano: 50 cm between December 2024 and January 2025
+	b := saveResort(t, w, models.Resort{Slug: "b", Name: "B", Prefecture: "nagano", Region: "hakuba"})
+	saveResort(t, w, models.Resort{Slug: "c", Name: "C", Prefecture: "nagano", Region: "hakuba"})
+	d := saveResort(t, w, models.Resort{Slug: "d", Name: "D", Prefecture: "hokkaido", Region: "niseko"})
+	if err := w.SaveDailySnowfall(ctx, []models.DailySnowfall{
+		{ResortID: b.ID, Date: day(2024, time.December, 22), SnowfallCM: 40},
+		{ResortID: b.ID, Date: day(2025, time.March, 1), SnowfallCM: 100},
+		{ResortID: d.ID, Date: day(2025, time.January, 31), SnowfallCM: 70},
+	}); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+
+	format := func(summaries []models.RegionSummary) string {
+		parts := make([]string, len(summaries))
+		for i, s := range summaries {
+			top := "-"
+			if s.TopResort != nil {
+				top = fmt.Sprintf("%s:%g", s.TopResort.Name, s.TopResort.SnowfallCM)
+			}
+			parts[i] = fmt.Sprintf("%s/%s=%d/%d:%d/%g:%s", s.Prefecture, s.Region, s.ReportingResorts, s.ResortCount, s.TotalSnowfallCM, s.AvgSnowfallCM, top)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	from, to := day(2024, time.December, 1), day(2025, time.January, 31)
+	byPrefecture, err := w.GetRegionSummaries(ctx, from, to, repository.GroupByPrefecture)
+	if err != nil {
+		t.Fatalf("GetRegionSummaries() error = %v", err)
+	}
+	if got, want := format(byPrefecture), "hokkaido/=1/1:70/70:D:70 nagano/=2/3:90/45:A:50"; got != want {
+		t.Fatalf("GetRegionSummaries(prefecture) = %s, want %s", got, want)
+	}
+
+	byRegion, err := w.GetRegionSummaries(ctx, from, to, repository.GroupByRegion)
+	if err != nil {
+		t.Fatalf("GetRegionSummaries() error = %v", err)
+	}
+	if got, want := format(byRegion), "hokkaido/niseko=1/1:70/70:D:70 nagano/=1/1:50/50:A:50 nagano/hakuba=1/2:40/40:B:40"; got != want {
+		t.Fatalf("GetRegionSummaries(region) = %s, want %s", got, want)
+	}
+
+	// Regions without data in the range are still listed.
+	quiet, err := w.GetRegionSummaries(ctx, day(2020, time.January, 1), day(2020, time.January, 31), repository.GroupByPrefecture)
+	if err != nil {
+		t.Fatalf("GetRegionSummaries() error = %v", err)
+	}
+	if got, want := format(quiet), "hokkaido/=0/1:0/0:- nagano/=0/3:0/0:-"; got != want {
+		t.Fatalf("GetRegionSummaries(no data) = %s, want %s", got, want)
+	}
+
+	if _, err := w.GetRegionSummaries(ctx, to, from, repository.GroupByPrefecture); err == nil {
+		t.Fatal("GetRegionSummaries() with to before from expected error")
+	}
+	if _, err := w.GetRegionSummaries(ctx, from, to, repository.RegionGrouping(99)); err == nil {
+		t.Fatal("GetRegionSummaries() with unknown grouping expected error")
+	}
+}
+
+func testSnowDepth(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	a := saveResort(t, w, models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"})
+	b := saveResort(t, w, models.Resort{Slug: "b", Name: "B", Prefecture: "nagano"})
+	readings := []models.SnowDepthReading{
+		{ResortID: a.ID, Date: day(2024, time.March, 1), DepthCM: 300},
+		{ResortID: a.ID, Date: day(2024, time.December, 20), DepthCM: 80},
+		{ResortID: a.ID, Date: day(2025, time.January, 10), DepthCM: 150},
+		{ResortID: a.ID, Date: day(2025, time.January, 20), DepthCM: 150},
+		{ResortID: a.ID, Date: day(2025, time.February, 1), DepthCM: 100},
+		{ResortID: b.ID, Date: day(2025, time.January, 5), DepthCM: 60},
+	}
+	if err := w.SaveSnowDepthReadings(ctx, readings); err != nil {
+		t.Fatalf("SaveSnowDepthReadings() error = %v", err)
+	}
+	// Upsert replaces the value for an existing day.
+	if err := w.SaveSnowDepthReadings(ctx, []models.SnowDepthReading{{ResortID: a.ID, Date: day(2025, time.February, 1), DepthCM: 120}}); err != nil {
+		t.Fatalf("SaveSnowDepthReadings() upsert error = %v", err)
+	}
+
+	history, err := w.GetSnowDepthHistory(ctx, a.ID, day(2024, time.December, 1), day(2025, time.January, 31))
+	if err != nil {
+		t.Fatalf("GetSnowDepthHistory() error = %v", err)
+	}
+	if len(history) != 3 || !history[0].Date.Equal(day(2024, time.December, 20)) {
+		t.Fatalf("GetSnowDepthHistory() = %+v", history)
+	}
+
+	latest, err := w.GetLatestSnowDepth(ctx, []string{a.ID, b.ID, "missing"})
+	if err != nil {
+		t.Fatalf("GetLatestSnowDepth() error = %v", err)
+	}
+	if len(latest) != 2 || latest[a.ID].DepthCM != 120 || latest[b.ID].DepthCM != 60 {
+		t.Fatalf("GetLatestSnowDepth() = %+v", latest)
+	}
+
+	maxDepth, err := w.GetSeasonMaxSnowDepth(ctx, []string{a.ID, b.ID}, day(2025, time.February, 15))
+	if err != nil {
+		t.Fatalf("GetSeasonMaxSnowDepth() error = %v", err)
+	}
+	// The 300 cm reading belongs to the previous season; ties go to the latest day.
+	if got := maxDepth[a.ID]; got.DepthCM != 150 || !got.Date.Equal(day(2025, time.January, 20)) {
+		t.Fatalf("GetSeasonMaxSnowDepth()[a] = %+v", got)
+	}
+	if got := maxDepth[b.ID]; got.DepthCM != 60 {
+		t.Fatalf("GetSeasonMaxSnowDepth()[b] = %+v", got)
+	}
+}
+
+func testPeakPeriods(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	resort := saveResort(t, w, models.Resort{Slug: "niseko", Name: "Niseko", Prefecture: "hokkaido"})
+	saveResort(t, w, models.Resort{Slug: "no-peaks", Name: "No Peaks", Prefecture: "hokkaido"})
+
+	calculatedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	peaks := []models.PeakPeriod{
+		{PeakRank: 2, StartDate: "02-20", EndDate: "03-06", CenterDate: "02-29", ConfidenceLevel: "low", CalculatedAt: calculatedAt},
+		{PeakRank: 1, StartDate: "12-25", EndDate: "01-08", CenterDate: "01-01", ConfidenceLevel: "high", CalculatedAt: calculatedAt},
+	}
+	if err := w.ReplacePeakPeriods(ctx, resort.ID, peaks); err != nil {
+		t.Fatalf("ReplacePeakPeriods() error = %v", err)
+	}
+
+	got, err := w.GetPeakPeriodsForResort(ctx, resort.ID)
+	if err != nil {
+		t.Fatalf("GetPeakPeriodsForResort() error = %v", err)
+	}
+	if len(got) != 2 || got[0].PeakRank != 1 || got[0].ID == "" || got[0].ResortID != resort.ID ||
+		got[1].CenterDate != "02-29" || !got[0].CalculatedAt.Equal(calculatedAt) {
+		t.Fatalf("GetPeakPeriodsForResort() = %+v", got)
+	}
+
+	withPeaks, err := w.GetAllResortsWithPeaks(ctx)
+	if err != nil {
+		t.Fatalf("GetAllResortsWithPeaks() error = %v", err)
+	}
+	if len(withPeaks) != 1 || withPeaks[0].Resort.ID != resort.ID || len(withPeaks[0].Peaks) != 2 {
+		t.Fatalf("GetAllResortsWithPeaks() = %+v", withPeaks)
+	}
+
+	// A failed replace leaves the previous peaks untouched.
+	bad := []models.PeakPeriod{{PeakRank: 1, StartDate: "13-01", EndDate: "01-08", CenterDate: "01-01"}}
+	if err := w.ReplacePeakPeriods(ctx, resort.ID, bad); err == nil {
+		t.Fatal("ReplacePeakPeriods() with invalid date expected error")
+	}
+	if got, _ := w.GetPeakPeriodsForResort(ctx, resort.ID); len(got) != 2 {
+		t.Fatalf("failed replace left %d peaks, want 2", len(got))
+	}
+
+	if err := w.ReplacePeakPeriods(ctx, resort.ID, nil); err != nil {
+		t.Fatalf("ReplacePeakPeriods(nil) error = %v", err)
+	}
+	if got, err := w.GetPeakPeriodsForResort(ctx, resort.ID); err != nil || len(got) != 0 {
+		t.Fatalf("GetPeakPeriodsForResort() after clear = %+v, %v", got, err)
+	}
+}
+
+func testFailedScrapeAttempts(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	if err := w.SaveFailedScrapeAttempt(ctx, "https://example.com/a", "timeout"); err != nil {
+		t.Fatalf("SaveFailedScrapeAttempt() error = %v", err)
+	}
+	pending, err := w.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingFailedScrapeAttempts() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID == "" || pending[0].Retried || pending[0].FailedAt.IsZero() ||
+		pending[0].ErrorMessage != "timeout" {
+		t.Fatalf("GetPendingFailedScrapeAttempts() = %+v", pending)
+	}
+
+	// A repeated failure of the URL counts on its open attempt.
+	if err := w.SaveFailedScrapeAttempt(ctx, "https://example.com/a", "reset"); err != nil {
+		t.Fatalf("SaveFailedScrapeAttempt(repeat) error = %v", err)
+	}
+	open, err := w.GetOpenFailedScrapeAttempt(ctx, "https://example.com/a")
+	if err != nil || open.ID != pending[0].ID || open.Attempts != 2 || open.ErrorMessage != "reset" {
+		t.Fatalf("GetOpenFailedScrapeAttempt() = %+v, %v, want %s with 2 attempts", open, err, pending[0].ID)
+	}
+	if err := w.SaveScrapeFailure(ctx, &models.FailedScrapeAttempt{
+		ResortURL: "https://example.com/a", ErrorMessage: "503", ErrorClass: models.ScrapeErrorTransient,
+		Attempts: 1, FailedAt: time.Now(),
+	}); err == nil {
+		t.Fatal("SaveScrapeFailure() of a second open attempt for a URL expected error")
+	}
+	if _, err := w.GetOpenFailedScrapeAttempt(ctx, "https://example.com/none"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetOpenFailedScrapeAttempt(none) error = %v, want sql.ErrNoRows", err)
+	}
+
+	pending0 := *open
+	if err := w.MarkFailedAttemptRetried(ctx, pending0.ID); err != nil {
+		t.Fatalf("MarkFailedAttemptRetried() error = %v", err)
+	}
+	if err := w.MarkFailedAttemptRetried(ctx, "missing"); err == nil {
+		t.Fatal("MarkFailedAttemptRetried(missing) expected error")
+	}
+
+	pending, err = w.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingFailedScrapeAttempts() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("GetPendingFailedScrapeAttempts() after retry = %+v", pending)
+	}
+	if err := w.SaveScrapeFailure(ctx, &pending0); err == nil {
+		t.Fatal("SaveScrapeFailure() of a retried attempt expected error")
+	}
+
+	// Only attempts whose next retry time has passed are due, earliest first.
+	now := time.Now().UTC().Truncate(time.Second)
+	schedule := func(url string, next time.Time) *models.FailedScrapeAttempt {
+		t.Helper()
+		attempt := &models.FailedScrapeAttempt{
+			ResortURL: url, ErrorMessage: "503", ErrorClass: models.ScrapeErrorTransient,
+			Attempts: 1, FailedAt: now.Add(-2 * time.Hour), NextRetryAt: &next,
+		}
+		if err := w.SaveScrapeFailure(ctx, attempt); err != nil {
+			t.Fatalf("SaveScrapeFailure(%s) error = %v", url, err)
+		}
+		return attempt
+	}
+	schedule("https://example.com/later", now.Add(time.Hour))
+	recent := schedule("https://example.com/recent", now.Add(-time.Minute))
+	early := schedule("https://example.com/early", now.Add(-time.Hour))
+
+	urls := func(attempts []models.FailedScrapeAttempt) string {
+		parts := make([]string, len(attempts))
+		for i, a := range attempts {
+			parts[i] = fmt.Sprintf("%s#%d", strings.TrimPrefix(a.ResortURL, "https://example.com/"), a.Attempts)
+		}
+		return strings.Join(parts, " ")
+	}
+	pending, err = w.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil {
+		t.Fatalf("GetPendingFailedScrapeAttempts() error = %v", err)
+	}
+	if got, want := urls(pending), "early#1 recent#1"; got != want {
+		t.Fatalf("GetPendingFailedScrapeAttempts() = %s, want %s", got, want)
+	}
+	if !pending[0].NextRetryAt.Equal(*early.NextRetryAt) || pending[0].ErrorClass != models.ScrapeErrorTransient {
+		t.Fatalf("GetPendingFailedScrapeAttempts()[0] = %+v", pending[0])
+	}
+
+	// Rescheduling into the future and dead-lettering both stop an attempt
+	// from being due.
+	early.Attempts, early.ErrorClass, early.ErrorMessage = 2, models.ScrapeErrorParse, "no table"
+	next := now.Add(time.Hour)
+	early.FailedAt, early.NextRetryAt = now, &next
+	if err := w.SaveScrapeFailure(ctx, early); err != nil {
+		t.Fatalf("SaveScrapeFailure(reschedule) error = %v", err)
+	}
+	recent.Attempts, recent.ErrorClass, recent.DeadLettered, recent.NextRetryAt = 2, models.ScrapeErrorNotFound, true, nil
+	if err := w.SaveScrapeFailure(ctx, recent); err != nil {
+		t.Fatalf("SaveScrapeFailure(dead letter) error = %v", err)
+	}
+	if err := w.SaveScrapeFailure(ctx, &models.FailedScrapeAttempt{ID: "missing", FailedAt: now}); err == nil {
+		t.Fatal("SaveScrapeFailure(missing) expected error")
+	}
+
+	pending, err = w.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("GetPendingFailedScrapeAttempts() after rescheduling = %+v, %v", pending, err)
+	}
+	dead, err := w.GetDeadLetteredScrapeAttempts(ctx)
+	if err != nil {
+		t.Fatalf("GetDeadLetteredScrapeAttempts() error = %v", err)
+	}
+	if got, want := urls(dead), "recent#2"; got != want || dead[0].NextRetryAt != nil || dead[0].ErrorClass != models.ScrapeErrorNotFound {
+		t.Fatalf("GetDeadLetteredScrapeAttempts() = %+v, want %s", dead, want)
+	}
+}
+
+func testResortSearch(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	for _, r := range []models.Resort{
+		{Slug: "happo", Name: "Hakuba Happo-One", Prefecture: "nagano", Region: "hakuba", TopElevationM: intPtr(1831), VerticalM: intPtr(1071), NumCourses: intPtr(16), SteepestCourseDeg: floatPtr(35)},
+		{Slug: "goryu", Name: "Hakuba Goryu", Prefecture: "nagano", Region: "hakuba", TopElevationM: intPtr(1676), VerticalM: intPtr(926), NumCourses: intPtr(17), SteepestCourseDeg: floatPtr(30)},
+		{Slug: "shiga", Name: "Shiga Kogen", Prefecture: "nagano", Region: "shiga", TopElevationM: intPtr(2307), VerticalM: intPtr(1000), NumCourses: intPtr(80)},
+		{Slug: "niseko", Name: "ニセコ", Prefecture: "hokkaido", Region: "niseko", TopElevationM: intPtr(1200), VerticalM: intPtr(940), NumCourses: intPtr(61)},
+		{Slug: "small", Name: "Small Hill", Prefecture: "hokkaido", Region: "sapporo"},
+	} {
+		saveResort(t, w, r)
+	}
+
+	search := func(q repository.ResortQuery) repository.ResortPage {
+		t.Helper()
+		page, err := w.SearchResorts(ctx, q)
+		if err != nil {
+			t.Fatalf("SearchResorts(%+v) error = %v", q, err)
+		}
+		return page
+	}
+	slugs := func(page repository.ResortPage) string {
+		var s []string
+		for _, r := range page.Resorts {
+			s = append(s, r.Slug)
+		}
+		return strings.Join(s, ",")
+	}
+
+	for _, tt := range []struct {
+		name string
+		q    repository.ResortQuery
+		want string
+	}{
+		{"all by name", repository.ResortQuery{}, "goryu,happo,shiga,small,niseko"},
+		{"romaji name", repository.ResortQuery{Name: "happou"}, "happo"},
+		{"kana name", repository.ResortQuery{Name: "ハッポー"}, "happo"},
+		{"romaji query for kana name", repository.ResortQuery{Name: "Niseko"}, "niseko"},
+		{"hepburn and kunrei", repository.ResortQuery{Name: "siga"}, "shiga"},
+		{"prefecture", repository.ResortQuery{Prefecture: "hokkaido"}, "small,niseko"},
+		{"region", repository.ResortQuery{Region: "hakuba"}, "goryu,happo"},
+		{"top elevation range", repository.ResortQuery{TopElevationM: repository.IntRange{Min: 1500, Max: 2000}}, "goryu,happo"},
+		{"vertical min", repository.ResortQuery{VerticalM: repository.IntRange{Min: 950}}, "happo,shiga"},
+		{"courses max", repository.ResortQuery{NumCourses: repository.IntRange{Max: 20}}, "goryu,happo"},
+		{"steepest", repository.ResortQuery{SteepestCourseDeg: repository.FloatRange{Min: 32}}, "happo"},
+		{"vertical descending, unknown last", repository.ResortQuery{Sort: repository.SortByVertical, Descending: true}, "happo,shiga,niseko,goryu,small"},
+		{"vertical ascending, unknown last", repository.ResortQuery{Sort: repository.SortByVertical}, "goryu,niseko,shiga,happo,small"},
+	} {
+		if got := slugs(search(tt.q)); got != tt.want {
+			t.Errorf("%s: SearchResorts() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Paging with cursors visits every match once, in order.
+	for _, q := range []repository.ResortQuery{
+		{Sort: repository.SortByVertical, Descending: true, Limit: 2},
+		{Sort: repository.SortBySteepestCourse, Limit: 2},
+		{Name: "hakuba", Limit: 1},
+		{Limit: 3},
+	} {
+		full := q
+		full.Limit = repository.MaxResortPageSize
+		want := slugs(search(full))
+
+		var got []string
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("SearchResorts(%+v) did not finish paging", q)
+			}
+			page := search(q)
+			if len(page.Resorts) > q.Limit {
+				t.Fatalf("SearchResorts(%+v) returned %d resorts", q, len(page.Resorts))
+			}
+			if s := slugs(page); s != "" {
+				got = append(got, s)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("paged SearchResorts() = %s, want %s", strings.Join(got, ","), want)
+		}
+	}
+
+	page := search(repository.ResortQuery{Limit: 2})
+	for _, bad := range []repository.ResortQuery{
+		{Limit: -1},
+		{Limit: repository.MaxResortPageSize + 1},
+		{VerticalM: repository.IntRange{Min: 10, Max: 5}},
+		{Cursor: "not-a-cursor"},
+		{Cursor: page.NextCursor, Sort: repository.SortByVertical},
+	} {
+		if _, err := w.SearchResorts(ctx, bad); !errors.Is(err, repository.ErrInvalidResortQuery) {
+			t.Errorf("SearchResorts(%+v) error = %v, want ErrInvalidResortQuery", bad, err)
+		}
+	}
+}
+
+func testResortGeo(t *testing.T, w repository.Writer) {
+	ctx := context.Background()
+
+	at := func(slug, name, prefecture string, lat, lon float64) models.Resort {
+		return models.Resort{Slug: slug, Name: name, Prefecture: prefecture, Latitude: floatPtr(lat), Longitude: floatPtr(lon)}
+	}
+	for _, r := range []models.Resort{
+		at("happo", "Happo-One", "nagano", 36.70, 137.83),
+		at("goryu", "Goryu", "nagano", 36.67, 137.83),
+		at("shiga", "Shiga Kogen", "nagano", 36.70, 138.51),
+		at("niseko", "Niseko", "hokkaido", 42.86, 140.70),
+		at("east", "East", "fiji", -17.7, 179.9),
+		at("west", "West", "fiji", -17.7, -179.9),
+		{Slug: "nowhere", Name: "Nowhere", Prefecture: "nagano"},
+	} {
+		saveResort(t, w, r)
+	}
+
+	got, err := w.GetResortBySlug(ctx, "happo")
+	if err != nil {
+		t.Fatalf("GetResortBySlug() error = %v", err)
+	}
+	if got.Latitude == nil || *got.Latitude != 36.70 || got.Longitude == nil || *got.Longitude != 137.83 {
+		t.Fatalf("GetResortBySlug() coordinates = %v, %v", got.Latitude, got.Longitude)
+	}
+
+	near := func(lat, lon, radius float64, limit int) string {
+		t.Helper()
+		results, err := w.GetResortsNear(ctx, lat, lon, radius, limit)
+		if err != nil {
+			t.Fatalf("GetResortsNear(%v, %v, %v, %d) error = %v", lat, lon, radius, limit, err)
+		}
+		var slugs []string
+		for i, r := range results {
+			if r.DistanceKM > radius || (i > 0 && r.DistanceKM < results[i-1].DistanceKM) {
+				t.Fatalf("GetResortsNear() distances out of order or range: %+v", results)
+			}
+			slugs = append(slugs, r.Resort.Slug)
+		}
+		return strings.Join(slugs, ",")
+	}
+	for _, tt := range []struct {
+		name             string
+		lat, lon, radius float64
+		limit            int
+		want             string
+	}{
+		{"hakuba station", 36.698, 137.862, 10, 10, "happo,goryu"},
+		{"wider radius", 36.698, 137.862, 100, 10, "happo,goryu,shiga"},
+		{"limit", 36.698, 137.862, 100, 1, "happo"},
+		{"nothing nearby", 0, 0, 100, 10, ""},
+		{"across the antimeridian", -17.7, 180, 50, 10, "east,west"},
+		{"continental radius", 36.698, 137.862, 2000, 10, "happo,goryu,shiga,niseko"},
+	} {
+		if got := near(tt.lat, tt.lon, tt.radius, tt.limit); got != tt.want {
+			t.Errorf("%s: GetResortsNear() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	bbox := func(minLat, minLon, maxLat, maxLon float64) string {
+		t.Helper()
+		resorts, err := w.GetResortsInBBox(ctx, minLat, minLon, maxLat, maxLon)
+		if err != nil {
+			t.Fatalf("GetResortsInBBox() error = %v", err)
+		}
+		var slugs []string
+		for _, r := range resorts {
+			slugs = append(slugs, r.Slug)
+		}
+		return strings.Join(slugs, ",")
+	}
+	if got := bbox(36, 137, 37, 139); got != "goryu,happo,shiga" {
+		t.Errorf("GetResortsInBBox(alps) = %s", got)
+	}
+	if got := bbox(36.70, 137.83, 36.70, 137.83); got != "happo" {
+		t.Errorf("GetResortsInBBox(point) = %s", got)
+	}
+	if got := bbox(-20, 179, -15, -179); got != "east,west" {
+		t.Errorf("GetResortsInBBox(antimeridian) = %s", got)
+	}
+	if got := bbox(-90, -180, 90, 180); got != "east,west,niseko,goryu,happo,shiga" {
+		t.Errorf("GetResortsInBBox(world) = %s", got)
+	}
+
+	if _, err := w.GetResortsNear(ctx, 91, 0, 10, 10); err == nil {
+		t.Error("GetResortsNear(lat 91) expected error")
+	}
+	if _, err := w.GetResortsNear(ctx, 0, 0, 0, 10); err == nil {
+		t.Error("GetResortsNear(radius 0) expected error")
+	}
+	if _, err := w.GetResortsNear(ctx, 0, 0, 10, 0); err == nil {
+		t.Error("GetResortsNear(limit 0) expected error")
+	}
+	if _, err := w.GetResortsInBBox(ctx, 10, 0, 5, 1); err == nil {
+		t.Error("GetResortsInBBox(min > max) expected error")
+	}
+	if err := w.SaveResort(ctx, &models.Resort{Slug: "half", Name: "Half", Latitude: floatPtr(36)}); err == nil {
+		t.Error("SaveResort(latitude only) expected error")
+	}
+	if err := w.SaveResort(ctx, &models.Resort{Slug: "far", Name: "Far", Latitude: floatPtr(36), Longitude: floatPtr(200)}); err == nil {
+		t.Error("SaveResort(longitude 200) expected error")
+	}
+}
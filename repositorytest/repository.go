@@ -0,0 +1,934 @@
+// Package repositorytest provides an in-memory implementation of
+// repository.Reader and repository.Writer for tests of code that depends on
+// them, and a conformance suite that any implementation can be checked against.
+package repositorytest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/internal/geo"
+	"github.com/amaumene/snowfinder_common/internal/regions"
+	"github.com/amaumene/snowfinder_common/internal/resortidentity"
+	"github.com/amaumene/snowfinder_common/internal/resortsearch"
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+	"github.com/google/uuid"
+)
+
+// seasonStartMonth matches the repository package: seasons run July to June.
+const seasonStartMonth = time.July
+
+type resortDay struct {
+	resortID string
+	date     string // "YYYY-MM-DD"
+}
+
+// Repository is a thread-safe in-memory repository.Writer. It mirrors the
+// SQLite implementation: SaveResort scopes colliding slugs, batch saves upsert
+// by resort and date, and single-row lookups wrap sql.ErrNoRows.
+//
+// The zero value is not usable; create one with New.
+type Repository struct {
+	mu       sync.RWMutex
+	now      func() time.Time
+	resorts  map[string]models.Resort // by ID
+	snowfall map[resortDay]int
+	depth    map[resortDay]int
+	peaks    map[string][]models.PeakPeriod // by resort ID, ordered by rank
+	attempts []models.FailedScrapeAttempt
+}
+
+var _ repository.Writer = (*Repository)(nil)
+
+// New returns an empty in-memory repository.
+func New() *Repository {
+	return &Repository{
+		now:      time.Now,
+		resorts:  make(map[string]models.Resort),
+		snowfall: make(map[resortDay]int),
+		depth:    make(map[resortDay]int),
+		peaks:    make(map[string][]models.PeakPeriod),
+	}
+}
+
+// timestamp returns the current time at the precision the database stores.
+func (r *Repository) timestamp() time.Time {
+	return r.now().UTC().Truncate(time.Second)
+}
+
+// GetResortBySlug returns the resort with the given URL slug.
+// Returns sql.ErrNoRows (wrapped) if no matching resort exists.
+func (r *Repository) GetResortBySlug(ctx context.Context, slug string) (*models.Resort, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("get resort by slug: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resort, ok := r.resortBySlug(slug)
+	if !ok {
+		return nil, fmt.Errorf("get resort by slug: %w", sql.ErrNoRows)
+	}
+	return &resort, nil
+}
+
+// GetResortByID returns the resort with the given ID.
+// Returns sql.ErrNoRows (wrapped) if no matching resort exists.
+func (r *Repository) GetResortByID(ctx context.Context, id string) (*models.Resort, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("get resort by id: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resort, ok := r.resorts[id]
+	if !ok {
+		return nil, fmt.Errorf("get resort by id: %w", sql.ErrNoRows)
+	}
+	resort = cloneResort(resort)
+	return &resort, nil
+}
+
+// GetAllResorts returns every resort ordered by prefecture and name.
+func (r *Repository) GetAllResorts(ctx context.Context) ([]models.Resort, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query resorts: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sortedResorts(), nil
+}
+
+// SearchResorts returns one page of resorts matching q, using the same
+// filtering, ordering and cursors as the SQL repository.
+func (r *Repository) SearchResorts(ctx context.Context, q repository.ResortQuery) (repository.ResortPage, error) {
+	if err := ctx.Err(); err != nil {
+		return repository.ResortPage{}, fmt.Errorf("search resorts: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	page, err := resortsearch.Search(r.sortedResorts(), q)
+	if err != nil {
+		return repository.ResortPage{}, fmt.Errorf("search resorts: %w", err)
+	}
+	return page, nil
+}
+
+// GetResortsNear returns up to limit resorts within radiusKM of lat/lon,
+// nearest first.
+func (r *Repository) GetResortsNear(ctx context.Context, lat, lon, radiusKM float64, limit int) ([]models.ResortDistance, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query resorts in area: %w", err)
+	}
+	if err := repository.ValidateCoordinates(lat, lon); err != nil {
+		return nil, err
+	}
+	if !(radiusKM > 0) {
+		return nil, fmt.Errorf("radius must be positive: %v", radiusKM)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive: %d", limit)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return geo.Nearest(r.sortedResorts(), lat, lon, radiusKM, limit), nil
+}
+
+// GetResortsInBBox returns the resorts inside the bounding box ordered by
+// prefecture and name.
+func (r *Repository) GetResortsInBBox(ctx context.Context, minLat, minLon, maxLat, maxLon float64) ([]models.Resort, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query resorts in area: %w", err)
+	}
+	box, err := geo.NewBox(minLat, minLon, maxLat, maxLon)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resorts := []models.Resort{}
+	for _, resort := range r.sortedResorts() {
+		if resort.Latitude != nil && resort.Longitude != nil && box.Contains(*resort.Latitude, *resort.Longitude) {
+			resorts = append(resorts, resort)
+		}
+	}
+	return resorts, nil
+}
+
+// GetSnowiestResorts ranks resorts by average snowfall over a calendar range,
+// with the same two input modes as repository.Reader.
+func (r *Repository) GetSnowiestResorts(ctx context.Context, startDate, endDate, prefecture string, limit int) ([]models.WeeklyResortStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query snowiest resorts: %w", err)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	startDOY, endDOY, startMonth, err := snowiestRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	crossYear := startDOY > endDOY
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type resortYear struct {
+		resortID string
+		year     int
+	}
+	totals := make(map[resortYear]int)
+	for key, cm := range r.snowfall {
+		date := parseDay(key.date)
+		doy := date.YearDay()
+		year := date.Year()
+		if crossYear {
+			if doy < startDOY && doy > endDOY {
+				continue
+			}
+			year = seasonYear(date, time.Month(startMonth))
+		} else if doy < startDOY || doy > endDOY {
+			continue
+		}
+		totals[resortYear{key.resortID, year}] += cm
+	}
+
+	sums := make(map[string]int)
+	years := make(map[string]int)
+	for key, total := range totals {
+		sums[key.resortID] += total
+		years[key.resortID]++
+	}
+
+	type ranked struct {
+		stat models.WeeklyResortStats
+		avg  float64
+	}
+	var results []ranked
+	for resortID, sum := range sums {
+		resort, ok := r.resorts[resortID]
+		if !ok || (prefecture != "" && resort.Prefecture != prefecture) {
+			continue
+		}
+		avg := float64(sum) / float64(years[resortID])
+		rounded := int(math.Round(avg))
+		yearsWithData := years[resortID]
+		resort = cloneResort(resort)
+		results = append(results, ranked{
+			avg: avg,
+			stat: models.WeeklyResortStats{
+				ResortID:        resort.ID,
+				Name:            resort.Name,
+				Prefecture:      resort.Prefecture,
+				TotalSnowfall:   &rounded,
+				YearsWithData:   &yearsWithData,
+				TopElevationM:   resort.TopElevationM,
+				BaseElevationM:  resort.BaseElevationM,
+				VerticalM:       resort.VerticalM,
+				NumCourses:      resort.NumCourses,
+				LongestCourseKM: resort.LongestCourseKM,
+			},
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].avg != results[j].avg {
+			return results[i].avg > results[j].avg
+		}
+		return results[i].stat.ResortID < results[j].stat.ResortID
+	})
+
+	stats := []models.WeeklyResortStats{}
+	for i := 0; i < len(results) && i < limit; i++ {
+		stats = append(stats, results[i].stat)
+	}
+	return stats, nil
+}
+
+// snowiestRange converts GetSnowiestResorts' date arguments to day-of-year
+// bounds and the month the range starts in.
+func snowiestRange(startDate, endDate string) (startDOY, endDOY, startMonth int, err error) {
+	if endDate == "" {
+		// Week mode: startDate is "YYYY-MM-DD"
+		start, err := time.Parse("2006-01-02", startDate)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("parse start date: %w", err)
+		}
+		start = time.Date(2000, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		return start.YearDay(), start.AddDate(0, 0, 6).YearDay(), int(start.Month()), nil
+	}
+
+	// Date range mode: both are "MM-DD"
+	start, err := time.Parse("01-02", startDate)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("parse start date: %w", err)
+	}
+	if _, err := time.Parse("01-02", endDate); err != nil {
+		return 0, 0, 0, fmt.Errorf("parse end date: %w", err)
+	}
+	if startDOY, err = mmddToDOY(startDate); err != nil {
+		return 0, 0, 0, fmt.Errorf("parse start date: %w", err)
+	}
+	if endDOY, err = mmddToDOY(endDate); err != nil {
+		return 0, 0, 0, fmt.Errorf("parse end date: %w", err)
+	}
+	return startDOY, endDOY, int(start.Month()), nil
+}
+
+// GetAllResortsWithPeaks returns all resorts that have at least one peak
+// period, ordered by prefecture, resort name, and peak rank.
+func (r *Repository) GetAllResortsWithPeaks(ctx context.Context) ([]models.ResortWithPeaks, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query resorts with peaks: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := []models.ResortWithPeaks{}
+	for _, resort := range r.sortedResorts() {
+		peaks := r.peaks[resort.ID]
+		if len(peaks) == 0 {
+			continue
+		}
+		results = append(results, models.ResortWithPeaks{
+			Resort: resort,
+			Peaks:  append([]models.PeakPeriod{}, peaks...),
+		})
+	}
+	return results, nil
+}
+
+// GetPeakPeriodsForResort returns all peak periods for the given resort,
+// ordered by peak rank ascending.
+func (r *Repository) GetPeakPeriodsForResort(ctx context.Context, resortID string) ([]models.PeakPeriod, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query peak periods: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	peaks := r.peaks[resortID]
+	if len(peaks) == 0 {
+		return nil, nil
+	}
+	return append([]models.PeakPeriod(nil), peaks...), nil
+}
+
+// GetPendingFailedScrapeAttempts returns the failed scrape attempts that are
+// due for a retry, ordered by next retry time ascending.
+func (r *Repository) GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query failed scrape attempts: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.timestamp()
+	var pending []models.FailedScrapeAttempt
+	for _, attempt := range r.attempts {
+		if !attempt.Retried && !attempt.DeadLettered && attempt.NextRetryAt != nil && !attempt.NextRetryAt.After(now) {
+			pending = append(pending, cloneAttempt(attempt))
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		if !pending[i].NextRetryAt.Equal(*pending[j].NextRetryAt) {
+			return pending[i].NextRetryAt.Before(*pending[j].NextRetryAt)
+		}
+		return pending[i].FailedAt.Before(pending[j].FailedAt)
+	})
+	return pending, nil
+}
+
+// GetOpenFailedScrapeAttempt returns the attempt for resortURL that is
+// neither retried nor dead-lettered, due or not.
+// Returns sql.ErrNoRows (wrapped) if the URL has no open attempt.
+func (r *Repository) GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query failed scrape attempts: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.openAttempt(resortURL)
+	if i < 0 {
+		return nil, fmt.Errorf("get open failed scrape attempt for %s: %w", resortURL, sql.ErrNoRows)
+	}
+	attempt := cloneAttempt(r.attempts[i])
+	return &attempt, nil
+}
+
+// GetDeadLetteredScrapeAttempts returns the dead-lettered scrape attempts,
+// ordered by failure time ascending.
+func (r *Repository) GetDeadLetteredScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query failed scrape attempts: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var dead []models.FailedScrapeAttempt
+	for _, attempt := range r.attempts {
+		if attempt.DeadLettered {
+			dead = append(dead, cloneAttempt(attempt))
+		}
+	}
+	sort.SliceStable(dead, func(i, j int) bool {
+		return dead[i].FailedAt.Before(dead[j].FailedAt)
+	})
+	return dead, nil
+}
+
+// GetSnowDepthHistory returns a resort's snow depth readings between from and
+// to (inclusive, compared by calendar date), ordered by date ascending.
+func (r *Repository) GetSnowDepthHistory(ctx context.Context, resortID string, from, to time.Time) ([]models.SnowDepthReading, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query snow depth history: %w", err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	readings := []models.SnowDepthReading{}
+	for _, key := range r.days(r.depth, resortID, from.Format("2006-01-02"), to.Format("2006-01-02")) {
+		readings = append(readings, models.SnowDepthReading{ResortID: resortID, Date: parseDay(key.date), DepthCM: r.depth[key]})
+	}
+	return readings, nil
+}
+
+// GetLatestSnowDepth returns the most recent snow depth reading for each of
+// the given resorts, keyed by resort ID. Resorts without readings are omitted.
+func (r *Repository) GetLatestSnowDepth(ctx context.Context, resortIDs []string) (map[string]models.SnowDepthReading, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query latest snow depth: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := make(map[string]models.SnowDepthReading, len(resortIDs))
+	for _, resortID := range resortIDs {
+		keys := r.days(r.depth, resortID, "", "")
+		if len(keys) == 0 {
+			continue
+		}
+		key := keys[len(keys)-1]
+		latest[resortID] = models.SnowDepthReading{ResortID: resortID, Date: parseDay(key.date), DepthCM: r.depth[key]}
+	}
+	return latest, nil
+}
+
+// GetSeasonMaxSnowDepth returns, for each of the given resorts, the deepest
+// reading from the start of the season containing asOf up to asOf (inclusive).
+// Ties go to the most recent reading.
+func (r *Repository) GetSeasonMaxSnowDepth(ctx context.Context, resortIDs []string, asOf time.Time) (map[string]models.SnowDepthReading, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query season max snow depth: %w", err)
+	}
+	from := time.Date(seasonYear(asOf, seasonStartMonth), seasonStartMonth, 1, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	to := asOf.Format("2006-01-02")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	maxDepths := make(map[string]models.SnowDepthReading, len(resortIDs))
+	for _, resortID := range resortIDs {
+		for _, key := range r.days(r.depth, resortID, from, to) {
+			// days are ascending, so >= lets later readings win ties
+			if best, ok := maxDepths[resortID]; !ok || r.depth[key] >= best.DepthCM {
+				maxDepths[resortID] = models.SnowDepthReading{ResortID: resortID, Date: parseDay(key.date), DepthCM: r.depth[key]}
+			}
+		}
+	}
+	return maxDepths, nil
+}
+
+// GetDailySnowfall returns a resort's daily snowfall between from and to
+// (inclusive, compared by calendar date), ordered by date ascending.
+func (r *Repository) GetDailySnowfall(ctx context.Context, resortID string, from, to time.Time) ([]models.DailySnowfall, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query daily snowfall: %w", err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snowfalls := []models.DailySnowfall{}
+	for _, key := range r.days(r.snowfall, resortID, from.Format("2006-01-02"), to.Format("2006-01-02")) {
+		snowfalls = append(snowfalls, models.DailySnowfall{ResortID: resortID, Date: parseDay(key.date), SnowfallCM: r.snowfall[key]})
+	}
+	return snowfalls, nil
+}
+
+// GetSeasonSnowfallTotals returns a resort's total snowfall for its most
+// recent seasons (newest first), ranked against each other. A non-empty
+// throughMMDD limits each season to days up to that calendar day.
+func (r *Repository) GetSeasonSnowfallTotals(ctx context.Context, resortID string, seasons int, throughMMDD string) ([]models.SeasonSnowfallTotal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query season snowfall totals: %w", err)
+	}
+	if seasons <= 0 {
+		return nil, fmt.Errorf("seasons must be positive: %d", seasons)
+	}
+	var through time.Time
+	if throughMMDD != "" {
+		var err error
+		if through, err = time.Parse("01-02", throughMMDD); err != nil {
+			return nil, fmt.Errorf("parse through date: %w", err)
+		}
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byYear := make(map[int]*models.SeasonSnowfallTotal)
+	for _, key := range r.days(r.snowfall, resortID, "", "") {
+		date := parseDay(key.date)
+		if throughMMDD != "" && !withinSeasonToDate(date, through.Month(), key.date[5:], throughMMDD) {
+			continue
+		}
+		year := seasonYear(date, seasonStartMonth)
+		total, ok := byYear[year]
+		if !ok {
+			total = &models.SeasonSnowfallTotal{ResortID: resortID, Season: seasonLabel(year), StartYear: year}
+			byYear[year] = total
+		}
+		total.TotalSnowfallCM += r.snowfall[key]
+		total.DaysWithData++
+	}
+
+	totals := []models.SeasonSnowfallTotal{}
+	for _, total := range byYear {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].StartYear > totals[j].StartYear })
+	if len(totals) > seasons {
+		totals = totals[:seasons]
+	}
+	// RANK(): ties share a rank and leave a gap after them
+	for i := range totals {
+		totals[i].Rank = 1
+		for j := range totals {
+			if totals[j].TotalSnowfallCM > totals[i].TotalSnowfallCM {
+				totals[i].Rank++
+			}
+		}
+	}
+	return totals, nil
+}
+
+// withinSeasonToDate reports whether a day ("MM-DD" monthDay) falls between
+// the start of its season and the through cutoff.
+func withinSeasonToDate(date time.Time, throughMonth time.Month, monthDay, throughMMDD string) bool {
+	if throughMonth >= seasonStartMonth {
+		return date.Month() >= seasonStartMonth && monthDay <= throughMMDD
+	}
+	return date.Month() >= seasonStartMonth || monthDay <= throughMMDD
+}
+
+// GetRegionSummaries returns the snowfall recorded between from and to
+// (inclusive) per prefecture or region, ordered by prefecture and region.
+func (r *Repository) GetRegionSummaries(ctx context.Context, from, to time.Time, groupBy repository.RegionGrouping) ([]models.RegionSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query region snowfall: %w", err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date %s is before from date %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	if groupBy != repository.GroupByPrefecture && groupBy != repository.GroupByRegion {
+		return nil, fmt.Errorf("unknown region grouping: %d", groupBy)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	first, last := from.Format("2006-01-02"), to.Format("2006-01-02")
+	totals := make(map[string]int)
+	for key, cm := range r.snowfall {
+		if key.date >= first && key.date <= last {
+			totals[key.resortID] += cm
+		}
+	}
+	return regions.Summarize(r.sortedResorts(), totals, groupBy == repository.GroupByRegion), nil
+}
+
+// GetObservedSnowfall returns observed daily snowfall between the from and to
+// dates (inclusive, "YYYY-MM-DD"), keyed by resort ID and then by date.
+func (r *Repository) GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query observed snowfall: %w", err)
+	}
+	if _, err := time.Parse("2006-01-02", from); err != nil {
+		return nil, fmt.Errorf("parse from date: %w", err)
+	}
+	if _, err := time.Parse("2006-01-02", to); err != nil {
+		return nil, fmt.Errorf("parse to date: %w", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	observed := make(map[string]map[string]int)
+	for key, cm := range r.snowfall {
+		if key.date < from || key.date > to {
+			continue
+		}
+		if observed[key.resortID] == nil {
+			observed[key.resortID] = make(map[string]int)
+		}
+		observed[key.resortID][key.date] = cm
+	}
+	return observed, nil
+}
+
+// SaveResort inserts or updates a resort, keyed by its resolved slug. Like the
+// SQL implementation it keeps stored coordinates when resort has none, and sets
+// resort.ID and resort.Slug to the persisted values.
+func (r *Repository) SaveResort(ctx context.Context, resort *models.Resort) error {
+	if resort == nil {
+		return errors.New("nil resort")
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save resort: %w", err)
+	}
+	if err := geo.ValidateResort(resort); err != nil {
+		return fmt.Errorf("save resort: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	persistedID, slug, err := resortidentity.ResolveSlug(resort, func(slug string) (*models.Resort, error) {
+		if existing, ok := r.resortBySlug(slug); ok {
+			return &existing, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("resolve resort identity: %w", err)
+	}
+
+	id := persistedID
+	if id == "" {
+		id = resort.ID
+		if id == "" {
+			id = uuid.New().String()
+		}
+		if _, taken := r.resorts[id]; taken {
+			return fmt.Errorf("save resort: resort ID %q already exists", id)
+		}
+	}
+
+	stored := cloneResort(*resort)
+	stored.ID = id
+	stored.Slug = slug
+	if existing, ok := r.resorts[id]; ok && stored.Latitude == nil {
+		stored.Latitude, stored.Longitude = existing.Latitude, existing.Longitude
+	}
+	stored.LastUpdated = r.timestamp()
+	r.resorts[id] = stored
+
+	resort.ID = id
+	resort.Slug = slug
+	return nil
+}
+
+// SaveSnowDepthReadings upserts a batch of snow depth readings.
+func (r *Repository) SaveSnowDepthReadings(ctx context.Context, readings []models.SnowDepthReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save reading: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reading := range readings {
+		r.depth[resortDay{reading.ResortID, reading.Date.Format("2006-01-02")}] = reading.DepthCM
+	}
+	return nil
+}
+
+// SaveDailySnowfall upserts a batch of daily snowfall records.
+func (r *Repository) SaveDailySnowfall(ctx context.Context, snowfalls []models.DailySnowfall) error {
+	if len(snowfalls) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save snowfall: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, sf := range snowfalls {
+		r.snowfall[resortDay{sf.ResortID, sf.Date.Format("2006-01-02")}] = sf.SnowfallCM
+	}
+	return nil
+}
+
+// SaveFailedScrapeAttempt records a transient failed scrape attempt for the
+// given URL, due for a retry immediately, incrementing the count of the URL's
+// open attempt if it has one.
+func (r *Repository) SaveFailedScrapeAttempt(ctx context.Context, resortURL, errorMessage string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save failed scrape attempt: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.timestamp()
+	if i := r.openAttempt(resortURL); i >= 0 {
+		attempt := &r.attempts[i]
+		attempt.ErrorMessage = errorMessage
+		attempt.ErrorClass = models.ScrapeErrorTransient
+		attempt.Attempts++
+		attempt.FailedAt = now
+		attempt.NextRetryAt = &now
+		return nil
+	}
+	r.attempts = append(r.attempts, models.FailedScrapeAttempt{
+		ID:           uuid.New().String(),
+		ResortURL:    resortURL,
+		ErrorMessage: errorMessage,
+		ErrorClass:   models.ScrapeErrorTransient,
+		Attempts:     1,
+		FailedAt:     now,
+		NextRetryAt:  &now,
+	})
+	return nil
+}
+
+// SaveScrapeFailure inserts an attempt without an ID, giving it one, or
+// updates the schedule of the pending attempt with its ID. Like the unique
+// index of the database, it refuses a second open attempt for a URL.
+func (r *Repository) SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("save failed scrape attempt: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Times are stored at the precision of the database.
+	stored := models.FailedScrapeAttempt{
+		ResortURL:    attempt.ResortURL,
+		ErrorMessage: attempt.ErrorMessage,
+		ErrorClass:   attempt.ErrorClass,
+		Attempts:     attempt.Attempts,
+		FailedAt:     attempt.FailedAt.UTC().Truncate(time.Second),
+		DeadLettered: attempt.DeadLettered,
+	}
+	if attempt.NextRetryAt != nil {
+		next := attempt.NextRetryAt.UTC().Truncate(time.Second)
+		stored.NextRetryAt = &next
+	}
+
+	if attempt.ID == "" {
+		if r.openAttempt(attempt.ResortURL) >= 0 {
+			return fmt.Errorf("save failed scrape attempt: UNIQUE constraint failed: failed_scrape_attempts.resort_url")
+		}
+		stored.ID = uuid.New().String()
+		r.attempts = append(r.attempts, stored)
+		attempt.ID = stored.ID
+		return nil
+	}
+	for i := range r.attempts {
+		if r.attempts[i].ID == attempt.ID && !r.attempts[i].Retried {
+			stored.ID = attempt.ID
+			stored.ResortURL = r.attempts[i].ResortURL
+			r.attempts[i] = stored
+			return nil
+		}
+	}
+	return fmt.Errorf("update failed scrape attempt %s: affected 0 rows, want 1", attempt.ID)
+}
+
+// openAttempt returns the index of the attempt for resortURL that is neither
+// retried nor dead-lettered, or -1. The caller must hold r.mu.
+func (r *Repository) openAttempt(resortURL string) int {
+	for i, attempt := range r.attempts {
+		if attempt.ResortURL == resortURL && !attempt.Retried && !attempt.DeadLettered {
+			return i
+		}
+	}
+	return -1
+}
+
+// MarkFailedAttemptRetried marks the failed scrape attempt with the given ID
+// as retried. Returns an error if no attempt has that ID.
+func (r *Repository) MarkFailedAttemptRetried(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("mark failed attempt retried: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.attempts {
+		if r.attempts[i].ID == id {
+			retriedAt := r.timestamp()
+			r.attempts[i].Retried = true
+			r.attempts[i].RetriedAt = &retriedAt
+			r.attempts[i].NextRetryAt = nil
+			return nil
+		}
+	}
+	return errors.New("mark failed attempt retried: affected 0 rows, want 1")
+}
+
+// ReplacePeakPeriods atomically replaces all peak periods for a resort.
+// Peaks with an empty ID are assigned a new UUID; StartDate, EndDate and
+// CenterDate must be "MM-DD". An empty slice clears the resort's peaks.
+func (r *Repository) ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	replaced := make([]models.PeakPeriod, 0, len(peaks))
+	ranks := make(map[int]bool, len(peaks))
+	for _, peak := range peaks {
+		for _, field := range []struct{ name, mmdd string }{
+			{"start", peak.StartDate}, {"end", peak.EndDate}, {"center", peak.CenterDate},
+		} {
+			if _, err := mmddToDOY(field.mmdd); err != nil {
+				return fmt.Errorf("convert %s date: %w", field.name, err)
+			}
+		}
+		if ranks[peak.PeakRank] {
+			return fmt.Errorf("save peak period rank %d: duplicate rank", peak.PeakRank)
+		}
+		ranks[peak.PeakRank] = true
+
+		if peak.ID == "" {
+			peak.ID = uuid.New().String()
+		}
+		if peak.CalculatedAt.IsZero() {
+			peak.CalculatedAt = r.now()
+		}
+		peak.CalculatedAt = peak.CalculatedAt.UTC().Truncate(time.Second)
+		peak.ResortID = resortID
+		replaced = append(replaced, peak)
+	}
+	sort.Slice(replaced, func(i, j int) bool { return replaced[i].PeakRank < replaced[j].PeakRank })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(replaced) == 0 {
+		delete(r.peaks, resortID)
+	} else {
+		r.peaks[resortID] = replaced
+	}
+	return nil
+}
+
+// resortBySlug returns a copy of the resort stored under slug. r.mu must be held.
+func (r *Repository) resortBySlug(slug string) (models.Resort, bool) {
+	for _, resort := range r.resorts {
+		if resort.Slug == slug {
+			return cloneResort(resort), true
+		}
+	}
+	return models.Resort{}, false
+}
+
+// sortedResorts returns copies of all resorts ordered by prefecture and name.
+// r.mu must be held.
+func (r *Repository) sortedResorts() []models.Resort {
+	resorts := make([]models.Resort, 0, len(r.resorts))
+	for _, resort := range r.resorts {
+		resorts = append(resorts, cloneResort(resort))
+	}
+	sort.Slice(resorts, func(i, j int) bool {
+		if resorts[i].Prefecture != resorts[j].Prefecture {
+			return resorts[i].Prefecture < resorts[j].Prefecture
+		}
+		if resorts[i].Name != resorts[j].Name {
+			return resorts[i].Name < resorts[j].Name
+		}
+		return resorts[i].ID < resorts[j].ID
+	})
+	return resorts
+}
+
+// days returns a resort's keys in values with dates between from and to
+// (inclusive, "YYYY-MM-DD"; empty means unbounded), ordered by date.
+// r.mu must be held.
+func (r *Repository) days(values map[resortDay]int, resortID, from, to string) []resortDay {
+	var keys []resortDay
+	for key := range values {
+		if key.resortID != resortID || (from != "" && key.date < from) || (to != "" && key.date > to) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].date < keys[j].date })
+	return keys
+}
+
+// cloneResort copies a resort so callers cannot mutate stored pointer fields.
+func cloneResort(resort models.Resort) models.Resort {
+	resort.TopElevationM = clonePtr(resort.TopElevationM)
+	resort.BaseElevationM = clonePtr(resort.BaseElevationM)
+	resort.VerticalM = clonePtr(resort.VerticalM)
+	resort.NumCourses = clonePtr(resort.NumCourses)
+	resort.LongestCourseKM = clonePtr(resort.LongestCourseKM)
+	resort.SteepestCourseDeg = clonePtr(resort.SteepestCourseDeg)
+	resort.Latitude = clonePtr(resort.Latitude)
+	resort.Longitude = clonePtr(resort.Longitude)
+	return resort
+}
+
+// cloneAttempt copies an attempt so callers cannot mutate stored pointer fields.
+func cloneAttempt(attempt models.FailedScrapeAttempt) models.FailedScrapeAttempt {
+	attempt.NextRetryAt = clonePtr(attempt.NextRetryAt)
+	attempt.RetriedAt = clonePtr(attempt.RetriedAt)
+	return attempt
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// parseDay parses a stored "YYYY-MM-DD" date as UTC midnight, the value the
+// SQLite driver scans DATE columns into.
+func parseDay(date string) time.Time {
+	t, _ := time.Parse("2006-01-02", date)
+	return t
+}
+
+// seasonYear returns the year in which the season containing t starts, for
+// seasons starting in startMonth.
+func seasonYear(t time.Time, startMonth time.Month) int {
+	if t.Month() < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// seasonLabel formats the season starting in startYear as "YYYY-YY".
+func seasonLabel(startYear int) string {
+	return fmt.Sprintf("%d-%02d", startYear, (startYear+1)%100)
+}
+
+// mmddToDOY converts an "MM-DD" string to a day-of-year integer in a leap year.
+func mmddToDOY(mmdd string) (int, error) {
+	t, err := time.Parse("01-02", mmdd)
+	if err != nil {
+		return 0, fmt.Errorf("parse MM-DD %q: %w", mmdd, err)
+	}
+	return time.Date(2000, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).YearDay(), nil
+}
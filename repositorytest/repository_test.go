@@ -0,0 +1,79 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+)
+
+func TestRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	TestWriter(t, func(t *testing.T) repository.Writer { return New() })
+}
+
+func TestRepository_ConcurrentWrites(t *testing.T) {
+	t.Parallel()
+
+	repo := New()
+	ctx := context.Background()
+	resort := &models.Resort{Slug: "a", Name: "A", Prefecture: "nagano"}
+	if err := repo.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			date := day(2025, time.January, 1).AddDate(0, 0, i)
+			if err := repo.SaveDailySnowfall(ctx, []models.DailySnowfall{{ResortID: resort.ID, Date: date, SnowfallCM: 1}}); err != nil {
+				t.Errorf("SaveDailySnowfall() error = %v", err)
+			}
+			if _, err := repo.GetDailySnowfall(ctx, resort.ID, day(2025, time.January, 1), date); err != nil {
+				t.Errorf("GetDailySnowfall() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.GetDailySnowfall(ctx, resort.ID, day(2025, time.January, 1), day(2025, time.December, 31))
+	if err != nil {
+		t.Fatalf("GetDailySnowfall() error = %v", err)
+	}
+	if len(got) != 20 {
+		t.Fatalf("GetDailySnowfall() returned %d days, want 20", len(got))
+	}
+}
+
+func TestRepository_ReturnsCopies(t *testing.T) {
+	t.Parallel()
+
+	repo := New()
+	ctx := context.Background()
+	vertical := 900
+	resort := &models.Resort{Slug: "a", Name: "A", VerticalM: &vertical}
+	if err := repo.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+	vertical = 1
+
+	got, err := repo.GetResortBySlug(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetResortBySlug() error = %v", err)
+	}
+	*got.VerticalM = 2
+
+	again, err := repo.GetResortBySlug(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetResortBySlug() error = %v", err)
+	}
+	if *again.VerticalM != 900 {
+		t.Fatalf("stored VerticalM = %d, want 900", *again.VerticalM)
+	}
+}
@@ -0,0 +1,259 @@
+// Package retry schedules retries of failed scrapes. Failures are classified
+// as transient, parse or not-found errors; transient and parse failures are
+// retried with exponential backoff and jitter until a maximum number of
+// attempts, after which they are dead-lettered.
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// Store is the persistence needed to schedule retries.
+// *repository.WriterRepository satisfies it.
+type Store interface {
+	GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error)
+	GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error)
+	SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error
+	MarkFailedAttemptRetried(ctx context.Context, id string) error
+}
+
+// Policy controls the retry schedule.
+type Policy struct {
+	// BaseDelay is the delay before the first retry; each further retry
+	// doubles it. Defaults to 5 minutes.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. Defaults to 6 hours.
+	MaxDelay time.Duration
+	// Jitter randomises each delay by up to this fraction either way, so
+	// failures from one outage do not all retry at once. It is clamped to
+	// [0, 1]; negative values disable jitter. Defaults to 0.2.
+	Jitter float64
+	// MaxAttempts is the number of failed attempts, including the first,
+	// after which a transient failure is dead-lettered. Defaults to 8.
+	MaxAttempts int
+	// MaxParseAttempts is the same for parse failures, which rarely fix
+	// themselves. Defaults to 3.
+	MaxParseAttempts int
+}
+
+// DefaultPolicy returns the default retry policy.
+func DefaultPolicy() Policy {
+	return Policy{}.withDefaults()
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 5 * time.Minute
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 6 * time.Hour
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 8
+	}
+	if p.MaxParseAttempts <= 0 {
+		p.MaxParseAttempts = 3
+	}
+	return p
+}
+
+// Delay returns how long to wait after the given number of failed attempts.
+// random, in [0, 1), picks the jitter: 0.5 gives the unjittered delay.
+func (p Policy) Delay(attempts int, random float64) time.Duration {
+	p = p.withDefaults()
+	backoff := float64(p.BaseDelay) * math.Pow(2, float64(max(attempts, 1)-1))
+	backoff = math.Min(backoff, float64(p.MaxDelay))
+	jitter := math.Max(0, math.Min(p.Jitter, 1))
+	backoff *= 1 + jitter*(2*random-1)
+	return time.Duration(math.Min(backoff, float64(p.MaxDelay)))
+}
+
+// maxAttempts returns the number of failed attempts after which a failure of
+// class is dead-lettered. Not-found failures are never retried.
+func (p Policy) maxAttempts(class models.ScrapeErrorClass) int {
+	switch class {
+	case models.ScrapeErrorNotFound:
+		return 1
+	case models.ScrapeErrorParse:
+		return p.MaxParseAttempts
+	default:
+		return p.MaxAttempts
+	}
+}
+
+// Schedule sets attempt's next retry time from its attempt count and error
+// class, or dead-letters it once it has used up its attempts.
+func (p Policy) Schedule(attempt *models.FailedScrapeAttempt, now time.Time, random float64) {
+	p = p.withDefaults()
+	if attempt.Attempts >= p.maxAttempts(attempt.ErrorClass) {
+		attempt.DeadLettered = true
+		attempt.NextRetryAt = nil
+		return
+	}
+	next := now.Add(p.Delay(attempt.Attempts, random))
+	attempt.NextRetryAt = &next
+}
+
+// classifiedError marks an error with its class.
+type classifiedError struct {
+	class models.ScrapeErrorClass
+	err   error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+// Transient marks err as a failure that may succeed on retry.
+func Transient(err error) error {
+	return &classifiedError{class: models.ScrapeErrorTransient, err: err}
+}
+
+// Parse marks err as a page that was fetched but could not be parsed.
+func Parse(err error) error {
+	return &classifiedError{class: models.ScrapeErrorParse, err: err}
+}
+
+// NotFound marks err as a page that does not exist.
+func NotFound(err error) error {
+	return &classifiedError{class: models.ScrapeErrorNotFound, err: err}
+}
+
+// StatusError is an unsuccessful HTTP response to a scrape.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Classify returns the class of a scrape error. Errors marked with Transient,
+// Parse or NotFound keep their class; a StatusError of 404 or 410 is
+// not-found; everything else, including other status codes, timeouts and
+// connection errors, is transient.
+func Classify(err error) models.ScrapeErrorClass {
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	var status *StatusError
+	if errors.As(err, &status) && (status.StatusCode == http.StatusNotFound || status.StatusCode == http.StatusGone) {
+		return models.ScrapeErrorNotFound
+	}
+	return models.ScrapeErrorTransient
+}
+
+// Scheduler records scrape failures and retries them on Policy's schedule.
+type Scheduler struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+	random func() float64
+}
+
+// NewScheduler returns a Scheduler that persists attempts in store. Zero
+// fields of policy take their defaults.
+func NewScheduler(store Store, policy Policy) *Scheduler {
+	return &Scheduler{
+		store:  store,
+		policy: policy.withDefaults(),
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// RecordFailure records a failed scrape of resortURL and schedules its retry.
+// A URL that already has an open attempt, one neither retried nor
+// dead-lettered, counts the failure on that attempt, so repeated failures
+// reach the policy's maximum and are dead-lettered. Failures of a retry go
+// through RecordRetry instead.
+func (s *Scheduler) RecordFailure(ctx context.Context, resortURL string, scrapeErr error) (*models.FailedScrapeAttempt, error) {
+	attempt, err := s.store.GetOpenFailedScrapeAttempt(ctx, resortURL)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		attempt = &models.FailedScrapeAttempt{ResortURL: resortURL}
+	case err != nil:
+		return nil, fmt.Errorf("get open scrape attempt: %w", err)
+	}
+	return attempt, s.fail(ctx, attempt, scrapeErr)
+}
+
+// RecordRetry records the outcome of retrying attempt. A nil scrapeErr marks
+// the attempt retried; otherwise its attempt count is increased and its next
+// retry scheduled, or it is dead-lettered.
+func (s *Scheduler) RecordRetry(ctx context.Context, attempt models.FailedScrapeAttempt, scrapeErr error) (*models.FailedScrapeAttempt, error) {
+	if scrapeErr == nil {
+		if err := s.store.MarkFailedAttemptRetried(ctx, attempt.ID); err != nil {
+			return nil, err
+		}
+		retriedAt := s.now().UTC()
+		attempt.Retried = true
+		attempt.RetriedAt = &retriedAt
+		attempt.NextRetryAt = nil
+		return &attempt, nil
+	}
+	return &attempt, s.fail(ctx, &attempt, scrapeErr)
+}
+
+func (s *Scheduler) fail(ctx context.Context, attempt *models.FailedScrapeAttempt, scrapeErr error) error {
+	now := s.now().UTC()
+	attempt.Attempts++
+	attempt.ErrorMessage = scrapeErr.Error()
+	attempt.ErrorClass = Classify(scrapeErr)
+	attempt.FailedAt = now
+	s.policy.Schedule(attempt, now, s.random())
+	return s.store.SaveScrapeFailure(ctx, attempt)
+}
+
+// Result counts the outcomes of RetryDue.
+type Result struct {
+	Succeeded    int `json:"succeeded"`
+	Rescheduled  int `json:"rescheduled"`
+	DeadLettered int `json:"dead_lettered"`
+}
+
+// RetryDue calls scrape for every attempt that is due and records the
+// outcome. It stops at the first error recording an outcome, or when ctx is
+// done, and returns the outcomes recorded so far.
+func (s *Scheduler) RetryDue(ctx context.Context, scrape func(ctx context.Context, resortURL string) error) (Result, error) {
+	var result Result
+	due, err := s.store.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil {
+		return result, fmt.Errorf("get due scrape attempts: %w", err)
+	}
+
+	for _, attempt := range due {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		updated, err := s.RecordRetry(ctx, attempt, scrape(ctx, attempt.ResortURL))
+		if err != nil {
+			return result, fmt.Errorf("record retry of %s: %w", attempt.ResortURL, err)
+		}
+		switch {
+		case updated.Retried:
+			result.Succeeded++
+		case updated.DeadLettered:
+			result.DeadLettered++
+		default:
+			result.Rescheduled++
+		}
+	}
+	return result, nil
+}
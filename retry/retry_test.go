@@ -0,0 +1,182 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repositorytest"
+)
+
+func TestPolicyDelay_DoublesCapsAndJitters(t *testing.T) {
+	t.Parallel()
+
+	p := Policy{BaseDelay: time.Minute, MaxDelay: 10 * time.Minute, Jitter: 0.2}
+	tests := []struct {
+		attempts int
+		random   float64
+		want     time.Duration
+	}{
+		{1, 0.5, time.Minute},
+		{2, 0.5, 2 * time.Minute},
+		{4, 0.5, 8 * time.Minute},
+		{5, 0.5, 10 * time.Minute},
+		{1, 0, 48 * time.Second},
+		{1, 1, 72 * time.Second},
+		// Jitter never exceeds MaxDelay.
+		{9, 1, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempts, tt.random); got != tt.want {
+			t.Errorf("Delay(%d, %v) = %s, want %s", tt.attempts, tt.random, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want models.ScrapeErrorClass
+	}{
+		{"unmarked", errors.New("connection reset"), models.ScrapeErrorTransient},
+		{"deadline", context.DeadlineExceeded, models.ScrapeErrorTransient},
+		{"parse", fmt.Errorf("scrape: %w", Parse(errors.New("no snow table"))), models.ScrapeErrorParse},
+		{"not found", NotFound(errors.New("resort closed")), models.ScrapeErrorNotFound},
+		{"404", &StatusError{URL: "https://example.com", StatusCode: 404}, models.ScrapeErrorNotFound},
+		{"410", fmt.Errorf("fetch: %w", &StatusError{StatusCode: 410}), models.ScrapeErrorNotFound},
+		{"503", &StatusError{StatusCode: 503}, models.ScrapeErrorTransient},
+		{"marked transient 404", Transient(&StatusError{StatusCode: 404}), models.ScrapeErrorTransient},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScheduler_RetriesWithBackoffUntilDeadLettered(t *testing.T) {
+	t.Parallel()
+
+	store := repositorytest.New()
+	s := NewScheduler(store, Policy{BaseDelay: time.Minute, MaxAttempts: 3, Jitter: -1})
+	// Failures recorded an hour ago are due now.
+	clock := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	s.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	attempt, err := s.RecordFailure(ctx, "https://example.com/a", errors.New("timeout"))
+	if err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	if attempt.ID == "" || attempt.Attempts != 1 || attempt.ErrorClass != models.ScrapeErrorTransient ||
+		!attempt.NextRetryAt.Equal(clock.Add(time.Minute)) {
+		t.Fatalf("RecordFailure() = %+v", attempt)
+	}
+	if _, err := s.RecordFailure(ctx, "https://example.com/gone", &StatusError{StatusCode: 404}); err != nil {
+		t.Fatalf("RecordFailure(404) error = %v", err)
+	}
+
+	var scraped []string
+	failing := func(_ context.Context, url string) error {
+		scraped = append(scraped, url)
+		return errors.New("timeout")
+	}
+
+	result, err := s.RetryDue(ctx, failing)
+	if err != nil || result != (Result{Rescheduled: 1}) || len(scraped) != 1 {
+		t.Fatalf("RetryDue() = %+v, %v after scraping %v", result, err, scraped)
+	}
+	pending, err := store.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Attempts != 2 ||
+		!pending[0].NextRetryAt.Equal(clock.Add(2*time.Minute)) {
+		t.Fatalf("pending after one retry = %+v, %v", pending, err)
+	}
+
+	result, err = s.RetryDue(ctx, failing)
+	if err != nil || result != (Result{DeadLettered: 1}) {
+		t.Fatalf("RetryDue() = %+v, %v", result, err)
+	}
+	if pending, err = store.GetPendingFailedScrapeAttempts(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after dead-lettering = %+v, %v", pending, err)
+	}
+	dead, err := store.GetDeadLetteredScrapeAttempts(ctx)
+	if err != nil || len(dead) != 2 {
+		t.Fatalf("GetDeadLetteredScrapeAttempts() = %+v, %v", dead, err)
+	}
+	for _, a := range dead {
+		if a.ResortURL == "https://example.com/gone" && (a.Attempts != 1 || a.ErrorClass != models.ScrapeErrorNotFound) {
+			t.Fatalf("not-found attempt = %+v, want dead-lettered on first failure", a)
+		}
+	}
+}
+
+func TestScheduler_RecordFailureCountsRepeatedFailuresOfAURL(t *testing.T) {
+	t.Parallel()
+
+	store := repositorytest.New()
+	s := NewScheduler(store, Policy{BaseDelay: time.Minute, MaxAttempts: 3, Jitter: -1})
+	clock := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	s.now = func() time.Time { return clock }
+	ctx := context.Background()
+	const url = "https://example.com/a"
+
+	first, err := s.RecordFailure(ctx, url, errors.New("timeout"))
+	if err != nil {
+		t.Fatalf("first RecordFailure() error = %v", err)
+	}
+	second, err := s.RecordFailure(ctx, url, errors.New("connection reset"))
+	if err != nil {
+		t.Fatalf("second RecordFailure() error = %v", err)
+	}
+	if second.ID != first.ID || second.Attempts != 2 || second.ErrorMessage != "connection reset" {
+		t.Fatalf("second RecordFailure() = %+v, want attempt %s counted twice", second, first.ID)
+	}
+	pending, err := store.GetPendingFailedScrapeAttempts(ctx)
+	if err != nil || len(pending) != 1 || pending[0].ID != first.ID || pending[0].Attempts != 2 {
+		t.Fatalf("pending after two failures = %+v, %v, want one attempt with Attempts=2", pending, err)
+	}
+
+	// Reaching MaxAttempts dead-letters the attempt; a later failure of the
+	// URL starts a new one.
+	if third, err := s.RecordFailure(ctx, url, errors.New("timeout")); err != nil || !third.DeadLettered {
+		t.Fatalf("third RecordFailure() = %+v, %v, want dead-lettered", third, err)
+	}
+	fourth, err := s.RecordFailure(ctx, url, errors.New("timeout"))
+	if err != nil || fourth.ID == first.ID || fourth.Attempts != 1 {
+		t.Fatalf("RecordFailure() after dead-lettering = %+v, %v, want a new attempt", fourth, err)
+	}
+}
+
+func TestScheduler_RetryDueSkipsFutureAndMarksSuccess(t *testing.T) {
+	t.Parallel()
+
+	store := repositorytest.New()
+	s := NewScheduler(store, Policy{BaseDelay: time.Minute})
+	ctx := context.Background()
+
+	if _, err := s.RecordFailure(ctx, "https://example.com/later", errors.New("timeout")); err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+	s.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	if _, err := s.RecordFailure(ctx, "https://example.com/due", Parse(errors.New("bad page"))); err != nil {
+		t.Fatalf("RecordFailure() error = %v", err)
+	}
+
+	var scraped []string
+	result, err := s.RetryDue(ctx, func(_ context.Context, url string) error {
+		scraped = append(scraped, url)
+		return nil
+	})
+	if err != nil || result != (Result{Succeeded: 1}) {
+		t.Fatalf("RetryDue() = %+v, %v", result, err)
+	}
+	if len(scraped) != 1 || scraped[0] != "https://example.com/due" {
+		t.Fatalf("RetryDue() scraped %v, want only the due attempt", scraped)
+	}
+}
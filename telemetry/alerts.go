@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+)
+
+// AlertRepository is a traced and measured *repository.AlertRepository.
+// It exposes the same methods, so callers can swap it in without changes.
+type AlertRepository struct {
+	inst *Instrumentation
+	next *repository.AlertRepository
+}
+
+// Alerts wraps r so every call is traced and measured.
+func (inst *Instrumentation) Alerts(r *repository.AlertRepository) *AlertRepository {
+	return &AlertRepository{inst: inst, next: r}
+}
+
+// SaveAlertRule calls the wrapped repository.
+func (a *AlertRepository) SaveAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	return observeWrite(a.inst, ctx, "SaveAlertRule", -1, func(ctx context.Context) error {
+		return a.next.SaveAlertRule(ctx, rule)
+	})
+}
+
+// GetAlertRule calls the wrapped repository.
+func (a *AlertRepository) GetAlertRule(ctx context.Context, id string) (*models.AlertRule, error) {
+	return observe(a.inst, ctx, "GetAlertRule", countPtr, func(ctx context.Context) (*models.AlertRule, error) {
+		return a.next.GetAlertRule(ctx, id)
+	})
+}
+
+// ListAlertRules calls the wrapped repository.
+func (a *AlertRepository) ListAlertRules(ctx context.Context, userID string) ([]models.AlertRule, error) {
+	return observe(a.inst, ctx, "ListAlertRules", countSlice, func(ctx context.Context) ([]models.AlertRule, error) {
+		return a.next.ListAlertRules(ctx, userID)
+	})
+}
+
+// ListEnabledAlertRules calls the wrapped repository.
+func (a *AlertRepository) ListEnabledAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	return observe(a.inst, ctx, "ListEnabledAlertRules", countSlice, a.next.ListEnabledAlertRules)
+}
+
+// DeleteAlertRule calls the wrapped repository.
+func (a *AlertRepository) DeleteAlertRule(ctx context.Context, id string) error {
+	return observeWrite(a.inst, ctx, "DeleteAlertRule", -1, func(ctx context.Context) error {
+		return a.next.DeleteAlertRule(ctx, id)
+	})
+}
+
+// SaveAlertEvents calls the wrapped repository. Its row count is the number
+// of events newly fired.
+func (a *AlertRepository) SaveAlertEvents(ctx context.Context, events []models.AlertEvent) ([]models.AlertEvent, error) {
+	c := a.inst.start(ctx, "SaveAlertEvents", len(events))
+	fired, err := a.next.SaveAlertEvents(c.ctx, events)
+	c.end(len(fired), err)
+	return fired, err
+}
+
+// ListPendingAlertEvents calls the wrapped repository.
+func (a *AlertRepository) ListPendingAlertEvents(ctx context.Context, limit int) ([]models.AlertEvent, error) {
+	return observe(a.inst, ctx, "ListPendingAlertEvents", countSlice, func(ctx context.Context) ([]models.AlertEvent, error) {
+		return a.next.ListPendingAlertEvents(ctx, limit)
+	})
+}
+
+// MarkAlertEventDelivered calls the wrapped repository.
+func (a *AlertRepository) MarkAlertEventDelivered(ctx context.Context, id string) error {
+	return observeWrite(a.inst, ctx, "MarkAlertEventDelivered", -1, func(ctx context.Context) error {
+		return a.next.MarkAlertEventDelivered(ctx, id)
+	})
+}
@@ -0,0 +1,147 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+)
+
+// PredictionRepository is a traced and measured *repository.PredictionRepository.
+// It exposes the same methods, so callers can swap it in without changes.
+type PredictionRepository struct {
+	inst *Instrumentation
+	next *repository.PredictionRepository
+}
+
+// Predictions wraps r so every call is traced and measured.
+func (inst *Instrumentation) Predictions(r *repository.PredictionRepository) *PredictionRepository {
+	return &PredictionRepository{inst: inst, next: r}
+}
+
+var _ repository.PredictionReader = (*PredictionRepository)(nil)
+
+// LoadPredictionConfig calls the wrapped repository.
+func (p *PredictionRepository) LoadPredictionConfig(ctx context.Context) (map[string]models.PredictorResortConfig, error) {
+	return observe(p.inst, ctx, "LoadPredictionConfig", countMap, p.next.LoadPredictionConfig)
+}
+
+// SavePredictionConfig calls the wrapped repository.
+func (p *PredictionRepository) SavePredictionConfig(ctx context.Context, configs map[string]models.PredictorResortConfig) error {
+	return observeWrite(p.inst, ctx, "SavePredictionConfig", len(configs), func(ctx context.Context) error {
+		return p.next.SavePredictionConfig(ctx, configs)
+	})
+}
+
+// LoadGlobalParams calls the wrapped repository.
+func (p *PredictionRepository) LoadGlobalParams(ctx context.Context) (models.GlobalParams, error) {
+	return observe(p.inst, ctx, "LoadGlobalParams", func(models.GlobalParams) int { return 1 }, p.next.LoadGlobalParams)
+}
+
+// SavePredictions calls the wrapped repository.
+func (p *PredictionRepository) SavePredictions(ctx context.Context, predictions *models.PredictionData) error {
+	return observeWrite(p.inst, ctx, "SavePredictions", predictionCount(predictions), func(ctx context.Context) error {
+		return p.next.SavePredictions(ctx, predictions)
+	})
+}
+
+// SavePredictionRun calls the wrapped repository.
+func (p *PredictionRepository) SavePredictionRun(ctx context.Context, predictions *models.PredictionData) (*models.PredictionRun, error) {
+	c := p.inst.start(ctx, "SavePredictionRun", predictionCount(predictions))
+	run, err := p.next.SavePredictionRun(c.ctx, predictions)
+	c.end(-1, err)
+	return run, err
+}
+
+// GetPrediction calls the wrapped repository.
+func (p *PredictionRepository) GetPrediction(ctx context.Context, resortID string) (*models.Prediction, error) {
+	return observe(p.inst, ctx, "GetPrediction", countPtr, func(ctx context.Context) (*models.Prediction, error) {
+		return p.next.GetPrediction(ctx, resortID)
+	})
+}
+
+// GetPredictionsForResorts calls the wrapped repository.
+func (p *PredictionRepository) GetPredictionsForResorts(ctx context.Context, resortIDs []string) (map[string]models.Prediction, error) {
+	return observe(p.inst, ctx, "GetPredictionsForResorts", countMap, func(ctx context.Context) (map[string]models.Prediction, error) {
+		return p.next.GetPredictionsForResorts(ctx, resortIDs)
+	})
+}
+
+// LoadPredictionData calls the wrapped repository. Its row count is the
+// number of resorts in the result.
+func (p *PredictionRepository) LoadPredictionData(ctx context.Context) (*models.PredictionData, error) {
+	return observe(p.inst, ctx, "LoadPredictionData", predictionCount, p.next.LoadPredictionData)
+}
+
+// ListPredictionRuns calls the wrapped repository.
+func (p *PredictionRepository) ListPredictionRuns(ctx context.Context, limit int) ([]models.PredictionRun, error) {
+	return observe(p.inst, ctx, "ListPredictionRuns", countSlice, func(ctx context.Context) ([]models.PredictionRun, error) {
+		return p.next.ListPredictionRuns(ctx, limit)
+	})
+}
+
+// GetPredictionForRun calls the wrapped repository.
+func (p *PredictionRepository) GetPredictionForRun(ctx context.Context, runID, resortID string) (*models.Prediction, error) {
+	return observe(p.inst, ctx, "GetPredictionForRun", countPtr, func(ctx context.Context) (*models.Prediction, error) {
+		return p.next.GetPredictionForRun(ctx, runID, resortID)
+	})
+}
+
+// PrunePredictionRuns calls the wrapped repository. Its row count is the
+// number of runs deleted.
+func (p *PredictionRepository) PrunePredictionRuns(ctx context.Context, retention time.Duration) (int64, error) {
+	return observe(p.inst, ctx, "PrunePredictionRuns", func(n int64) int { return int(n) }, func(ctx context.Context) (int64, error) {
+		return p.next.PrunePredictionRuns(ctx, retention)
+	})
+}
+
+// ListPredictionRunsBetween calls the wrapped repository.
+func (p *PredictionRepository) ListPredictionRunsBetween(ctx context.Context, from, to time.Time) ([]models.PredictionRun, error) {
+	return observe(p.inst, ctx, "ListPredictionRunsBetween", countSlice, func(ctx context.Context) ([]models.PredictionRun, error) {
+		return p.next.ListPredictionRunsBetween(ctx, from, to)
+	})
+}
+
+// LoadPredictionRun calls the wrapped repository.
+func (p *PredictionRepository) LoadPredictionRun(ctx context.Context, runID string) (map[string]models.Prediction, error) {
+	return observe(p.inst, ctx, "LoadPredictionRun", countMap, func(ctx context.Context) (map[string]models.Prediction, error) {
+		return p.next.LoadPredictionRun(ctx, runID)
+	})
+}
+
+// GetObservedSnowfall calls the wrapped repository. Its row count is the
+// number of resorts with observations.
+func (p *PredictionRepository) GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error) {
+	return observe(p.inst, ctx, "GetObservedSnowfall", countMap, func(ctx context.Context) (map[string]map[string]int, error) {
+		return p.next.GetObservedSnowfall(ctx, from, to)
+	})
+}
+
+// SaveForecastVerification calls the wrapped repository.
+func (p *PredictionRepository) SaveForecastVerification(ctx context.Context, results []models.ForecastVerification) error {
+	return observeWrite(p.inst, ctx, "SaveForecastVerification", len(results), func(ctx context.Context) error {
+		return p.next.SaveForecastVerification(ctx, results)
+	})
+}
+
+// GetForecastVerification calls the wrapped repository.
+func (p *PredictionRepository) GetForecastVerification(ctx context.Context, resortID string) ([]models.ForecastVerification, error) {
+	return observe(p.inst, ctx, "GetForecastVerification", countSlice, func(ctx context.Context) ([]models.ForecastVerification, error) {
+		return p.next.GetForecastVerification(ctx, resortID)
+	})
+}
+
+// GetRegionForecasts calls the wrapped repository.
+func (p *PredictionRepository) GetRegionForecasts(ctx context.Context, from time.Time, days int, groupBy repository.RegionGrouping) ([]models.RegionForecast, error) {
+	return observe(p.inst, ctx, "GetRegionForecasts", countSlice, func(ctx context.Context) ([]models.RegionForecast, error) {
+		return p.next.GetRegionForecasts(ctx, from, days, groupBy)
+	})
+}
+
+func predictionCount(data *models.PredictionData) int {
+	if data == nil {
+		return 0
+	}
+	return len(data.Resorts)
+}
@@ -0,0 +1,172 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+)
+
+// Reader wraps r so every call is traced and measured.
+func (inst *Instrumentation) Reader(r repository.Reader) repository.Reader {
+	return &reader{inst: inst, next: r}
+}
+
+// Writer wraps w so every call is traced and measured. Batch writes also
+// record the number of records passed in.
+func (inst *Instrumentation) Writer(w repository.Writer) repository.Writer {
+	return &writer{reader: reader{inst: inst, next: w}, next: w}
+}
+
+type reader struct {
+	inst *Instrumentation
+	next repository.Reader
+}
+
+func (r *reader) GetResortBySlug(ctx context.Context, slug string) (*models.Resort, error) {
+	return observe(r.inst, ctx, "GetResortBySlug", countPtr, func(ctx context.Context) (*models.Resort, error) {
+		return r.next.GetResortBySlug(ctx, slug)
+	})
+}
+
+func (r *reader) GetResortByID(ctx context.Context, id string) (*models.Resort, error) {
+	return observe(r.inst, ctx, "GetResortByID", countPtr, func(ctx context.Context) (*models.Resort, error) {
+		return r.next.GetResortByID(ctx, id)
+	})
+}
+
+func (r *reader) GetAllResorts(ctx context.Context) ([]models.Resort, error) {
+	return observe(r.inst, ctx, "GetAllResorts", countSlice, r.next.GetAllResorts)
+}
+
+func (r *reader) SearchResorts(ctx context.Context, q repository.ResortQuery) (repository.ResortPage, error) {
+	return observe(r.inst, ctx, "SearchResorts", func(p repository.ResortPage) int { return len(p.Resorts) }, func(ctx context.Context) (repository.ResortPage, error) {
+		return r.next.SearchResorts(ctx, q)
+	})
+}
+
+func (r *reader) GetResortsNear(ctx context.Context, lat, lon, radiusKM float64, limit int) ([]models.ResortDistance, error) {
+	return observe(r.inst, ctx, "GetResortsNear", countSlice, func(ctx context.Context) ([]models.ResortDistance, error) {
+		return r.next.GetResortsNear(ctx, lat, lon, radiusKM, limit)
+	})
+}
+
+func (r *reader) GetResortsInBBox(ctx context.Context, minLat, minLon, maxLat, maxLon float64) ([]models.Resort, error) {
+	return observe(r.inst, ctx, "GetResortsInBBox", countSlice, func(ctx context.Context) ([]models.Resort, error) {
+		return r.next.GetResortsInBBox(ctx, minLat, minLon, maxLat, maxLon)
+	})
+}
+
+func (r *reader) GetSnowiestResorts(ctx context.Context, startDate, endDate, prefecture string, limit int) ([]models.WeeklyResortStats, error) {
+	return observe(r.inst, ctx, "GetSnowiestResorts", countSlice, func(ctx context.Context) ([]models.WeeklyResortStats, error) {
+		return r.next.GetSnowiestResorts(ctx, startDate, endDate, prefecture, limit)
+	})
+}
+
+func (r *reader) GetAllResortsWithPeaks(ctx context.Context) ([]models.ResortWithPeaks, error) {
+	return observe(r.inst, ctx, "GetAllResortsWithPeaks", countSlice, r.next.GetAllResortsWithPeaks)
+}
+
+func (r *reader) GetPeakPeriodsForResort(ctx context.Context, resortID string) ([]models.PeakPeriod, error) {
+	return observe(r.inst, ctx, "GetPeakPeriodsForResort", countSlice, func(ctx context.Context) ([]models.PeakPeriod, error) {
+		return r.next.GetPeakPeriodsForResort(ctx, resortID)
+	})
+}
+
+func (r *reader) GetPendingFailedScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	return observe(r.inst, ctx, "GetPendingFailedScrapeAttempts", countSlice, r.next.GetPendingFailedScrapeAttempts)
+}
+
+func (r *reader) GetOpenFailedScrapeAttempt(ctx context.Context, resortURL string) (*models.FailedScrapeAttempt, error) {
+	return observe(r.inst, ctx, "GetOpenFailedScrapeAttempt", countPtr, func(ctx context.Context) (*models.FailedScrapeAttempt, error) {
+		return r.next.GetOpenFailedScrapeAttempt(ctx, resortURL)
+	})
+}
+
+func (r *reader) GetDeadLetteredScrapeAttempts(ctx context.Context) ([]models.FailedScrapeAttempt, error) {
+	return observe(r.inst, ctx, "GetDeadLetteredScrapeAttempts", countSlice, r.next.GetDeadLetteredScrapeAttempts)
+}
+
+func (r *reader) GetSnowDepthHistory(ctx context.Context, resortID string, from, to time.Time) ([]models.SnowDepthReading, error) {
+	return observe(r.inst, ctx, "GetSnowDepthHistory", countSlice, func(ctx context.Context) ([]models.SnowDepthReading, error) {
+		return r.next.GetSnowDepthHistory(ctx, resortID, from, to)
+	})
+}
+
+func (r *reader) GetLatestSnowDepth(ctx context.Context, resortIDs []string) (map[string]models.SnowDepthReading, error) {
+	return observe(r.inst, ctx, "GetLatestSnowDepth", countMap, func(ctx context.Context) (map[string]models.SnowDepthReading, error) {
+		return r.next.GetLatestSnowDepth(ctx, resortIDs)
+	})
+}
+
+func (r *reader) GetSeasonMaxSnowDepth(ctx context.Context, resortIDs []string, asOf time.Time) (map[string]models.SnowDepthReading, error) {
+	return observe(r.inst, ctx, "GetSeasonMaxSnowDepth", countMap, func(ctx context.Context) (map[string]models.SnowDepthReading, error) {
+		return r.next.GetSeasonMaxSnowDepth(ctx, resortIDs, asOf)
+	})
+}
+
+func (r *reader) GetDailySnowfall(ctx context.Context, resortID string, from, to time.Time) ([]models.DailySnowfall, error) {
+	return observe(r.inst, ctx, "GetDailySnowfall", countSlice, func(ctx context.Context) ([]models.DailySnowfall, error) {
+		return r.next.GetDailySnowfall(ctx, resortID, from, to)
+	})
+}
+
+func (r *reader) GetSeasonSnowfallTotals(ctx context.Context, resortID string, seasons int, throughMMDD string) ([]models.SeasonSnowfallTotal, error) {
+	return observe(r.inst, ctx, "GetSeasonSnowfallTotals", countSlice, func(ctx context.Context) ([]models.SeasonSnowfallTotal, error) {
+		return r.next.GetSeasonSnowfallTotals(ctx, resortID, seasons, throughMMDD)
+	})
+}
+
+func (r *reader) GetRegionSummaries(ctx context.Context, from, to time.Time, groupBy repository.RegionGrouping) ([]models.RegionSummary, error) {
+	return observe(r.inst, ctx, "GetRegionSummaries", countSlice, func(ctx context.Context) ([]models.RegionSummary, error) {
+		return r.next.GetRegionSummaries(ctx, from, to, groupBy)
+	})
+}
+
+type writer struct {
+	reader
+	next repository.Writer
+}
+
+func (w *writer) SaveResort(ctx context.Context, resort *models.Resort) error {
+	return observeWrite(w.inst, ctx, "SaveResort", -1, func(ctx context.Context) error {
+		return w.next.SaveResort(ctx, resort)
+	})
+}
+
+func (w *writer) SaveSnowDepthReadings(ctx context.Context, readings []models.SnowDepthReading) error {
+	return observeWrite(w.inst, ctx, "SaveSnowDepthReadings", len(readings), func(ctx context.Context) error {
+		return w.next.SaveSnowDepthReadings(ctx, readings)
+	})
+}
+
+func (w *writer) SaveDailySnowfall(ctx context.Context, snowfalls []models.DailySnowfall) error {
+	return observeWrite(w.inst, ctx, "SaveDailySnowfall", len(snowfalls), func(ctx context.Context) error {
+		return w.next.SaveDailySnowfall(ctx, snowfalls)
+	})
+}
+
+func (w *writer) SaveFailedScrapeAttempt(ctx context.Context, resortURL, errorMessage string) error {
+	return observeWrite(w.inst, ctx, "SaveFailedScrapeAttempt", -1, func(ctx context.Context) error {
+		return w.next.SaveFailedScrapeAttempt(ctx, resortURL, errorMessage)
+	})
+}
+
+func (w *writer) SaveScrapeFailure(ctx context.Context, attempt *models.FailedScrapeAttempt) error {
+	return observeWrite(w.inst, ctx, "SaveScrapeFailure", -1, func(ctx context.Context) error {
+		return w.next.SaveScrapeFailure(ctx, attempt)
+	})
+}
+
+func (w *writer) MarkFailedAttemptRetried(ctx context.Context, id string) error {
+	return observeWrite(w.inst, ctx, "MarkFailedAttemptRetried", -1, func(ctx context.Context) error {
+		return w.next.MarkFailedAttemptRetried(ctx, id)
+	})
+}
+
+func (w *writer) ReplacePeakPeriods(ctx context.Context, resortID string, peaks []models.PeakPeriod) error {
+	return observeWrite(w.inst, ctx, "ReplacePeakPeriods", -1, func(ctx context.Context) error {
+		return w.next.ReplacePeakPeriods(ctx, resortID, peaks)
+	})
+}
@@ -0,0 +1,174 @@
+// Package telemetry instruments repositories and the lifecycle manager with
+// OpenTelemetry spans and metrics.
+//
+// Every wrapped repository method gets a span and records its latency, result
+// row count and errors. Batch writes also record their batch size. Providers
+// are passed in explicitly so tests can use in-memory exporters.
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/amaumene/snowfinder_common/lifecycle"
+)
+
+// ScopeName is the instrumentation scope of all spans and metrics.
+const ScopeName = "github.com/amaumene/snowfinder_common/telemetry"
+
+// Attribute keys set on spans and metrics.
+const (
+	MethodKey    = attribute.Key("repository.method")
+	OutcomeKey   = attribute.Key("repository.outcome")
+	RowsKey      = attribute.Key("repository.rows")
+	BatchSizeKey = attribute.Key("repository.batch_size")
+	EventKey     = attribute.Key("lifecycle.event")
+)
+
+// Outcomes recorded under OutcomeKey. A wrapped sql.ErrNoRows is reported as
+// not_found rather than as an error.
+const (
+	OutcomeOK       = "ok"
+	OutcomeNotFound = "not_found"
+	OutcomeError    = "error"
+)
+
+// Instrumentation holds the tracer and instruments shared by every wrapper.
+type Instrumentation struct {
+	tracer    trace.Tracer
+	duration  metric.Float64Histogram
+	rows      metric.Int64Histogram
+	errors    metric.Int64Counter
+	batchSize metric.Int64Histogram
+	events    metric.Int64Counter
+}
+
+// New creates the instruments from the given providers.
+func New(tp trace.TracerProvider, mp metric.MeterProvider) (*Instrumentation, error) {
+	meter := mp.Meter(ScopeName)
+	inst := &Instrumentation{tracer: tp.Tracer(ScopeName)}
+
+	var err error
+	if inst.duration, err = meter.Float64Histogram("snowfinder.repository.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of repository calls.")); err != nil {
+		return nil, fmt.Errorf("create duration histogram: %w", err)
+	}
+	if inst.rows, err = meter.Int64Histogram("snowfinder.repository.rows",
+		metric.WithUnit("{row}"), metric.WithDescription("Rows returned by repository reads.")); err != nil {
+		return nil, fmt.Errorf("create rows histogram: %w", err)
+	}
+	if inst.errors, err = meter.Int64Counter("snowfinder.repository.errors",
+		metric.WithUnit("{error}"), metric.WithDescription("Failed repository calls.")); err != nil {
+		return nil, fmt.Errorf("create errors counter: %w", err)
+	}
+	if inst.batchSize, err = meter.Int64Histogram("snowfinder.repository.batch_size",
+		metric.WithUnit("{record}"), metric.WithDescription("Records passed to repository batch writes.")); err != nil {
+		return nil, fmt.Errorf("create batch size histogram: %w", err)
+	}
+	if inst.events, err = meter.Int64Counter("snowfinder.lifecycle.events",
+		metric.WithUnit("{event}"), metric.WithDescription("Idle shutdown events from the lifecycle manager.")); err != nil {
+		return nil, fmt.Errorf("create lifecycle events counter: %w", err)
+	}
+	return inst, nil
+}
+
+// call describes one repository call in progress.
+type call struct {
+	inst   *Instrumentation
+	ctx    context.Context
+	span   trace.Span
+	method string
+	start  time.Time
+}
+
+// start opens a span for method. batch is the number of records written, or
+// -1 for calls that are not batch writes.
+func (inst *Instrumentation) start(ctx context.Context, method string, batch int) *call {
+	ctx, span := inst.tracer.Start(ctx, "repository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(MethodKey.String(method)))
+	if batch >= 0 {
+		span.SetAttributes(BatchSizeKey.Int(batch))
+		inst.batchSize.Record(ctx, int64(batch), metric.WithAttributes(MethodKey.String(method)))
+	}
+	return &call{inst: inst, ctx: ctx, span: span, method: method, start: time.Now()}
+}
+
+// end records the call's outcome. rows is the number of rows read, or -1 for
+// calls that do not return rows.
+func (c *call) end(rows int, err error) {
+	defer c.span.End()
+
+	outcome := OutcomeOK
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		outcome = OutcomeNotFound
+	case err != nil:
+		outcome = OutcomeError
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+		c.inst.errors.Add(c.ctx, 1, metric.WithAttributes(MethodKey.String(c.method)))
+	}
+
+	attrs := metric.WithAttributes(MethodKey.String(c.method), OutcomeKey.String(outcome))
+	c.span.SetAttributes(OutcomeKey.String(outcome))
+	c.inst.duration.Record(c.ctx, time.Since(c.start).Seconds(), attrs)
+	if rows >= 0 && err == nil {
+		c.span.SetAttributes(RowsKey.Int(rows))
+		c.inst.rows.Record(c.ctx, int64(rows), metric.WithAttributes(MethodKey.String(c.method)))
+	}
+}
+
+// observe runs fn inside a call, counting result rows with count.
+func observe[T any](inst *Instrumentation, ctx context.Context, method string, count func(T) int, fn func(context.Context) (T, error)) (T, error) {
+	c := inst.start(ctx, method, -1)
+	result, err := fn(c.ctx)
+	c.end(count(result), err)
+	return result, err
+}
+
+// observeWrite runs a write inside a call. batch is as for start.
+func observeWrite(inst *Instrumentation, ctx context.Context, method string, batch int, fn func(context.Context) error) error {
+	c := inst.start(ctx, method, batch)
+	err := fn(c.ctx)
+	c.end(-1, err)
+	return err
+}
+
+func countSlice[S ~[]E, E any](s S) int { return len(s) }
+
+func countMap[M ~map[K]V, K comparable, V any](m M) int { return len(m) }
+
+func countPtr[T any](p *T) int {
+	if p == nil {
+		return 0
+	}
+	return 1
+}
+
+// ObserveLifecycle records m's idle shutdown events as a counter and as short
+// spans; failed stops are marked as errors.
+func (inst *Instrumentation) ObserveLifecycle(m *lifecycle.Manager) {
+	m.Observe(func(e lifecycle.Event) {
+		ctx := context.Background()
+		attrs := []attribute.KeyValue{EventKey.String(e.Kind.String())}
+		inst.events.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+		_, span := inst.tracer.Start(ctx, "lifecycle."+e.Kind.String(),
+			trace.WithTimestamp(e.Time),
+			trace.WithAttributes(append(attrs, attribute.String("lifecycle.idle_timeout", e.IdleTimeout.String()))...))
+		if e.Err != nil {
+			span.RecordError(e.Err)
+			span.SetStatus(codes.Error, e.Err.Error())
+		}
+		span.End(trace.WithTimestamp(e.Time))
+	})
+}
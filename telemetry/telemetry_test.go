@@ -0,0 +1,280 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/amaumene/snowfinder_common/lifecycle"
+	"github.com/amaumene/snowfinder_common/migrations"
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+	"github.com/amaumene/snowfinder_common/repositorytest"
+	_ "modernc.org/sqlite"
+)
+
+type harness struct {
+	inst    *Instrumentation
+	spans   *tracetest.InMemoryExporter
+	metrics *sdkmetric.ManualReader
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	spans := tracetest.NewInMemoryExporter()
+	metrics := sdkmetric.NewManualReader()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metrics))
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background()) //nolint:errcheck
+		mp.Shutdown(context.Background()) //nolint:errcheck
+	})
+
+	inst, err := New(tp, mp)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return &harness{inst: inst, spans: spans, metrics: metrics}
+}
+
+// span returns the only recorded span called name.
+func (h *harness) span(t *testing.T, name string) tracetest.SpanStub {
+	t.Helper()
+	var found []tracetest.SpanStub
+	for _, s := range h.spans.GetSpans() {
+		if s.Name == name {
+			found = append(found, s)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %d spans named %q, want 1", len(found), name)
+	}
+	return found[0]
+}
+
+func spanAttr(s tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// metric returns the collected metric called name.
+func (h *harness) metric(t *testing.T, name string) metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := h.metrics.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %q not recorded", name)
+	return metricdata.Metrics{}
+}
+
+// histogramFor returns the histogram point for method.
+func histogramFor(t *testing.T, m metricdata.Metrics, method string) metricdata.HistogramDataPoint[int64] {
+	t.Helper()
+	hist, ok := m.Data.(metricdata.Histogram[int64])
+	if !ok {
+		t.Fatalf("%s data = %T, want int64 histogram", m.Name, m.Data)
+	}
+	for _, dp := range hist.DataPoints {
+		if v, ok := dp.Attributes.Value(MethodKey); ok && v.AsString() == method {
+			return dp
+		}
+	}
+	t.Fatalf("%s has no point for %s", m.Name, method)
+	return metricdata.HistogramDataPoint[int64]{}
+}
+
+// sumFor returns the counter value for the attribute key=value.
+func sumFor(m metricdata.Metrics, key attribute.Key, value string) int64 {
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	if !ok {
+		return 0
+	}
+	var total int64
+	for _, dp := range sum.DataPoints {
+		if v, ok := dp.Attributes.Value(key); ok && v.AsString() == value {
+			total += dp.Value
+		}
+	}
+	return total
+}
+
+func TestWriter_RecordsSpansRowsAndBatchSizes(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness(t)
+	w := h.inst.Writer(repositorytest.New())
+	ctx := context.Background()
+
+	resort := &models.Resort{Slug: "niseko", Name: "Niseko", Prefecture: "Hokkaido"}
+	if err := w.SaveResort(ctx, resort); err != nil {
+		t.Fatalf("SaveResort() error = %v", err)
+	}
+	snowfalls := []models.DailySnowfall{
+		{ResortID: resort.ID, Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), SnowfallCM: 10},
+		{ResortID: resort.ID, Date: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), SnowfallCM: 20},
+		{ResortID: resort.ID, Date: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), SnowfallCM: 30},
+	}
+	if err := w.SaveDailySnowfall(ctx, snowfalls); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+	got, err := w.GetDailySnowfall(ctx, resort.ID, snowfalls[0].Date, snowfalls[2].Date)
+	if err != nil || len(got) != 3 {
+		t.Fatalf("GetDailySnowfall() = %d rows, %v", len(got), err)
+	}
+
+	save := h.span(t, "repository.SaveDailySnowfall")
+	if v, _ := spanAttr(save, BatchSizeKey); v.AsInt64() != 3 {
+		t.Errorf("SaveDailySnowfall batch_size = %v, want 3", v.AsInt64())
+	}
+	read := h.span(t, "repository.GetDailySnowfall")
+	if v, _ := spanAttr(read, RowsKey); v.AsInt64() != 3 {
+		t.Errorf("GetDailySnowfall rows = %v, want 3", v.AsInt64())
+	}
+	if read.Status.Code == codes.Error {
+		t.Errorf("GetDailySnowfall status = %v", read.Status)
+	}
+
+	if dp := histogramFor(t, h.metric(t, "snowfinder.repository.batch_size"), "SaveDailySnowfall"); dp.Count != 1 || dp.Sum != 3 {
+		t.Errorf("batch_size point = count %d sum %d, want 1/3", dp.Count, dp.Sum)
+	}
+	if dp := histogramFor(t, h.metric(t, "snowfinder.repository.rows"), "GetDailySnowfall"); dp.Sum != 3 {
+		t.Errorf("rows point sum = %d, want 3", dp.Sum)
+	}
+
+	duration := h.metric(t, "snowfinder.repository.duration")
+	hist, ok := duration.Data.(metricdata.Histogram[float64])
+	if !ok || len(hist.DataPoints) != 3 {
+		t.Fatalf("duration data = %+v, want one point per method", duration.Data)
+	}
+}
+
+func TestReader_RecordsErrorsAndNotFound(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness(t)
+	r := h.inst.Reader(failingReader{Reader: repositorytest.New()})
+	ctx := context.Background()
+
+	if _, err := r.GetResortBySlug(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetResortBySlug() error = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := r.GetAllResorts(ctx); !errors.Is(err, errBoom) {
+		t.Fatalf("GetAllResorts() error = %v, want errBoom", err)
+	}
+
+	notFound := h.span(t, "repository.GetResortBySlug")
+	if v, _ := spanAttr(notFound, OutcomeKey); v.AsString() != OutcomeNotFound || notFound.Status.Code == codes.Error {
+		t.Errorf("GetResortBySlug outcome = %q status = %v", v.AsString(), notFound.Status)
+	}
+	failed := h.span(t, "repository.GetAllResorts")
+	if failed.Status.Code != codes.Error || len(failed.Events) == 0 {
+		t.Errorf("GetAllResorts status = %v events = %d, want error with recorded event", failed.Status, len(failed.Events))
+	}
+
+	errs := h.metric(t, "snowfinder.repository.errors")
+	if n := sumFor(errs, MethodKey, "GetAllResorts"); n != 1 {
+		t.Errorf("errors{GetAllResorts} = %d, want 1", n)
+	}
+	if n := sumFor(errs, MethodKey, "GetResortBySlug"); n != 0 {
+		t.Errorf("errors{GetResortBySlug} = %d, want 0", n)
+	}
+}
+
+var errBoom = errors.New("boom")
+
+type failingReader struct{ repository.Reader }
+
+func (failingReader) GetAllResorts(context.Context) ([]models.Resort, error) { return nil, errBoom }
+
+func TestPredictions_RecordsCalls(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if err := migrations.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+
+	h := newHarness(t)
+	repo := h.inst.Predictions(repository.NewPredictionRepository(db))
+	ctx := context.Background()
+
+	data := &models.PredictionData{
+		GeneratedAt: "2025-01-10T06:00:00Z",
+		Resorts:     map[string]models.Prediction{"a": {Name: "A"}, "b": {Name: "B"}},
+	}
+	if _, err := repo.SavePredictionRun(ctx, data); err != nil {
+		t.Fatalf("SavePredictionRun() error = %v", err)
+	}
+	if _, err := repo.GetPredictionsForResorts(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("GetPredictionsForResorts() error = %v", err)
+	}
+
+	if v, _ := spanAttr(h.span(t, "repository.SavePredictionRun"), BatchSizeKey); v.AsInt64() != 2 {
+		t.Errorf("SavePredictionRun batch_size = %d, want 2", v.AsInt64())
+	}
+	if v, _ := spanAttr(h.span(t, "repository.GetPredictionsForResorts"), RowsKey); v.AsInt64() != 2 {
+		t.Errorf("GetPredictionsForResorts rows = %d, want 2", v.AsInt64())
+	}
+}
+
+func TestObserveLifecycle_CountsIdleShutdownEvents(t *testing.T) {
+	// Unset Fly variables so the idle handler skips the machine stop.
+	t.Setenv("FLY_APP_NAME", "")
+	t.Setenv("FLY_MACHINE_ID", "")
+
+	h := newHarness(t)
+	m, err := lifecycle.New(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("lifecycle.New() error = %v", err)
+	}
+	h.inst.ObserveLifecycle(m)
+	done := make(chan struct{})
+	m.Observe(func(e lifecycle.Event) {
+		if e.Kind == lifecycle.EventStopSkipped {
+			close(done)
+		}
+	})
+
+	m.ResetIdleTimer()
+	defer m.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle shutdown did not run")
+	}
+
+	events := h.metric(t, "snowfinder.lifecycle.events")
+	for _, kind := range []lifecycle.EventKind{lifecycle.EventIdle, lifecycle.EventStopSkipped} {
+		if n := sumFor(events, EventKey, kind.String()); n != 1 {
+			t.Errorf("events{%s} = %d, want 1", kind, n)
+		}
+	}
+	h.span(t, "lifecycle.idle")
+	h.span(t, "lifecycle.stop_skipped")
+}
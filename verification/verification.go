@@ -0,0 +1,294 @@
+// Package verification scores stored prediction runs against the daily
+// snowfall observed afterwards and persists per-resort, per-lead-time skill
+// metrics (MAE, bias, range-hit rate and powder Brier scores).
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/models"
+)
+
+// PowderThresholdsCM are the snowfall thresholds scored against
+// models.PowderProb, in the same order as its fields.
+var PowderThresholdsCM = [4]float64{5, 10, 20, 30}
+
+// SampleSource is the persistence needed to pair forecasts with observations.
+// *repository.PredictionRepository satisfies it.
+type SampleSource interface {
+	ListPredictionRunsBetween(ctx context.Context, from, to time.Time) ([]models.PredictionRun, error)
+	LoadPredictionRun(ctx context.Context, runID string) (map[string]models.Prediction, error)
+	GetObservedSnowfall(ctx context.Context, from, to string) (map[string]map[string]int, error)
+}
+
+// Store is the persistence needed to verify forecasts.
+// *repository.PredictionRepository satisfies it.
+type Store interface {
+	SampleSource
+	SaveForecastVerification(ctx context.Context, results []models.ForecastVerification) error
+}
+
+// Options controls which runs are verified.
+type Options struct {
+	// From and To bound the runs' GeneratedAt (inclusive).
+	From time.Time
+	To   time.Time
+	// Location is used to turn a run's GeneratedAt into a calendar date when
+	// computing lead days. Defaults to UTC.
+	Location *time.Location
+	// DryRun computes the metrics without persisting them.
+	DryRun bool
+}
+
+// Sample pairs one forecast day with the snowfall observed on that day.
+type Sample struct {
+	ResortID string
+	// Source is the run's source; ModelSource is the single model the
+	// resort's forecast came from, or empty when it blends several.
+	Source      string
+	ModelSource string
+	// AppliedFactor is the bias factor that was applied to ModelSource's
+	// output, 1 when none was, or 0 when the run did not record its factors.
+	AppliedFactor float64
+	LeadDays      int
+	ForecastCM    float64
+	RangeLow      float64
+	RangeHigh     float64
+	Powder        *models.PowderProb
+	ObservedCM    float64
+}
+
+// Run verifies every run generated within [opts.From, opts.To] against observed
+// snowfall, persists the aggregated metrics unless opts.DryRun is set, and
+// returns them ordered by resort ID and lead time.
+func Run(ctx context.Context, store Store, opts Options) ([]models.ForecastVerification, error) {
+	if store == nil {
+		return nil, errors.New("nil store")
+	}
+	if opts.To.Before(opts.From) {
+		return nil, fmt.Errorf("to %s is before from %s", opts.To.Format(time.RFC3339), opts.From.Format(time.RFC3339))
+	}
+
+	samples, err := CollectSamples(ctx, store, opts.From, opts.To, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	results := Aggregate(samples, time.Now().UTC())
+	if opts.DryRun {
+		return results, nil
+	}
+	if err := store.SaveForecastVerification(ctx, results); err != nil {
+		return nil, fmt.Errorf("save forecast verification: %w", err)
+	}
+	return results, nil
+}
+
+// CollectSamples loads every run generated within [from, to] and pairs its
+// forecast days with the snowfall observed on those days.
+func CollectSamples(ctx context.Context, src SampleSource, from, to time.Time, loc *time.Location) ([]Sample, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	runs, err := src.ListPredictionRunsBetween(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("list prediction runs: %w", err)
+	}
+
+	type loadedRun struct {
+		run         models.PredictionRun
+		predictions map[string]models.Prediction
+	}
+	loaded := make([]loadedRun, 0, len(runs))
+	var minDate, maxDate string
+	for _, run := range runs {
+		predictions, err := src.LoadPredictionRun(ctx, run.ID)
+		if err != nil {
+			return nil, fmt.Errorf("load prediction run %s: %w", run.ID, err)
+		}
+		for _, pred := range predictions {
+			for _, day := range pred.Daily {
+				if minDate == "" || day.Date < minDate {
+					minDate = day.Date
+				}
+				if day.Date > maxDate {
+					maxDate = day.Date
+				}
+			}
+		}
+		loaded = append(loaded, loadedRun{run: run, predictions: predictions})
+	}
+	if minDate == "" {
+		return nil, nil
+	}
+
+	observed, err := src.GetObservedSnowfall(ctx, minDate, maxDate)
+	if err != nil {
+		return nil, fmt.Errorf("get observed snowfall: %w", err)
+	}
+
+	var samples []Sample
+	for _, lr := range loaded {
+		runSamples, err := Samples(lr.run, lr.predictions, observed, loc)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, runSamples...)
+	}
+	return samples, nil
+}
+
+// Samples pairs each forecast day of a run with its observation. Days with no
+// observation, and days dated before the run, are skipped.
+func Samples(run models.PredictionRun, predictions map[string]models.Prediction, observed map[string]map[string]int, loc *time.Location) ([]Sample, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	generated := run.GeneratedAt.In(loc)
+	runDate := time.Date(generated.Year(), generated.Month(), generated.Day(), 0, 0, 0, 0, time.UTC)
+
+	var samples []Sample
+	for resortID, pred := range predictions {
+		resortObserved := observed[resortID]
+		if len(resortObserved) == 0 {
+			continue
+		}
+		var modelSource string
+		var applied float64
+		if len(pred.Sources) == 1 {
+			modelSource = pred.Sources[0]
+			if pred.BiasFactors != nil {
+				applied = 1
+				if f, ok := pred.BiasFactors[modelSource]; ok {
+					applied = f
+				}
+			}
+		}
+		for _, day := range pred.Daily {
+			obs, ok := resortObserved[day.Date]
+			if !ok {
+				continue
+			}
+			date, err := time.Parse("2006-01-02", day.Date)
+			if err != nil {
+				return nil, fmt.Errorf("parse forecast date %q for %s in run %s: %w", day.Date, resortID, run.ID, err)
+			}
+			lead := int(date.Sub(runDate).Hours() / 24)
+			if lead < 0 {
+				continue
+			}
+			samples = append(samples, Sample{
+				ResortID:      resortID,
+				Source:        run.Source,
+				ModelSource:   modelSource,
+				AppliedFactor: applied,
+				LeadDays:      lead,
+				ForecastCM:    day.SnowfallCM,
+				RangeLow:      day.SnowfallRangeLow,
+				RangeHigh:     day.SnowfallRangeHigh,
+				Powder:        day.PowderProbability,
+				ObservedCM:    float64(obs),
+			})
+		}
+	}
+	return samples, nil
+}
+
+// Aggregate groups samples by resort and lead time and computes skill metrics.
+// An observation counts as exceeding a powder threshold when it is strictly
+// greater than the threshold. Samples without a range (RangeLow and RangeHigh
+// both zero) are left out of the range hit rate.
+func Aggregate(samples []Sample, computedAt time.Time) []models.ForecastVerification {
+	type key struct {
+		resortID string
+		lead     int
+	}
+	type accumulator struct {
+		n, rangeN, hits, brierN int
+		absErr, err             float64
+		brier                   [4]float64
+	}
+
+	acc := make(map[key]*accumulator)
+	for _, s := range samples {
+		k := key{s.ResortID, s.LeadDays}
+		a := acc[k]
+		if a == nil {
+			a = &accumulator{}
+			acc[k] = a
+		}
+		diff := s.ForecastCM - s.ObservedCM
+		a.n++
+		a.err += diff
+		a.absErr += math.Abs(diff)
+		if s.RangeLow != 0 || s.RangeHigh != 0 {
+			a.rangeN++
+			if s.ObservedCM >= s.RangeLow && s.ObservedCM <= s.RangeHigh {
+				a.hits++
+			}
+		}
+		if s.Powder != nil {
+			a.brierN++
+			probs := powderProbabilities(s.Powder)
+			for i, threshold := range PowderThresholdsCM {
+				outcome := 0.0
+				if s.ObservedCM > threshold {
+					outcome = 1
+				}
+				d := probs[i] - outcome
+				a.brier[i] += d * d
+			}
+		}
+	}
+
+	results := make([]models.ForecastVerification, 0, len(acc))
+	for k, a := range acc {
+		v := models.ForecastVerification{
+			ResortID:         k.resortID,
+			LeadDays:         k.lead,
+			SampleCount:      a.n,
+			MAECM:            a.absErr / float64(a.n),
+			BiasCM:           a.err / float64(a.n),
+			RangeSampleCount: a.rangeN,
+			BrierSampleCount: a.brierN,
+			ComputedAt:       computedAt,
+		}
+		if a.rangeN > 0 {
+			rate := float64(a.hits) / float64(a.rangeN)
+			v.RangeHitRate = &rate
+		}
+		if a.brierN > 0 {
+			scores := [4]*float64{}
+			for i := range scores {
+				score := a.brier[i] / float64(a.brierN)
+				scores[i] = &score
+			}
+			v.Brier5cm, v.Brier10cm, v.Brier20cm, v.Brier30cm = scores[0], scores[1], scores[2], scores[3]
+		}
+		results = append(results, v)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ResortID != results[j].ResortID {
+			return results[i].ResortID < results[j].ResortID
+		}
+		return results[i].LeadDays < results[j].LeadDays
+	})
+	return results
+}
+
+// powderProbabilities converts percentage exceedance values to [0, 1] probabilities.
+func powderProbabilities(p *models.PowderProb) [4]float64 {
+	return [4]float64{
+		float64(p.Exceeds5cm) / 100,
+		float64(p.Exceeds10cm) / 100,
+		float64(p.Exceeds20cm) / 100,
+		float64(p.Exceeds30cm) / 100,
+	}
+}
@@ -0,0 +1,184 @@
+package verification
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/amaumene/snowfinder_common/migrations"
+	"github.com/amaumene/snowfinder_common/models"
+	"github.com/amaumene/snowfinder_common/repository"
+	_ "modernc.org/sqlite"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// almostEqualPtr reports whether a is set and almost equal to b.
+func almostEqualPtr(a *float64, b float64) bool {
+	return a != nil && almostEqual(*a, b)
+}
+
+func TestSamples_ComputesLeadDaysAndSkipsMissing(t *testing.T) {
+	t.Parallel()
+
+	run := models.PredictionRun{ID: "run-1", GeneratedAt: time.Date(2025, 1, 14, 21, 0, 0, 0, time.UTC)}
+	predictions := map[string]models.Prediction{
+		"resort-1": {Daily: []models.DailyForecast{
+			{Date: "2025-01-14", SnowfallCM: 1},
+			{Date: "2025-01-15", SnowfallCM: 10},
+			{Date: "2025-01-16", SnowfallCM: 20},
+			{Date: "2025-01-17", SnowfallCM: 30},
+		}},
+	}
+	observed := map[string]map[string]int{
+		"resort-1": {"2025-01-14": 0, "2025-01-15": 8, "2025-01-17": 25},
+	}
+
+	jst := time.FixedZone("JST", 9*60*60)
+	samples, err := Samples(run, predictions, observed, jst)
+	if err != nil {
+		t.Fatalf("Samples() error = %v", err)
+	}
+	// In JST the run is dated 2025-01-15: 01-14 is skipped as before the run,
+	// 01-16 has no observation.
+	if len(samples) != 2 {
+		t.Fatalf("Samples() returned %d samples, want 2: %+v", len(samples), samples)
+	}
+	leads := map[int]float64{}
+	for _, s := range samples {
+		leads[s.LeadDays] = s.ObservedCM
+	}
+	if leads[0] != 8 || leads[2] != 25 {
+		t.Fatalf("Samples() leads = %+v, want {0:8 2:25}", leads)
+	}
+}
+
+func TestAggregate_ComputesMetrics(t *testing.T) {
+	t.Parallel()
+
+	samples := []Sample{
+		{ResortID: "r", LeadDays: 1, ForecastCM: 10, RangeLow: 5, RangeHigh: 15, ObservedCM: 6,
+			Powder: &models.PowderProb{Exceeds5cm: 100, Exceeds10cm: 50, Exceeds20cm: 0, Exceeds30cm: 0}},
+		{ResortID: "r", LeadDays: 1, ForecastCM: 4, RangeLow: 0, RangeHigh: 8, ObservedCM: 12},
+		{ResortID: "r", LeadDays: 0, ForecastCM: 2, RangeLow: 0, RangeHigh: 4, ObservedCM: 2},
+		// No range, and an observation exactly at the 10 cm threshold.
+		{ResortID: "r", LeadDays: 2, ForecastCM: 0, ObservedCM: 0},
+		{ResortID: "r", LeadDays: 2, ForecastCM: 10, RangeLow: 12, RangeHigh: 20, ObservedCM: 10,
+			Powder: &models.PowderProb{Exceeds5cm: 100, Exceeds10cm: 100}},
+	}
+
+	computedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	results := Aggregate(samples, computedAt)
+	if len(results) != 3 {
+		t.Fatalf("Aggregate() returned %d results, want 3", len(results))
+	}
+	if results[0].LeadDays != 0 || results[1].LeadDays != 1 {
+		t.Fatalf("Aggregate() not ordered by lead: %+v", results)
+	}
+
+	lead1 := results[1]
+	if lead1.SampleCount != 2 {
+		t.Fatalf("SampleCount = %d, want 2", lead1.SampleCount)
+	}
+	if !almostEqual(lead1.MAECM, 6) || !almostEqual(lead1.BiasCM, -2) {
+		t.Fatalf("MAE/bias = %v/%v, want 6/-2", lead1.MAECM, lead1.BiasCM)
+	}
+	if lead1.RangeSampleCount != 2 || !almostEqualPtr(lead1.RangeHitRate, 0.5) {
+		t.Fatalf("range samples/hit rate = %d/%v, want 2/0.5", lead1.RangeSampleCount, lead1.RangeHitRate)
+	}
+	if lead1.BrierSampleCount != 1 || lead1.Brier5cm == nil || lead1.Brier10cm == nil {
+		t.Fatalf("Brier scores missing: %+v", lead1)
+	}
+	if !almostEqual(*lead1.Brier5cm, 0) || !almostEqual(*lead1.Brier10cm, 0.25) {
+		t.Fatalf("Brier5/10 = %v/%v, want 0/0.25", *lead1.Brier5cm, *lead1.Brier10cm)
+	}
+	if results[0].Brier5cm != nil {
+		t.Fatal("expected nil Brier scores without powder probabilities")
+	}
+
+	lead2 := results[2]
+	if lead2.SampleCount != 2 || lead2.RangeSampleCount != 1 || !almostEqualPtr(lead2.RangeHitRate, 0) {
+		t.Fatalf("lead 2 = %+v, want the rangeless sample left out of a 0 hit rate", lead2)
+	}
+	if !almostEqual(*lead2.Brier5cm, 0) || !almostEqual(*lead2.Brier10cm, 1) {
+		t.Fatalf("lead 2 Brier5/10 = %v/%v, want 0/1 for 10 cm not exceeding 10 cm", *lead2.Brier5cm, *lead2.Brier10cm)
+	}
+	if !results[0].ComputedAt.Equal(computedAt) {
+		t.Fatalf("ComputedAt = %s, want %s", results[0].ComputedAt, computedAt)
+	}
+
+	// Without a ranged sample there is no hit rate, rather than a 0 one.
+	rangeless := Aggregate([]Sample{{ResortID: "r", LeadDays: 0, ForecastCM: 3, ObservedCM: 0}}, computedAt)
+	if len(rangeless) != 1 || rangeless[0].RangeSampleCount != 0 || rangeless[0].RangeHitRate != nil {
+		t.Fatalf("Aggregate(rangeless) = %+v, want no range hit rate", rangeless)
+	}
+}
+
+func TestRun_PersistsVerification(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	if err := migrations.Migrate(ctx, db); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+
+	predRepo := repository.NewPredictionRepository(db)
+	writer := repository.NewWriter(db)
+
+	if err := predRepo.SavePredictions(ctx, &models.PredictionData{
+		GeneratedAt: "2025-01-15T00:00:00Z",
+		Resorts: map[string]models.Prediction{
+			"resort-1": {Daily: []models.DailyForecast{
+				{Date: "2025-01-15", SnowfallCM: 10, SnowfallRangeLow: 5, SnowfallRangeHigh: 15},
+				{Date: "2025-01-16", SnowfallCM: 20, SnowfallRangeLow: 10, SnowfallRangeHigh: 30},
+			}},
+		},
+	}); err != nil {
+		t.Fatalf("SavePredictions() error = %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO resorts (id, slug, name) VALUES ('resort-1', 'resort-1', 'One')`); err != nil {
+		t.Fatalf("insert resort: %v", err)
+	}
+	if err := writer.SaveDailySnowfall(ctx, []models.DailySnowfall{
+		{ResortID: "resort-1", Date: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), SnowfallCM: 12},
+		{ResortID: "resort-1", Date: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC), SnowfallCM: 40},
+	}); err != nil {
+		t.Fatalf("SaveDailySnowfall() error = %v", err)
+	}
+
+	results, err := Run(ctx, predRepo, Options{
+		From: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Run() returned %d results, want 2", len(results))
+	}
+
+	stored, err := predRepo.GetForecastVerification(ctx, "resort-1")
+	if err != nil {
+		t.Fatalf("GetForecastVerification() error = %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("stored %d verification rows, want 2", len(stored))
+	}
+	if stored[0].LeadDays != 0 || !almostEqual(stored[0].BiasCM, -2) || stored[0].RangeSampleCount != 1 ||
+		!almostEqualPtr(stored[0].RangeHitRate, 1) {
+		t.Fatalf("stored lead 0 = %+v", stored[0])
+	}
+	if stored[1].LeadDays != 1 || !almostEqual(stored[1].MAECM, 20) || !almostEqualPtr(stored[1].RangeHitRate, 0) {
+		t.Fatalf("stored lead 1 = %+v", stored[1])
+	}
+}